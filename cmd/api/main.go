@@ -3,19 +3,21 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"product-management/internal/api/routes"
+	"product-management/internal/api"
 	"product-management/internal/cache"
 	"product-management/internal/config"
+	"product-management/internal/currency"
+	"product-management/internal/migrate"
 	"product-management/internal/queue"
 	"product-management/internal/repository"
 	"product-management/internal/service"
+	"product-management/migrations"
 	"product-management/pkg/logger"
 
 	"github.com/gin-gonic/gin"
@@ -24,12 +26,16 @@ import (
 )
 
 func main() {
-	// Load configuration
-	cfg := config.LoadConfig()
-
 	// Initialize logger
 	appLogger := logger.NewLogger()
 
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		appLogger.Error("Configuration failed", logger.Error(err))
+		os.Exit(1)
+	}
+
 	// Database connection
 	dbConnStr := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -42,12 +48,29 @@ func main() {
 	}
 	defer db.Close()
 
+	// Bring the schema up to date; replicas wait for each other on the
+	// migration lock
+	if cfg.MigrateOnStartup {
+		all, err := migrate.Load(migrations.FS)
+		if err != nil {
+			appLogger.Error("Failed to load migrations", logger.Error(err))
+			os.Exit(1)
+		}
+		if _, err := migrate.NewMigrator(db.DB, all, appLogger).Up(context.Background()); err != nil {
+			appLogger.Error("Database migration failed", logger.Error(err))
+			os.Exit(1)
+		}
+	}
+
 	// Redis cache
 	redisURL := fmt.Sprintf("redis://%s:%s", cfg.RedisHost, cfg.RedisPort)
 	redisCache := cache.NewRedisCache(redisURL)
 
 	// RabbitMQ Publisher
-	rabbitMQURL := fmt.Sprintf("amqp://%s:%s", cfg.RabbitMQHost, cfg.RabbitMQPort)
+	rabbitMQURL := fmt.Sprintf(
+		"amqp://%s:%s@%s:%s/",
+		cfg.RabbitMQUser, cfg.RabbitMQPassword, cfg.RabbitMQHost, cfg.RabbitMQPort,
+	)
 	rabbitMQPublisher, err := queue.NewRabbitMQPublisher(rabbitMQURL)
 	if err != nil {
 		appLogger.Error("RabbitMQ connection failed", logger.Error(err))
@@ -56,26 +79,159 @@ func main() {
 	defer rabbitMQPublisher.Close()
 
 	// Repositories
+	transactor := repository.NewTransactor(db.DB)
 	productRepo := repository.NewProductRepository(db.DB, appLogger)
+	outboxRepo := repository.NewOutboxRepository(db.DB, appLogger)
+	userRepo := repository.NewUserRepository(db.DB, appLogger)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db.DB, appLogger)
+	apiKeyRepo := repository.NewAPIKeyRepository(db.DB, appLogger)
+	categoryRepo := repository.NewCategoryRepository(db.DB, appLogger)
+	variantRepo := repository.NewProductVariantRepository(db.DB, appLogger)
+	inventoryRepo := repository.NewInventoryRepository(db.DB, appLogger)
+	priceRepo := repository.NewPriceRepository(db.DB, appLogger)
+	auditRepo := repository.NewAuditRepository(db.DB, appLogger)
+
+	// Exchange rates for showing prices in other currencies
+	rateProvider, err := currency.NewRateProvider(cfg)
+	if err != nil {
+		appLogger.Error("Exchange rate setup failed", logger.Error(err))
+		os.Exit(1)
+	}
+
+	if cfg.JWTSecret == "" {
+		appLogger.Error("JWT_SECRET must be set")
+		os.Exit(1)
+	}
+	cursorSecret := cfg.CursorSecret
+	if cursorSecret == "" {
+		cursorSecret = cfg.JWTSecret
+	}
 
 	// Services
 	productService := service.NewProductService(
 		productRepo,
+		variantRepo,
+		priceRepo,
+		outboxRepo,
+		nil, // Image tasks are consumed by the image-processor worker
+		auditRepo,
+		transactor,
 		appLogger,
-		rabbitMQPublisher,
 		redisCache,
+		nil, // Images are processed by the image-processor worker
+		[]byte(cursorSecret),
 	)
 
+	userService := service.NewUserService(userRepo, refreshTokenRepo, auditRepo, transactor, appLogger)
+	categoryService := service.NewCategoryService(categoryRepo, appLogger, redisCache)
+	inventoryService := service.NewInventoryService(inventoryRepo, productRepo, variantRepo, outboxRepo, transactor, appLogger)
+	priceService := service.NewPriceService(priceRepo, productRepo, rateProvider, appLogger)
+	auditService := service.NewAuditService(auditRepo, appLogger)
+
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, transactor, appLogger, service.AuthConfig{
+		Secret:          []byte(cfg.JWTSecret),
+		Issuer:          cfg.JWTIssuer,
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+	})
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo, appLogger)
+
+	// Background jobs
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+
+	// Outbox relay
+	relayDone := make(chan struct{})
+	if cfg.OutboxRelayEnabled {
+		relay, err := service.NewOutboxRelay(transactor, outboxRepo, rabbitMQPublisher, appLogger, service.OutboxRelayConfig{
+			BatchSize:      cfg.OutboxBatchSize,
+			PollInterval:   cfg.OutboxPollInterval,
+			Retention:      cfg.OutboxRetention,
+			RetryBaseDelay: cfg.OutboxRetryBaseDelay,
+			RetryMaxDelay:  cfg.OutboxRetryMaxDelay,
+		})
+		if err != nil {
+			appLogger.Error("Outbox relay setup failed", logger.Error(err))
+			os.Exit(1)
+		}
+		go func() {
+			defer close(relayDone)
+			relay.Run(backgroundCtx)
+		}()
+	} else {
+		close(relayDone)
+	}
+
+	// Price change announcements of scheduled sales
+	schedulerDone := make(chan struct{})
+	scheduler, err := service.NewPriceScheduler(transactor, priceRepo, productRepo, outboxRepo, appLogger, service.PriceSchedulerConfig{
+		BatchSize:    cfg.PriceScheduleBatchSize,
+		PollInterval: cfg.PriceSchedulePollInterval,
+	})
+	if err != nil {
+		appLogger.Error("Price scheduler setup failed", logger.Error(err))
+		os.Exit(1)
+	}
+	go func() {
+		defer close(schedulerDone)
+		scheduler.Run(backgroundCtx)
+	}()
+
+	// Drop refresh tokens that can no longer be used
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if _, err := refreshTokenRepo.DeleteExpiredBefore(backgroundCtx, time.Now()); err != nil && backgroundCtx.Err() == nil {
+				appLogger.Error("Refresh token cleanup failed", logger.Error(err))
+			}
+			select {
+			case <-backgroundCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
+	// Only trusted proxies may set the client IP through X-Forwarded-For, or
+	// any client could pick a fresh IP, and rate limit, with every request
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		appLogger.Error("Invalid trusted proxy configuration", logger.Error(err))
+		os.Exit(1)
+	}
+
 	// Middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
+	router.Use(routes.RequestInfo())
+
+	// Rate limits are shared by every replica through Redis
+	rateLimiter := cache.NewRateLimiter(redisCache)
+	rateLimit := func(group, spec string) gin.HandlerFunc {
+		if !cfg.RateLimitEnabled {
+			return func(c *gin.Context) { c.Next() }
+		}
+		limit, err := cache.ParseRateLimit(spec)
+		if err != nil {
+			appLogger.Error("Invalid rate limit configuration", logger.Error(err))
+			os.Exit(1)
+		}
+		return routes.RateLimit(rateLimiter, group, limit, appLogger)
+	}
 
 	// Setup routes
-	routes.SetupProductRoutes(router, productService, appLogger)
+	requireAuth := routes.RequireAuth(authService, apiKeyService, appLogger)
+	routes.SetupAuthRoutes(router, authService, rateLimit("auth", cfg.RateLimitAuth), appLogger)
+	routes.SetupProductRoutes(router, productService, priceService, requireAuth, rateLimit("products", cfg.RateLimitProducts), appLogger)
+	routes.SetupCategoryRoutes(router, categoryService, requireAuth, rateLimit("categories", cfg.RateLimitProducts), appLogger)
+	routes.SetupInventoryRoutes(router, inventoryService, requireAuth, rateLimit("inventory", cfg.RateLimitProducts), appLogger)
+	routes.SetupPriceRoutes(router, priceService, requireAuth, rateLimit("prices", cfg.RateLimitProducts), appLogger)
+	routes.SetupUserRoutes(router, userService, requireAuth, rateLimit("users", cfg.RateLimitUsers), appLogger)
+	routes.SetupAPIKeyRoutes(router, apiKeyService, requireAuth, rateLimit("api-keys", cfg.RateLimitUsers), appLogger)
+	routes.SetupAuditRoutes(router, auditService, requireAuth, rateLimit("audit", cfg.RateLimitUsers), appLogger)
 
 	// HTTP Server
 	srv := &http.Server{
@@ -108,5 +264,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Stop the relay before the publisher it uses is closed
+	stopBackground()
+	<-relayDone
+	<-schedulerDone
+
 	appLogger.Info("Server exiting")
-}
\ No newline at end of file
+}
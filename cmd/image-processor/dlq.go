@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"product-management/internal/queue"
+)
+
+const dlqUsage = `usage: image-processor dlq <command> [flags]
+
+commands:
+  inspect [-limit N]  print dead-lettered image tasks without removing them
+  replay  [-limit N]  move dead-lettered tasks back onto the image queue
+  purge               delete every dead-lettered task
+`
+
+// runDLQCommand implements the dlq subcommand and returns the exit code.
+func runDLQCommand(rabbitMQURL string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, dlqUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("dlq "+args[0], flag.ContinueOnError)
+	limit := flags.Int("limit", 100, "maximum number of messages to process")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "inspect", "replay", "purge":
+	default:
+		fmt.Fprint(os.Stderr, dlqUsage)
+		return 2
+	}
+
+	admin, err := queue.NewDeadLetterAdmin(rabbitMQURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer admin.Close()
+
+	if err := queue.RunDeadLetterCommand(admin, args[0], *limit, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -3,15 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"product-management/internal/config"
 	"product-management/internal/queue"
 	"product-management/internal/repository"
 	"product-management/internal/service"
+	"product-management/internal/storage"
 	"product-management/pkg/logger"
 
 	"github.com/jmoiron/sqlx"
@@ -19,12 +20,26 @@ import (
 )
 
 func main() {
-	// Load configuration
-	cfg := config.LoadConfig()
-
 	// Initialize logger
 	appLogger := logger.NewLogger()
 
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		appLogger.Error("Configuration failed", logger.Error(err))
+		os.Exit(1)
+	}
+
+	rabbitMQURL := fmt.Sprintf(
+		"amqp://%s:%s@%s:%s/",
+		cfg.RabbitMQUser, cfg.RabbitMQPassword, cfg.RabbitMQHost, cfg.RabbitMQPort,
+	)
+
+	// Dead-letter queue maintenance: image-processor dlq <inspect|replay|purge>
+	if len(os.Args) > 1 && os.Args[1] == "dlq" {
+		os.Exit(runDLQCommand(rabbitMQURL, os.Args[2:]))
+	}
+
 	// Database connection
 	dbConnStr := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
@@ -38,8 +53,11 @@ func main() {
 	defer db.Close()
 
 	// RabbitMQ Consumer
-	rabbitMQURL := fmt.Sprintf("amqp://%s:%s", cfg.RabbitMQHost, cfg.RabbitMQPort)
-	rabbitMQConsumer, err := queue.NewRabbitMQConsumer(rabbitMQURL)
+	rabbitMQConsumer, err := queue.NewRabbitMQConsumer(rabbitMQURL, cfg.RabbitMQPrefetch, queue.RetryPolicy{
+		MaxAttempts: cfg.ImageTaskMaxAttempts,
+		BaseDelay:   cfg.ImageTaskRetryBaseDelay,
+		MaxDelay:    cfg.ImageTaskRetryMaxDelay,
+	})
 	if err != nil {
 		appLogger.Error("RabbitMQ connection failed", logger.Error(err))
 		os.Exit(1)
@@ -47,14 +65,49 @@ func main() {
 	defer rabbitMQConsumer.Close()
 
 	// Repositories
+	transactor := repository.NewTransactor(db.DB)
 	productRepo := repository.NewProductRepository(db.DB, appLogger)
+	processedRepo := repository.NewProcessedMessageRepository(db.DB, appLogger)
+	auditRepo := repository.NewAuditRepository(db.DB, appLogger)
+
+	// Object storage for processed images
+	blobStore, err := storage.NewBlobStore(cfg)
+	if err != nil {
+		appLogger.Error("Object storage setup failed", logger.Error(err))
+		os.Exit(1)
+	}
+
+	// Image pipeline
+	renditions, err := service.ParseRenditions(cfg.ImageRenditions)
+	if err != nil {
+		appLogger.Error("Invalid image renditions", logger.Error(err))
+		os.Exit(1)
+	}
+
+	imageProcessor := service.NewImageProcessor(
+		service.NewImageSource(service.NewPublicHTTPClient(30*time.Second), cfg.ImageSourceDir),
+		blobStore,
+		service.ImageProcessorConfig{
+			Renditions:       renditions,
+			PrimaryRendition: cfg.ImagePrimaryRendition,
+			Quality:          cfg.ImageQuality,
+			MaxSourceBytes:   cfg.ImageMaxSourceBytes,
+		},
+	)
 
 	// Services
 	productService := service.NewProductService(
 		productRepo,
+		nil, // The processor does not touch variants
+		nil, // The processor does not price products
+		nil, // The processor enqueues no messages
+		processedRepo,
+		auditRepo,
+		transactor,
 		appLogger,
-		nil, // No publisher needed for processor
 		nil, // No cache needed for processor
+		imageProcessor,
+		nil, // The processor lists no products
 	)
 
 	// Context for cancellation
@@ -62,9 +115,13 @@ func main() {
 	defer cancel()
 
 	// Image processing message handler
-	imageProcessingHandler := func(productID int64, imageURLs []string) error {
-		appLogger.Info(fmt.Sprintf("Processing images for product %d", productID))
-		return productService.ProcessProductImages(ctx, productID, imageURLs)
+	imageProcessingHandler := func(ctx context.Context, task *queue.ImageProcessingTask) error {
+		appLogger.Info(fmt.Sprintf("Processing images for product %d", task.ProductID))
+		if err := productService.ProcessImageTask(ctx, task); err != nil {
+			appLogger.Error("Image processing failed", logger.Error(err))
+			return err
+		}
+		return nil
 	}
 
 	// Start consuming messages
@@ -76,16 +133,36 @@ func main() {
 		}
 	}()
 
+	// Forget processed message IDs once redeliveries are no longer expected
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			cutoff := time.Now().Add(-cfg.ProcessedMessageTTL)
+			if _, err := processedRepo.DeleteProcessedBefore(ctx, cutoff); err != nil && ctx.Err() == nil {
+				appLogger.Error("Processed message cleanup failed", logger.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	// Block until a signal is received
-	<-quit
+	// Block until a signal is received or the consumer gives up
+	select {
+	case <-quit:
+	case <-ctx.Done():
+	}
 	appLogger.Info("Shutting down image processor...")
 
 	// Cancel context to stop processing
 	cancel()
 
 	appLogger.Info("Image processor stopped")
-}
\ No newline at end of file
+}
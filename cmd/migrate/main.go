@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"product-management/internal/config"
+	"product-management/internal/migrate"
+	"product-management/migrations"
+	"product-management/pkg/logger"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+const usage = `usage: migrate <command>
+
+commands:
+  up          apply every pending migration
+  down N      revert the N most recently applied migrations
+  status      list migrations and when they were applied
+  force V     record migrations up to V as applied without running them`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// Initialize logger
+	appLogger := logger.NewLogger()
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		appLogger.Error("Configuration failed", logger.Error(err))
+		os.Exit(1)
+	}
+
+	// Database connection
+	dbConnStr := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
+	)
+	db, err := sqlx.Connect("postgres", dbConnStr)
+	if err != nil {
+		appLogger.Error("Database connection failed", logger.Error(err))
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		appLogger.Error("Failed to load migrations", logger.Error(err))
+		os.Exit(1)
+	}
+	migrator := migrate.NewMigrator(db.DB, all, appLogger)
+
+	if err := run(context.Background(), migrator, os.Args[1:]); err != nil {
+		appLogger.Error("Migration failed", logger.Error(err))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		applied, err := migrator.Up(ctx)
+		fmt.Printf("Applied %d migration(s)\n", applied)
+		return err
+
+	case args[0] == "down" && len(args) == 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("down needs a positive number of migrations, got %q", args[1])
+		}
+		reverted, err := migrator.Down(ctx, n)
+		fmt.Printf("Reverted %d migration(s)\n", reverted)
+		return err
+
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%03d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+
+	case args[0] == "force" && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("force needs a migration version, got %q", args[1])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Printf("Recorded migrations up to %d as applied\n", version)
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(2)
+	return nil
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupAPIKeyRoutes(router *gin.Engine, apiKeyService *service.APIKeyService, requireAuth, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
+	keys := router.Group("/api/v1/api-keys", requireAuth, rateLimit)
+	{
+		// Issue a key acting as the caller; the key is only shown here
+		keys.POST("", func(c *gin.Context) {
+			var req models.APIKeyCreateRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			key, err := apiKeyService.CreateAPIKey(c.Request.Context(), currentIdentity(c), &req)
+			if err != nil {
+				respondError(c, appLogger, "API key creation failed", err)
+				return
+			}
+
+			c.JSON(http.StatusCreated, key)
+		})
+
+		// List the caller's keys
+		keys.GET("", func(c *gin.Context) {
+			apiKeys, err := apiKeyService.ListAPIKeys(c.Request.Context(), currentIdentity(c))
+			if err != nil {
+				respondError(c, appLogger, "API key listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"api_keys": apiKeys})
+		})
+
+		// Revoke a key
+		keys.DELETE("/:id", func(c *gin.Context) {
+			keyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+				return
+			}
+
+			if err := apiKeyService.RevokeAPIKey(c.Request.Context(), currentIdentity(c), keyID); err != nil {
+				respondError(c, appLogger, "API key revocation failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
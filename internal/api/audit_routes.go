@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"net/http"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupAuditRoutes(router *gin.Engine, auditService *service.AuditService, requireAuth, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
+	v1 := router.Group("/api/v1", requireAuth, rateLimit)
+	{
+		// Search the audit log by entity, actor and time
+		v1.GET("/audit", func(c *gin.Context) {
+			var params models.AuditFilterParams
+			if err := c.ShouldBindQuery(&params); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			entries, total, err := auditService.ListEntries(c.Request.Context(), currentIdentity(c), &params)
+			if err != nil {
+				respondError(c, appLogger, "Audit log retrieval failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"entries":     entries,
+				"total_count": total,
+				"page":        params.Page,
+				"page_size":   params.PageSize,
+			})
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// identityKey stores the authenticated caller on the Gin context.
+const identityKey = "identity"
+
+// RequireAuth rejects requests without a valid bearer access token or API
+// key and makes the caller's identity available to handlers. API keys are
+// sent as "Authorization: ApiKey <key>".
+func RequireAuth(authService *service.AuthService, apiKeyService *service.APIKeyService, appLogger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, credentials, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || credentials == "" {
+			challenge(c, "Missing bearer token or API key")
+			return
+		}
+
+		var identity *models.Identity
+		var err error
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			identity, err = authService.Authenticate(credentials)
+		case strings.EqualFold(scheme, "ApiKey"):
+			identity, err = apiKeyService.Authenticate(c.Request.Context(), credentials)
+		default:
+			challenge(c, "Unsupported authorization scheme")
+			return
+		}
+		if errors.Is(err, service.ErrInvalidToken) {
+			c.Header("WWW-Authenticate", scheme+` error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			respondError(c, appLogger, "Authentication failed", err)
+			c.Abort()
+			return
+		}
+
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
+
+// challenge rejects an unauthenticated request, listing the accepted
+// schemes.
+func challenge(c *gin.Context, msg string) {
+	c.Writer.Header().Add("WWW-Authenticate", "Bearer")
+	c.Writer.Header().Add("WWW-Authenticate", "ApiKey")
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msg})
+}
+
+// currentIdentity returns the caller set by RequireAuth.
+func currentIdentity(c *gin.Context) *models.Identity {
+	identity, _ := c.MustGet(identityKey).(*models.Identity)
+	return identity
+}
+
+func SetupAuthRoutes(router *gin.Engine, authService *service.AuthService, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
+	auth := router.Group("/api/v1/auth", rateLimit)
+	{
+		// Exchange an email and password for a token pair
+		auth.POST("/login", func(c *gin.Context) {
+			var req models.LoginRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			tokens, err := authService.Login(c.Request.Context(), &req)
+			if err != nil {
+				respondError(c, appLogger, "Login failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, tokens)
+		})
+
+		// Rotate a refresh token
+		auth.POST("/refresh", func(c *gin.Context) {
+			var req models.RefreshRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			tokens, err := authService.Refresh(c.Request.Context(), req.RefreshToken)
+			if err != nil {
+				respondError(c, appLogger, "Token refresh failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, tokens)
+		})
+
+		// Revoke a refresh token and every token rotated from it
+		auth.POST("/logout", func(c *gin.Context) {
+			var req models.RefreshRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if err := authService.Logout(c.Request.Context(), req.RefreshToken); err != nil {
+				respondError(c, appLogger, "Logout failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
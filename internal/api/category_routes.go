@@ -0,0 +1,107 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupCategoryRoutes(router *gin.Engine, categoryService *service.CategoryService, requireAuth, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
+	categories := router.Group("/api/v1/categories", requireAuth, rateLimit)
+	{
+		// Create a category, below parent_id or as a root
+		categories.POST("", func(c *gin.Context) {
+			var req models.CategoryRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			category, err := categoryService.CreateCategory(c.Request.Context(), currentIdentity(c), &req)
+			if err != nil {
+				respondError(c, appLogger, "Category creation failed", err)
+				return
+			}
+
+			c.JSON(http.StatusCreated, category)
+		})
+
+		// List the whole tree, depth first
+		categories.GET("", func(c *gin.Context) {
+			list, err := categoryService.ListCategories(c.Request.Context(), currentIdentity(c))
+			if err != nil {
+				respondError(c, appLogger, "Category listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"categories": list})
+		})
+
+		// Get category by ID
+		categories.GET("/:id", func(c *gin.Context) {
+			categoryID, ok := parseCategoryID(c)
+			if !ok {
+				return
+			}
+
+			category, err := categoryService.GetCategory(c.Request.Context(), currentIdentity(c), categoryID)
+			if err != nil {
+				respondError(c, appLogger, "Category retrieval failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, category)
+		})
+
+		// Rename or move a category
+		categories.PUT("/:id", func(c *gin.Context) {
+			categoryID, ok := parseCategoryID(c)
+			if !ok {
+				return
+			}
+
+			var req models.CategoryRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			category, err := categoryService.UpdateCategory(c.Request.Context(), currentIdentity(c), categoryID, &req)
+			if err != nil {
+				respondError(c, appLogger, "Category update failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, category)
+		})
+
+		// Delete a category without subcategories
+		categories.DELETE("/:id", func(c *gin.Context) {
+			categoryID, ok := parseCategoryID(c)
+			if !ok {
+				return
+			}
+
+			if err := categoryService.DeleteCategory(c.Request.Context(), currentIdentity(c), categoryID); err != nil {
+				respondError(c, appLogger, "Category deletion failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
+
+func parseCategoryID(c *gin.Context) (int64, bool) {
+	categoryID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return 0, false
+	}
+	return categoryID, true
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupInventoryRoutes(router *gin.Engine, inventoryService *service.InventoryService, requireAuth, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
+	v1 := router.Group("/api/v1", requireAuth, rateLimit)
+	{
+		// Add a warehouse
+		v1.POST("/warehouses", func(c *gin.Context) {
+			var req models.WarehouseCreateRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			warehouse, err := inventoryService.CreateWarehouse(c.Request.Context(), currentIdentity(c), &req)
+			if err != nil {
+				respondError(c, appLogger, "Warehouse creation failed", err)
+				return
+			}
+
+			c.JSON(http.StatusCreated, warehouse)
+		})
+
+		// List warehouses
+		v1.GET("/warehouses", func(c *gin.Context) {
+			warehouses, err := inventoryService.ListWarehouses(c.Request.Context(), currentIdentity(c))
+			if err != nil {
+				respondError(c, appLogger, "Warehouse listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"warehouses": warehouses})
+		})
+
+		// Stock of a product per variant and warehouse
+		v1.GET("/products/:id/inventory", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var params models.InventoryParams
+			if err := c.ShouldBindQuery(&params); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			levels, err := inventoryService.GetStock(c.Request.Context(), currentIdentity(c), productID, &params)
+			if err != nil {
+				respondError(c, appLogger, "Stock retrieval failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"inventory": levels})
+		})
+
+		// Add or take units on hand
+		v1.POST("/products/:id/inventory/adjust", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var req models.StockAdjustRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			level, err := inventoryService.AdjustStock(c.Request.Context(), currentIdentity(c), productID, &req)
+			if err != nil {
+				respondError(c, appLogger, "Stock adjustment failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, level)
+		})
+
+		// The stock ledger of a product, newest first
+		v1.GET("/products/:id/inventory/movements", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var params models.StockMovementParams
+			if err := c.ShouldBindQuery(&params); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			movements, total, err := inventoryService.ListMovements(c.Request.Context(), currentIdentity(c), productID, &params)
+			if err != nil {
+				respondError(c, appLogger, "Stock movement listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"movements":   movements,
+				"total_count": total,
+				"page":        params.Page,
+				"page_size":   params.PageSize,
+			})
+		})
+
+		// Hold units for an order
+		v1.POST("/products/:id/inventory/reservations", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var req models.StockReserveRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			reservation, err := inventoryService.ReserveStock(c.Request.Context(), currentIdentity(c), productID, &req)
+			if err != nil {
+				respondError(c, appLogger, "Stock reservation failed", err)
+				return
+			}
+
+			c.JSON(http.StatusCreated, reservation)
+		})
+
+		// Take reserved units off the shelf
+		v1.POST("/inventory/reservations/:id/commit", func(c *gin.Context) {
+			reservationID, ok := parseReservationID(c)
+			if !ok {
+				return
+			}
+
+			reservation, err := inventoryService.CommitReservation(c.Request.Context(), currentIdentity(c), reservationID)
+			if err != nil {
+				respondError(c, appLogger, "Reservation commit failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, reservation)
+		})
+
+		// Make reserved units available again
+		v1.POST("/inventory/reservations/:id/release", func(c *gin.Context) {
+			reservationID, ok := parseReservationID(c)
+			if !ok {
+				return
+			}
+
+			reservation, err := inventoryService.ReleaseReservation(c.Request.Context(), currentIdentity(c), reservationID)
+			if err != nil {
+				respondError(c, appLogger, "Reservation release failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, reservation)
+		})
+	}
+}
+
+func parseReservationID(c *gin.Context) (int64, bool) {
+	reservationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reservation ID"})
+		return 0, false
+	}
+	return reservationID, true
+}
@@ -0,0 +1,91 @@
+package routes
+
+import (
+	"net/http"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupPriceRoutes(router *gin.Engine, priceService *service.PriceService, requireAuth, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
+	v1 := router.Group("/api/v1", requireAuth, rateLimit)
+	{
+		// Price list of a product in other currencies
+		v1.GET("/products/:id/prices", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			prices, err := priceService.ListPrices(c.Request.Context(), currentIdentity(c), productID)
+			if err != nil {
+				respondError(c, appLogger, "Price listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"prices": prices})
+		})
+
+		// Price changes of a product and its price statistics over a period
+		v1.GET("/products/:id/price-history", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var params models.PriceHistoryParams
+			if err := c.ShouldBindQuery(&params); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			history, err := priceService.PriceHistory(c.Request.Context(), currentIdentity(c), productID, &params)
+			if err != nil {
+				respondError(c, appLogger, "Price history retrieval failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, history)
+		})
+
+		// Set the price of a product in a currency
+		v1.PUT("/products/:id/prices/:currency", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var req models.CurrencyPriceRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			price, err := priceService.SetPrice(c.Request.Context(), currentIdentity(c), productID, c.Param("currency"), &req)
+			if err != nil {
+				respondError(c, appLogger, "Price update failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, price)
+		})
+
+		// Remove the price of a product in a currency
+		v1.DELETE("/products/:id/prices/:currency", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			if err := priceService.DeletePrice(c.Request.Context(), currentIdentity(c), productID, c.Param("currency")); err != nil {
+				respondError(c, appLogger, "Price deletion failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"product-management/internal/cache"
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits how often each client may call the routes of a group.
+// Clients are told apart by API key, then by user, then by IP address, so
+// it must run after RequireAuth on authenticated groups. API keys with
+// their own limit get that many requests per minute instead of limit.
+// When Redis is unavailable requests are let through rather than failed.
+func RateLimit(limiter *cache.RateLimiter, group string, limit cache.RateLimit, appLogger *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, clientLimit := rateLimitClient(c, limit)
+		key := fmt.Sprintf("ratelimit:%s:%s", group, client)
+
+		result, err := limiter.Allow(c.Request.Context(), key, clientLimit)
+		if err != nil {
+			appLogger.Error("Rate limiting failed", logger.Error(err))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", clientLimit.Requests, int(clientLimit.Period.Seconds())))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitClient returns who a request is counted against, and their
+// limit.
+func rateLimitClient(c *gin.Context, limit cache.RateLimit) (string, cache.RateLimit) {
+	value, ok := c.Get(identityKey)
+	if !ok {
+		return "ip:" + c.ClientIP(), limit
+	}
+
+	identity := value.(*models.Identity)
+	if identity.IsAPIKey() {
+		if identity.RateLimit > 0 {
+			limit = cache.RateLimit{Requests: identity.RateLimit, Period: time.Minute}
+		}
+		return fmt.Sprintf("key:%d", identity.APIKeyID), limit
+	}
+
+	return fmt.Sprintf("user:%d", identity.UserID), limit
+}
+
+// ceilSeconds rounds up so clients never retry too early.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
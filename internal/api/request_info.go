@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request, from the client or a proxy
+// in front, or else generated here. It is echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestInfo identifies every request by its ID and source IP address and
+// passes both on to the services in the request context, for the audit
+// log. The source is the address the request was received from: unlike
+// X-Forwarded-For, it cannot be made up by the client. It must run before
+// any handler that changes data.
+func RequestInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := service.WithRequestInfo(c.Request.Context(), models.RequestInfo{
+			RequestID: requestID,
+			SourceIP:  c.RemoteIP(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
+
+// validRequestID accepts short IDs of printable ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("failed to generate request ID: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
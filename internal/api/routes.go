@@ -1,83 +1,396 @@
 package routes
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	"product-management/internal/currency"
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
 	"github.com/gin-gonic/gin"
-	"github.com/product-management/internal/service"
-	"github.com/product-management/pkg/logger"
+	"github.com/go-playground/validator/v10"
 )
 
-func SetupProductRoutes(router *gin.Engine, productService *service.ProductService, logger *logger.Logger) {
+// SetupProductRoutes registers the product endpoints. Reads take an
+// optional currency parameter to add each product's price in it.
+func SetupProductRoutes(router *gin.Engine, productService *service.ProductService, priceService *service.PriceService, requireAuth, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
 	// Product group routes
-	v1 := router.Group("/api/v1")
+	v1 := router.Group("/api/v1", requireAuth, rateLimit)
 	{
 		// Create a new product
 		v1.POST("/products", func(c *gin.Context) {
-			var req struct {
-				UserID             int64    `json:"user_id" binding:"required"`
-				ProductName        string   `json:"product_name" binding:"required"`
-				ProductDescription string   `json:"product_description"`
-				ProductPrice       float64  `json:"product_price" binding:"required,min=0"`
-				ProductImages      []string `json:"product_images" binding:"required"`
-			}
-
+			var req models.ProductCreateRequest
 			if err := c.ShouldBindJSON(&req); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			req.UserID = currentIdentity(c).UserID
 
-			product, err := productService.CreateProduct(c.Request.Context(), &req)
+			product, err := productService.CreateProduct(c.Request.Context(), currentIdentity(c), &req)
 			if err != nil {
-				logger.Error("Product creation failed", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondError(c, appLogger, "Product creation failed", err)
 				return
 			}
 
 			c.JSON(http.StatusCreated, product)
 		})
 
+		// Full-text search across every seller's products
+		v1.GET("/products/search", func(c *gin.Context) {
+			var params models.ProductSearchParams
+			if err := c.ShouldBindQuery(&params); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			results, total, err := productService.SearchProducts(c.Request.Context(), currentIdentity(c), &params)
+			if err != nil {
+				respondError(c, appLogger, "Product search failed", err)
+				return
+			}
+			if code := c.Query("currency"); code != "" {
+				products := make([]*models.Product, len(results))
+				for i := range results {
+					products[i] = &results[i].Product
+				}
+				if err := priceService.Localize(c.Request.Context(), code, products...); err != nil {
+					respondError(c, appLogger, "Price conversion failed", err)
+					return
+				}
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"results":     results,
+				"total_count": total,
+				"page":        params.Page,
+				"page_size":   params.PageSize,
+			})
+		})
+
 		// Get product by ID
 		v1.GET("/products/:id", func(c *gin.Context) {
-			productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			product, err := productService.GetProductByID(c.Request.Context(), currentIdentity(c), productID)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+				respondError(c, appLogger, "Product retrieval failed", err)
+				return
+			}
+			if code := c.Query("currency"); code != "" {
+				if err := priceService.Localize(c.Request.Context(), code, product); err != nil {
+					respondError(c, appLogger, "Price conversion failed", err)
+					return
+				}
+			}
+
+			c.JSON(http.StatusOK, product)
+		})
+
+		// Replace a product
+		v1.PUT("/products/:id", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
 				return
 			}
 
-			product, err := productService.GetProductByID(c.Request.Context(), productID)
+			var req models.ProductUpdateRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			product, err := productService.UpdateProduct(c.Request.Context(), currentIdentity(c), productID, &req)
 			if err != nil {
-				logger.Error("Product retrieval failed", err)
-				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				respondError(c, appLogger, "Product update failed", err)
 				return
 			}
 
 			c.JSON(http.StatusOK, product)
 		})
 
-		// List products
+		// Partially update a product (JSON merge patch)
+		v1.PATCH("/products/:id", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			patch, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+				return
+			}
+
+			product, err := productService.PatchProduct(c.Request.Context(), currentIdentity(c), productID, patch)
+			if err != nil {
+				respondError(c, appLogger, "Product patch failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, product)
+		})
+
+		// Delete a product
+		v1.DELETE("/products/:id", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			if err := productService.DeleteProduct(c.Request.Context(), currentIdentity(c), productID); err != nil {
+				respondError(c, appLogger, "Product deletion failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+
+		// Render a product's images again
+		v1.POST("/products/:id/images/reprocess", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			product, err := productService.ReprocessProductImages(c.Request.Context(), currentIdentity(c), productID)
+			if err != nil {
+				respondError(c, appLogger, "Image reprocessing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusAccepted, product)
+		})
+
+		// Replace the categories a product is filed under
+		v1.PUT("/products/:id/categories", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var req models.ProductCategoriesRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			categories, err := productService.SetProductCategories(c.Request.Context(), currentIdentity(c), productID, &req)
+			if err != nil {
+				respondError(c, appLogger, "Product categorization failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"categories": categories})
+		})
+
+		// Add a variant to a product
+		v1.POST("/products/:id/variants", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var req models.ProductVariantRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			variant, err := productService.CreateVariant(c.Request.Context(), currentIdentity(c), productID, &req)
+			if err != nil {
+				respondError(c, appLogger, "Variant creation failed", err)
+				return
+			}
+
+			c.JSON(http.StatusCreated, variant)
+		})
+
+		// List a product's variants
+		v1.GET("/products/:id/variants", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			variants, err := productService.ListVariants(c.Request.Context(), currentIdentity(c), productID)
+			if err != nil {
+				respondError(c, appLogger, "Variant listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"variants": variants})
+		})
+
+		// Get a variant
+		v1.GET("/products/:id/variants/:variant_id", func(c *gin.Context) {
+			productID, variantID, ok := parseVariantID(c)
+			if !ok {
+				return
+			}
+
+			variant, err := productService.GetVariant(c.Request.Context(), currentIdentity(c), productID, variantID)
+			if err != nil {
+				respondError(c, appLogger, "Variant retrieval failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, variant)
+		})
+
+		// Replace a variant
+		v1.PUT("/products/:id/variants/:variant_id", func(c *gin.Context) {
+			productID, variantID, ok := parseVariantID(c)
+			if !ok {
+				return
+			}
+
+			var req models.ProductVariantRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			variant, err := productService.UpdateVariant(c.Request.Context(), currentIdentity(c), productID, variantID, &req)
+			if err != nil {
+				respondError(c, appLogger, "Variant update failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, variant)
+		})
+
+		// Delete a variant
+		v1.DELETE("/products/:id/variants/:variant_id", func(c *gin.Context) {
+			productID, variantID, ok := parseVariantID(c)
+			if !ok {
+				return
+			}
+
+			if err := productService.DeleteVariant(c.Request.Context(), currentIdentity(c), productID, variantID); err != nil {
+				respondError(c, appLogger, "Variant deletion failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+
+		// Schedule a sale price for a product
+		v1.POST("/products/:id/price-schedules", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			var req models.PriceScheduleRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			schedule, err := productService.CreatePriceSchedule(c.Request.Context(), currentIdentity(c), productID, &req)
+			if err != nil {
+				respondError(c, appLogger, "Price schedule creation failed", err)
+				return
+			}
+
+			c.JSON(http.StatusCreated, schedule)
+		})
+
+		// List a product's price schedules
+		v1.GET("/products/:id/price-schedules", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+
+			schedules, err := productService.ListPriceSchedules(c.Request.Context(), currentIdentity(c), productID)
+			if err != nil {
+				respondError(c, appLogger, "Price schedule listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"price_schedules": schedules})
+		})
+
+		// Cancel a price schedule
+		v1.DELETE("/products/:id/price-schedules/:schedule_id", func(c *gin.Context) {
+			productID, ok := parseProductID(c)
+			if !ok {
+				return
+			}
+			scheduleID, err := strconv.ParseInt(c.Param("schedule_id"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid price schedule ID"})
+				return
+			}
+
+			if err := productService.DeletePriceSchedule(c.Request.Context(), currentIdentity(c), productID, scheduleID); err != nil {
+				respondError(c, appLogger, "Price schedule deletion failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+
+		// List the catalog, optionally filtered and sorted
 		v1.GET("/products", func(c *gin.Context) {
-			var params service.ProductFilterParams
+			var params models.ProductFilterParams
 			if err := c.ShouldBindQuery(&params); err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
+			if attributes := c.QueryMap("attr"); len(attributes) > 0 {
+				params.Attributes = attributes
+			}
+			for _, bound := range c.QueryArray("price_bucket") {
+				amount, err := models.ParseAmount(bound)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				params.PriceBuckets = append(params.PriceBuckets, amount)
+			}
 
-			products, total, err := productService.ListProducts(c.Request.Context(), &params)
+			page, err := productService.ListProducts(c.Request.Context(), currentIdentity(c), &params)
 			if err != nil {
-				logger.Error("Products listing failed", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				respondError(c, appLogger, "Products listing failed", err)
 				return
 			}
+			if code := c.Query("currency"); code != "" {
+				products := make([]*models.Product, len(page.Products))
+				for i := range page.Products {
+					products[i] = &page.Products[i]
+				}
+				if err := priceService.Localize(c.Request.Context(), code, products...); err != nil {
+					respondError(c, appLogger, "Price conversion failed", err)
+					return
+				}
+			}
 
-			c.JSON(http.StatusOK, gin.H{
-				"products":    products,
-				"total_count": total,
+			response := gin.H{
+				"products":    page.Products,
+				"total_count": page.TotalCount,
 				"page":        params.Page,
 				"page_size":   params.PageSize,
-			})
+				"next_cursor": page.NextCursor,
+				"prev_cursor": page.PrevCursor,
+			}
+			if page.CountEstimated {
+				response["count_estimated"] = true
+			}
+			if page.Facets != nil {
+				response["facets"] = page.Facets
+			}
+			c.JSON(http.StatusOK, response)
 		})
 	}
 
@@ -89,3 +402,61 @@ func SetupProductRoutes(router *gin.Engine, productService *service.ProductServi
 		})
 	})
 }
+
+func parseProductID(c *gin.Context) (int64, bool) {
+	productID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return 0, false
+	}
+	return productID, true
+}
+
+func parseVariantID(c *gin.Context) (int64, int64, bool) {
+	productID, ok := parseProductID(c)
+	if !ok {
+		return 0, 0, false
+	}
+
+	variantID, err := strconv.ParseInt(c.Param("variant_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid variant ID"})
+		return 0, 0, false
+	}
+	return productID, variantID, true
+}
+
+// respondError maps service and repository errors onto HTTP status codes.
+// Only unexpected failures are logged; client errors are just reported.
+func respondError(c *gin.Context, appLogger *logger.Logger, msg string, err error) {
+	var validationErrs validator.ValidationErrors
+
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound), errors.Is(err, repository.ErrUserNotFound),
+		errors.Is(err, repository.ErrAPIKeyNotFound), errors.Is(err, repository.ErrCategoryNotFound),
+		errors.Is(err, repository.ErrVariantNotFound), errors.Is(err, repository.ErrReservationNotFound),
+		errors.Is(err, repository.ErrPriceNotFound), errors.Is(err, repository.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErrs), errors.Is(err, service.ErrInvalidPatch),
+		errors.Is(err, service.ErrInvalidCursor), errors.Is(err, service.ErrInvalidPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrUserExists), errors.Is(err, repository.ErrUserHasProducts),
+		errors.Is(err, repository.ErrCategoryExists), errors.Is(err, repository.ErrCategoryHasChildren),
+		errors.Is(err, repository.ErrSKUExists), errors.Is(err, repository.ErrWarehouseExists),
+		errors.Is(err, repository.ErrInsufficientStock), errors.Is(err, service.ErrReservationClosed),
+		errors.Is(err, repository.ErrScheduleOverlap):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUnknownUser), errors.Is(err, repository.ErrUnknownCategory),
+		errors.Is(err, repository.ErrCategoryCycle), errors.Is(err, repository.ErrUnknownWarehouse),
+		errors.Is(err, service.ErrOwnCurrency), errors.Is(err, currency.ErrRateUnavailable),
+		errors.Is(err, service.ErrScheduleEnded):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrInvalidToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		appLogger.Error(msg, logger.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
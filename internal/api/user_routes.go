@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"product-management/internal/models"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupUserRoutes(router *gin.Engine, userService *service.UserService, requireAuth, rateLimit gin.HandlerFunc, appLogger *logger.Logger) {
+	// Sign up
+	router.POST("/api/v1/users", rateLimit, func(c *gin.Context) {
+		var req models.UserCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, err := userService.CreateUser(c.Request.Context(), &req)
+		if err != nil {
+			respondError(c, appLogger, "User creation failed", err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, user)
+	})
+
+	v1 := router.Group("/api/v1", requireAuth, rateLimit)
+	{
+		// Get user by ID
+		v1.GET("/users/:id", func(c *gin.Context) {
+			userID, ok := parseUserID(c)
+			if !ok {
+				return
+			}
+
+			user, err := userService.GetUserByID(c.Request.Context(), currentIdentity(c), userID)
+			if err != nil {
+				respondError(c, appLogger, "User retrieval failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, user)
+		})
+
+		// Replace a user
+		v1.PUT("/users/:id", func(c *gin.Context) {
+			userID, ok := parseUserID(c)
+			if !ok {
+				return
+			}
+
+			var req models.UserUpdateRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			user, err := userService.UpdateUser(c.Request.Context(), currentIdentity(c), userID, &req)
+			if err != nil {
+				respondError(c, appLogger, "User update failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, user)
+		})
+
+		// Delete a user
+		v1.DELETE("/users/:id", func(c *gin.Context) {
+			userID, ok := parseUserID(c)
+			if !ok {
+				return
+			}
+
+			if err := userService.DeleteUser(c.Request.Context(), currentIdentity(c), userID); err != nil {
+				respondError(c, appLogger, "User deletion failed", err)
+				return
+			}
+
+			c.Status(http.StatusNoContent)
+		})
+
+		// List users, optionally looking one up by email
+		v1.GET("/users", func(c *gin.Context) {
+			var params models.UserFilterParams
+			if err := c.ShouldBindQuery(&params); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			users, total, err := userService.ListUsers(c.Request.Context(), currentIdentity(c), &params)
+			if err != nil {
+				respondError(c, appLogger, "Users listing failed", err)
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"users":       users,
+				"total_count": total,
+				"page":        params.Page,
+				"page_size":   params.PageSize,
+			})
+		})
+	}
+}
+
+func parseUserID(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userID, true
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimit allows Requests requests per Period, with bursts of up to
+// Requests requests.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseRateLimit parses limits written as "requests/period", e.g. "100/1m".
+func ParseRateLimit(spec string) (RateLimit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: want requests/period", spec)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", spec)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", spec)
+	}
+
+	return RateLimit{Requests: n, Period: d}, nil
+}
+
+// RateLimitResult describes the state of a limit after a request.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long to wait before a rejected request may be
+	// retried.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the full limit is available again.
+	ResetAfter time.Duration
+}
+
+// gcraScript implements the generic cell rate algorithm, a token bucket
+// that needs a single key per client: the key holds the theoretical
+// arrival time (TAT) of the next request, in milliseconds. Redis' clock is
+// used so every API replica agrees on the time. Milliseconds keep the
+// values exact when Lua turns them into strings.
+//
+// KEYS[1] = bucket key, ARGV[1] = emission interval, ARGV[2] = period
+// (both in milliseconds). Returns {allowed, remaining, retry_after,
+// reset_after}, durations in milliseconds.
+var gcraScript = redis.NewScript(`
+local now_parts = redis.call("TIME")
+local now = tonumber(now_parts[1]) * 1000 + math.floor(tonumber(now_parts[2]) / 1000)
+local interval = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - period
+if now < allow_at then
+	return {0, 0, allow_at - now, tat - now}
+end
+
+redis.call("SET", KEYS[1], new_tat, "PX", new_tat - now)
+return {1, math.floor((now - allow_at) / interval), 0, new_tat - now}
+`)
+
+// RateLimiter enforces rate limits shared by every API replica.
+type RateLimiter struct {
+	client *redis.Client
+}
+
+// NewRateLimiter keeps its counters in the Redis instance of redisCache.
+func NewRateLimiter(redisCache *RedisCache) *RateLimiter {
+	return &RateLimiter{client: redisCache.client}
+}
+
+// Allow records a request for key and reports whether it is within limit.
+// Rejected requests are not counted.
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit RateLimit) (*RateLimitResult, error) {
+	period := limit.Period.Milliseconds()
+	interval := period / int64(limit.Requests)
+	if interval < 1 {
+		interval = 1
+	}
+
+	values, err := gcraScript.Run(ctx, l.client, []string{key}, interval, period).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply rate limit: %w", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("failed to apply rate limit: unexpected reply %v", values)
+	}
+
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
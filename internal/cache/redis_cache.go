@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,6 +12,9 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// ErrCacheMiss is returned when a key is not present in Redis.
+var ErrCacheMiss = errors.New("cache miss")
+
 type RedisCache struct {
 	client *redis.Client
 }
@@ -41,4 +45,73 @@ func (c *RedisCache) Get(ctx context.Context, key string) (*models.Product, erro
 	// Get from Redis
 	data, err := c.client.Get(ctx, key).Bytes()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get from")
\ No newline at end of file
+		if err == redis.Nil {
+			return nil, ErrCacheMiss
+		}
+		return nil, fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	// Deserialize product
+	product := &models.Product{}
+	if err := json.Unmarshal(data, product); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal product: %w", err)
+	}
+
+	return product, nil
+}
+
+// SetList caches one page of a product listing.
+func (c *RedisCache) SetList(ctx context.Context, key string, page *models.ProductPage, expiration time.Duration) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return fmt.Errorf("failed to marshal product list: %w", err)
+	}
+
+	return c.client.Set(ctx, key, data, expiration).Err()
+}
+
+func (c *RedisCache) GetList(ctx context.Context, key string) (*models.ProductPage, error) {
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrCacheMiss
+		}
+		return nil, fmt.Errorf("failed to get from cache: %w", err)
+	}
+
+	page := &models.ProductPage{}
+	if err := json.Unmarshal(data, page); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal product list: %w", err)
+	}
+
+	return page, nil
+}
+
+// Delete removes the given keys. Missing keys are not an error.
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// DeleteByPattern removes every key matching a glob pattern. It walks the
+// keyspace with SCAN so it never blocks Redis the way KEYS would.
+func (c *RedisCache) DeleteByPattern(ctx context.Context, pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, err := c.client.Scan(ctx, cursor, pattern, 100).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan cache keys: %w", err)
+		}
+
+		if err := c.Delete(ctx, keys...); err != nil {
+			return fmt.Errorf("failed to delete cache keys: %w", err)
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,37 +19,161 @@ type Config struct {
 	DBPassword string
 	DBName     string
 
+	// MigrateOnStartup applies pending migrations when the API starts.
+	MigrateOnStartup bool
+
 	RedisHost string
 	RedisPort string
 
-	RabbitMQHost string
-	RabbitMQPort string
+	RabbitMQHost     string
+	RabbitMQPort     string
+	RabbitMQUser     string
+	RabbitMQPassword string
+	RabbitMQPrefetch int
+
+	JWTSecret       string
+	JWTIssuer       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// CursorSecret signs product listing cursors; defaults to JWTSecret
+	CursorSecret string
+
+	// Rate limits per route group, as requests/period
+	RateLimitEnabled  bool
+	RateLimitAuth     string
+	RateLimitProducts string
+	RateLimitUsers    string
+
+	// TrustedProxies are the addresses or CIDR ranges whose X-Forwarded-For
+	// header is believed. By default no proxy is, and clients are told apart
+	// by the address they connect from.
+	TrustedProxies []string
+
+	OutboxRelayEnabled   bool
+	OutboxBatchSize      int
+	OutboxPollInterval   time.Duration
+	OutboxRetention      time.Duration
+	OutboxRetryBaseDelay time.Duration
+	OutboxRetryMaxDelay  time.Duration
 
-	S3Bucket string
+	PriceScheduleBatchSize    int
+	PriceSchedulePollInterval time.Duration
+
+	ImageTaskMaxAttempts    int
+	ImageTaskRetryBaseDelay time.Duration
+	ImageTaskRetryMaxDelay  time.Duration
+	ProcessedMessageTTL     time.Duration
+
+	S3Bucket    string
+	S3Endpoint  string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+
+	StorageBackend    string
+	StorageLocalDir   string
+	StoragePublicURL  string
+	StorageSigningKey string
+
+	ImageSourceDir        string
+	ImageRenditions       string
+	ImagePrimaryRendition string
+	ImageQuality          int
+	ImageMaxSourceBytes   int64
+
+	// ExchangeRateBackend is "file" to convert prices at the rates in
+	// ExchangeRateFile, or "none" to only show price list prices
+	ExchangeRateBackend string
+	ExchangeRateFile    string
 }
 
-func LoadConfig() *Config {
+// LoadConfig reads the configuration from the environment and an optional
+// .env file. Numbers and durations that cannot be parsed are reported
+// rather than replaced by their defaults.
+func LoadConfig() (*Config, error) {
 	// Load .env file
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	return &Config{
+	env := &envReader{}
+	cfg := &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
 		DBUser:     getEnv("DB_USER", "productapp"),
 		DBPassword: getEnv("DB_PASSWORD", "securepassword"),
 		DBName:     getEnv("DB_NAME", "productmanagement"),
 
+		MigrateOnStartup: getEnv("MIGRATE_ON_STARTUP", "false") == "true",
+
 		RedisHost: getEnv("REDIS_HOST", "localhost"),
 		RedisPort: getEnv("REDIS_PORT", "6379"),
 
-		RabbitMQHost: getEnv("RABBITMQ_HOST", "localhost"),
-		RabbitMQPort: getEnv("RABBITMQ_PORT", "5672"),
+		RabbitMQHost:     getEnv("RABBITMQ_HOST", "localhost"),
+		RabbitMQPort:     getEnv("RABBITMQ_PORT", "5672"),
+		RabbitMQUser:     getEnv("RABBITMQ_USER", "guest"),
+		RabbitMQPassword: getEnv("RABBITMQ_PASSWORD", "guest"),
+		RabbitMQPrefetch: env.getInt("RABBITMQ_PREFETCH", 4),
+
+		JWTSecret:       getEnv("JWT_SECRET", ""),
+		JWTIssuer:       getEnv("JWT_ISSUER", "product-management"),
+		AccessTokenTTL:  env.getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: env.getDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		CursorSecret: getEnv("CURSOR_SECRET", ""),
+
+		RateLimitEnabled:  getEnv("RATE_LIMIT_ENABLED", "true") == "true",
+		RateLimitAuth:     getEnv("RATE_LIMIT_AUTH", "10/1m"),
+		RateLimitProducts: getEnv("RATE_LIMIT_PRODUCTS", "300/1m"),
+		RateLimitUsers:    getEnv("RATE_LIMIT_USERS", "60/1m"),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+
+		OutboxRelayEnabled:   getEnv("OUTBOX_RELAY_ENABLED", "true") == "true",
+		OutboxBatchSize:      env.getInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxPollInterval:   env.getDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxRetention:      env.getDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		OutboxRetryBaseDelay: env.getDuration("OUTBOX_RETRY_BASE_DELAY", time.Second),
+		OutboxRetryMaxDelay:  env.getDuration("OUTBOX_RETRY_MAX_DELAY", 5*time.Minute),
+
+		PriceScheduleBatchSize:    env.getInt("PRICE_SCHEDULE_BATCH_SIZE", 100),
+		PriceSchedulePollInterval: env.getDuration("PRICE_SCHEDULE_POLL_INTERVAL", 15*time.Second),
+
+		ImageTaskMaxAttempts:    env.getInt("IMAGE_TASK_MAX_ATTEMPTS", 5),
+		ImageTaskRetryBaseDelay: env.getDuration("IMAGE_TASK_RETRY_BASE_DELAY", 10*time.Second),
+		ImageTaskRetryMaxDelay:  env.getDuration("IMAGE_TASK_RETRY_MAX_DELAY", 10*time.Minute),
+		ProcessedMessageTTL:     env.getDuration("PROCESSED_MESSAGE_TTL", 7*24*time.Hour),
+
+		S3Bucket:    getEnv("S3_BUCKET", "product-images"),
+		S3Endpoint:  getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:    getEnv("S3_REGION", "us-east-1"),
+		S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle: getEnv("S3_PATH_STYLE", "false") == "true",
+
+		StorageBackend:    getEnv("STORAGE_BACKEND", "local"),
+		StorageLocalDir:   getEnv("STORAGE_LOCAL_DIR", "data/storage"),
+		StoragePublicURL:  getEnv("STORAGE_PUBLIC_URL", ""),
+		StorageSigningKey: getEnv("STORAGE_SIGNING_KEY", ""),
+
+		ImageSourceDir:        getEnv("IMAGE_SOURCE_DIR", ""),
+		ImageRenditions:       getEnv("IMAGE_RENDITIONS", "thumbnail:200,medium:600,large:1600,medium_webp:600:webp"),
+		ImagePrimaryRendition: getEnv("IMAGE_PRIMARY_RENDITION", "large"),
+		ImageQuality:          env.getInt("IMAGE_QUALITY", 80),
+		ImageMaxSourceBytes:   int64(env.getInt("IMAGE_MAX_SOURCE_BYTES", 20<<20)),
+
+		ExchangeRateBackend: getEnv("EXCHANGE_RATE_BACKEND", "none"),
+		ExchangeRateFile:    getEnv("EXCHANGE_RATE_FILE", "data/exchange_rates.json"),
+	}
 
-		S3Bucket: getEnv("S3_BUCKET", "product-images"),
+	if err := errors.Join(env.errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
+
+	return cfg, nil
 }
 
 func getEnv(key, defaultValue string) string {
@@ -53,4 +182,47 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-}
\ No newline at end of file
+}
+
+// getEnvList splits a comma-separated setting, returning nil when it is
+// unset.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// envReader reads typed settings and collects those that fail to parse.
+type envReader struct {
+	errs []error
+}
+
+func (e *envReader) getInt(key string, defaultValue int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", key, raw))
+		return defaultValue
+	}
+	return value
+}
+
+func (e *envReader) getDuration(key string, defaultValue time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a duration", key, raw))
+		return defaultValue
+	}
+	return value
+}
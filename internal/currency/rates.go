@@ -0,0 +1,183 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"product-management/internal/config"
+	"product-management/internal/models"
+)
+
+// ErrRateUnavailable is returned when no exchange rate between two
+// currencies is known.
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// RateProvider quotes exchange rates for showing prices in other
+// currencies.
+type RateProvider interface {
+	// Rate returns how many units of to one unit of from buys.
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// NewRateProvider builds the provider selected by EXCHANGE_RATE_BACKEND.
+func NewRateProvider(cfg *config.Config) (RateProvider, error) {
+	switch cfg.ExchangeRateBackend {
+	case "none":
+		return &Table{}, nil
+	case "file":
+		return NewFileProvider(cfg.ExchangeRateFile)
+	default:
+		return nil, fmt.Errorf("unknown exchange rate backend %q", cfg.ExchangeRateBackend)
+	}
+}
+
+// Convert converts amount at rate into minor units of the target currency,
+// which has exponent fractional digits, rounding half away from zero.
+func Convert(amount models.Amount, rate *big.Rat, exponent int) int64 {
+	// amount is in hundredths
+	scale := new(big.Rat).SetFrac(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil), big.NewInt(100))
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(amount)), rate)
+	r.Mul(r, scale)
+
+	// round(|n/d|) = floor((2|n| + d) / 2d)
+	n := new(big.Int).Lsh(new(big.Int).Abs(r.Num()), 1)
+	n.Add(n, r.Denom())
+	n.Quo(n, new(big.Int).Lsh(r.Denom(), 1))
+	if r.Sign() < 0 {
+		n.Neg(n)
+	}
+
+	return n.Int64()
+}
+
+// Table holds the rates of every currency against one base currency, so
+// any two of them convert through the base. The zero Table only converts
+// a currency into itself.
+type Table struct {
+	Base  string
+	Rates map[string]*big.Rat
+}
+
+func (t *Table) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	fromRate, ok := t.against(from)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRateUnavailable, from)
+	}
+	toRate, ok := t.against(to)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRateUnavailable, to)
+	}
+
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// against returns how many units of code one unit of the base buys.
+func (t *Table) against(code string) (*big.Rat, bool) {
+	if code == t.Base && code != "" {
+		return big.NewRat(1, 1), true
+	}
+	rate, ok := t.Rates[code]
+	return rate, ok
+}
+
+// rateFile is the format of FileProvider files, e.g.
+//
+//	{"base": "USD", "rates": {"EUR": "0.9215", "JPY": 149.32}}
+//
+// Rates may be JSON numbers or strings; both are parsed exactly.
+type rateFile struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// FileProvider reads rates from a JSON file and rereads it whenever it
+// changes, so rates can be updated without a restart. It stands in for a
+// rates feed in development and tests.
+type FileProvider struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	table   *Table
+}
+
+// NewFileProvider loads the rates in path.
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if _, err := p.current(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileProvider) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	table, err := p.current()
+	if err != nil {
+		return nil, err
+	}
+	return table.Rate(ctx, from, to)
+}
+
+// current returns the rates, reloading the file if it changed since it
+// was last read.
+func (p *FileProvider) current() (*Table, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat exchange rates: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.table != nil && info.ModTime().Equal(p.modTime) {
+		return p.table, nil
+	}
+
+	table, err := loadTable(p.path)
+	if err != nil {
+		return nil, err
+	}
+	p.table, p.modTime = table, info.ModTime()
+
+	return table, nil
+}
+
+func loadTable(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates: %w", err)
+	}
+
+	var file rateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates: %w", err)
+	}
+
+	table := &Table{
+		Base:  strings.ToUpper(file.Base),
+		Rates: make(map[string]*big.Rat, len(file.Rates)),
+	}
+	if table.Base == "" {
+		return nil, fmt.Errorf("exchange rates in %s have no base currency", path)
+	}
+	for code, value := range file.Rates {
+		rate, ok := new(big.Rat).SetString(value.String())
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q for %s", value, code)
+		}
+		table.Rates[strings.ToUpper(code)] = rate
+	}
+
+	return table, nil
+}
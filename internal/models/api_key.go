@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Scopes an API key can be granted.
+const (
+	ScopeProductsRead    = "products:read"
+	ScopeProductsWrite   = "products:write"
+	ScopeImagesReprocess = "images:reprocess"
+	ScopeInventoryWrite  = "inventory:write"
+)
+
+// APIKey is a long-lived credential acting as its owner, limited to its
+// scopes. The key itself is only shown once, when it is created.
+type APIKey struct {
+	ID     int64  `json:"id" db:"id"`
+	UserID int64  `json:"user_id" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+	// Prefix is the start of the key, to tell keys apart in listings.
+	Prefix  string   `json:"prefix" db:"prefix"`
+	KeyHash string   `json:"-" db:"key_hash"`
+	Scopes  []string `json:"scopes" db:"scopes"`
+	// RateLimit is in requests per minute; nil uses the default limit.
+	RateLimit  *int       `json:"rate_limit,omitempty" db:"rate_limit"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// APIKeyCreateRequest is the body of POST /api-keys.
+type APIKeyCreateRequest struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Scopes    []string `json:"scopes" validate:"required,min=1,dive,oneof=products:read products:write images:reprocess inventory:write"`
+	RateLimit *int     `json:"rate_limit,omitempty" validate:"omitempty,min=1"`
+}
+
+// CreatedAPIKey is returned once, when a key is created.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
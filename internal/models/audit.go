@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited entity types.
+const (
+	AuditEntityProduct = "product"
+	AuditEntityUser    = "user"
+	// AuditEntityImage is the rendered images of a product; the entity ID
+	// is the product's.
+	AuditEntityImage = "image"
+)
+
+// Audited actions.
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// RequestInfo describes the HTTP request a change was made in.
+type RequestInfo struct {
+	RequestID string
+	SourceIP  string
+}
+
+// AuditEntry records one change to an entity. Changes maps every field
+// that changed to its value before and after, which is null for fields of
+// created and deleted entities. ActorID is nil for changes made by the
+// system, such as the image worker, or by a user signing up.
+type AuditEntry struct {
+	ID         int64           `json:"id" db:"id"`
+	EntityType string          `json:"entity_type" db:"entity_type"`
+	EntityID   int64           `json:"entity_id" db:"entity_id"`
+	Action     string          `json:"action" db:"action"`
+	ActorID    *int64          `json:"actor_id,omitempty" db:"actor_id"`
+	APIKeyID   *int64          `json:"api_key_id,omitempty" db:"api_key_id"`
+	RequestID  string          `json:"request_id,omitempty" db:"request_id"`
+	SourceIP   string          `json:"source_ip,omitempty" db:"source_ip"`
+	Changes    json.RawMessage `json:"changes" db:"changes"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditChange is the value of a field before and after a change. Before is
+// omitted for fields that did not exist, After for fields that no longer do.
+type AuditChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditFilterParams are the query parameters of GET /audit.
+type AuditFilterParams struct {
+	EntityType string     `json:"entity_type" form:"entity_type" validate:"omitempty,oneof=product user image"`
+	EntityID   int64      `json:"entity_id" form:"entity_id"`
+	ActorID    int64      `json:"actor_id" form:"actor_id"`
+	From       *time.Time `json:"from" form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         *time.Time `json:"to" form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page       int        `json:"page" form:"page"`
+	PageSize   int        `json:"page_size" form:"page_size" validate:"max=100"`
+}
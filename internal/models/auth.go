@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// TokenPair is returned by login and refresh.
+type TokenPair struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the access token lifetime in seconds.
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken is the server-side record of an issued refresh token.
+type RefreshToken struct {
+	ID        int64      `db:"id"`
+	UserID    int64      `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	FamilyID  string     `db:"family_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	UserID int64  `json:"user_id"`
+	Role   string `json:"role"`
+	// APIKeyID is set when the caller authenticated with an API key rather
+	// than as the user.
+	APIKeyID int64 `json:"api_key_id,omitempty"`
+	// Scopes limit what an API key may do. Users are not limited by scopes.
+	Scopes []string `json:"scopes,omitempty"`
+	// RateLimit is the API key's own limit in requests per minute, if any.
+	RateLimit int `json:"-"`
+}
+
+// IsAdmin reports whether the caller may act on any resource.
+func (i *Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+// IsAPIKey reports whether the caller authenticated with an API key.
+func (i *Identity) IsAPIKey() bool {
+	return i.APIKeyID != 0
+}
+
+// HasScope reports whether the caller may use scope. Users have every
+// scope; API keys only those they were granted.
+func (i *Identity) HasScope(scope string) bool {
+	if !i.IsAPIKey() {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Category is a node of the product taxonomy. Categories without a parent
+// are roots.
+type Category struct {
+	ID       int64  `json:"id" db:"id"`
+	ParentID *int64 `json:"parent_id" db:"parent_id"`
+	Name     string `json:"name" db:"name"`
+	Slug     string `json:"slug" db:"slug"`
+	// Path is the materialized path of IDs from the root, e.g. "/1/4/9/".
+	Path string `json:"-" db:"path"`
+	// Depth is 0 for roots.
+	Depth     int       `json:"depth" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CategoryRef names a category, e.g. as one step of a breadcrumb.
+type CategoryRef struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// ProductCategory is a category a product is assigned to, with the
+// breadcrumb leading to it from its root, the category itself included.
+type ProductCategory struct {
+	CategoryRef
+	Breadcrumbs []CategoryRef `json:"breadcrumbs"`
+}
+
+// CategoryRequest is the body of POST and PUT /categories. Moving a
+// category to another parent moves its whole subtree.
+type CategoryRequest struct {
+	ParentID *int64 `json:"parent_id" validate:"omitempty,min=1"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Slug     string `json:"slug" validate:"required,max=100"`
+}
+
+// ProductCategoriesRequest is the body of PUT /products/:id/categories and
+// replaces every assignment of the product.
+type ProductCategoriesRequest struct {
+	CategoryIDs []int64 `json:"category_ids" validate:"max=20,dive,min=1"`
+}
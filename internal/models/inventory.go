@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// Warehouse is a place stock is kept in.
+type Warehouse struct {
+	ID        int64     `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// WarehouseCreateRequest is the body of POST /warehouses.
+type WarehouseCreateRequest struct {
+	Code string `json:"code" validate:"required,max=32,alphanum"`
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// InventoryLevel is the stock of a product, or of one of its variants, in
+// one warehouse.
+type InventoryLevel struct {
+	ID          int64  `json:"id" db:"id"`
+	ProductID   int64  `json:"product_id" db:"product_id"`
+	VariantID   *int64 `json:"variant_id" db:"variant_id"`
+	WarehouseID int64  `json:"warehouse_id" db:"warehouse_id"`
+	OnHand      int    `json:"on_hand" db:"on_hand"`
+	// Reserved units are held for orders that are not finished yet.
+	Reserved          int       `json:"reserved" db:"reserved"`
+	LowStockThreshold *int      `json:"low_stock_threshold" db:"low_stock_threshold"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Available is the stock that can still be reserved.
+func (l *InventoryLevel) Available() int {
+	return l.OnHand - l.Reserved
+}
+
+// Stock movement kinds.
+const (
+	MovementAdjust  = "adjust"
+	MovementReserve = "reserve"
+	MovementCommit  = "commit"
+	MovementRelease = "release"
+)
+
+// StockMovement is an entry of the append-only stock ledger.
+type StockMovement struct {
+	ID            int64     `json:"id" db:"id"`
+	InventoryID   int64     `json:"inventory_id" db:"inventory_id"`
+	Kind          string    `json:"kind" db:"kind"`
+	OnHandDelta   int       `json:"on_hand_delta" db:"on_hand_delta"`
+	ReservedDelta int       `json:"reserved_delta" db:"reserved_delta"`
+	ReservationID *int64    `json:"reservation_id,omitempty" db:"reservation_id"`
+	Reason        string    `json:"reason,omitempty" db:"reason"`
+	ActorID       *int64    `json:"actor_id,omitempty" db:"actor_id"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// Reservation statuses. Pending reservations are either committed, when
+// the units ship, or released.
+const (
+	ReservationPending   = "pending"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+// StockReservation holds units of an inventory level for an order.
+type StockReservation struct {
+	ID          int64  `json:"id" db:"id"`
+	InventoryID int64  `json:"inventory_id" db:"inventory_id"`
+	ProductID   int64  `json:"product_id" db:"-"`
+	Quantity    int    `json:"quantity" db:"quantity"`
+	Status      string `json:"status" db:"status"`
+	Reference   string `json:"reference,omitempty" db:"reference"`
+	// Level is the inventory level after the last change of the
+	// reservation.
+	Level     *InventoryLevel `json:"level,omitempty" db:"-"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// StockAdjustRequest is the body of POST /products/:id/inventory/adjust.
+// Delta is added to the units on hand, e.g. +20 for a delivery or -1 for
+// a damaged unit.
+type StockAdjustRequest struct {
+	VariantID   *int64 `json:"variant_id" validate:"omitempty,min=1"`
+	WarehouseID int64  `json:"warehouse_id" validate:"required,min=1"`
+	Delta       int    `json:"delta" validate:"min=-1000000,max=1000000"`
+	Reason      string `json:"reason" validate:"required,max=255"`
+	// LowStockThreshold replaces the threshold when set.
+	LowStockThreshold *int `json:"low_stock_threshold" validate:"omitempty,min=0"`
+}
+
+// StockReserveRequest is the body of POST /products/:id/inventory/reservations.
+type StockReserveRequest struct {
+	VariantID   *int64 `json:"variant_id" validate:"omitempty,min=1"`
+	WarehouseID int64  `json:"warehouse_id" validate:"required,min=1"`
+	Quantity    int    `json:"quantity" validate:"required,min=1,max=1000000"`
+	Reference   string `json:"reference" validate:"max=100"`
+}
+
+// InventoryParams select the stock of a product to look at.
+type InventoryParams struct {
+	VariantID   int64 `form:"variant_id" validate:"min=0"`
+	WarehouseID int64 `form:"warehouse_id" validate:"min=0"`
+}
+
+// StockMovementParams page through a product's stock ledger, newest first.
+type StockMovementParams struct {
+	InventoryParams
+	Page     int `form:"page"`
+	PageSize int `form:"page_size" validate:"max=100"`
+}
@@ -0,0 +1,233 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is the currency of products created without one.
+const DefaultCurrency = "USD"
+
+// Amount is an exact amount of money in hundredths of the currency unit,
+// the scale of the DECIMAL(10,2) price columns. It never passes through a
+// float: JSON, query parameters and the database carry it as a decimal
+// like 19.99.
+type Amount int64
+
+// ParseAmount parses a plain decimal with at most two fractional digits,
+// such as "19", "19.9" or "-0.99".
+func ParseAmount(s string) (Amount, error) {
+	digits := strings.TrimPrefix(s, "-")
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" || len(frac) > 2 || !isDigits(whole) || !isDigits(frac) || len(whole) > 16 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	units, err := strconv.ParseInt(whole+(frac + "00")[:2], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(digits) < len(s) {
+		units = -units
+	}
+
+	return Amount(units), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with exactly two fractional digits.
+func (a Amount) String() string {
+	sign := ""
+	units := int64(a)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/100, units%100)
+}
+
+// MarshalJSON writes the amount as a JSON number with two fractional
+// digits.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string holding one.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	text := string(data)
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	amount, err := ParseAmount(text)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// UnmarshalParam binds query and form parameters.
+func (a *Amount) UnmarshalParam(param string) error {
+	amount, err := ParseAmount(param)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// Scan reads a DECIMAL column, which lib/pq returns as text.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return a.UnmarshalParam(string(v))
+	case string:
+		return a.UnmarshalParam(v)
+	case int64:
+		*a = Amount(v * 100)
+	case float64:
+		// Only drivers without a decimal type report floats
+		*a = Amount(math.Round(v * 100))
+	default:
+		return fmt.Errorf("cannot scan %T into Amount", src)
+	}
+	return nil
+}
+
+// Value passes the amount as a decimal string, which Postgres casts to
+// NUMERIC without loss.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Price sources of a DisplayPrice.
+const (
+	// PriceSourceBase is the product's own price.
+	PriceSourceBase = "base"
+	// PriceSourceList is a price set for the currency in the product's
+	// price list.
+	PriceSourceList = "price_list"
+	// PriceSourceExchangeRate is the product's price converted at the
+	// current exchange rate.
+	PriceSourceExchangeRate = "exchange_rate"
+)
+
+// DisplayPrice is a product's price in the currency a client asked for.
+type DisplayPrice struct {
+	// Amount is in the minor unit of Currency: cents for USD, yen for JPY,
+	// fils for KWD.
+	Amount   int64
+	Currency string
+	Source   string
+}
+
+// MarshalJSON writes the amount as a JSON number with as many fractional
+// digits as the currency has.
+func (p DisplayPrice) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   json.Number `json:"amount"`
+		Currency string      `json:"currency"`
+		Source   string      `json:"source"`
+	}{
+		Amount:   json.Number(FormatMinorUnits(p.Amount, CurrencyExponent(p.Currency))),
+		Currency: p.Currency,
+		Source:   p.Source,
+	})
+}
+
+// currencyExponents lists the ISO 4217 currencies whose minor unit is not
+// a hundredth of the major unit.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// CurrencyExponent returns the number of fractional digits of a currency
+// under ISO 4217: 0 for JPY, 2 for USD, 3 for KWD.
+func CurrencyExponent(code string) int {
+	if exponent, ok := currencyExponents[code]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// InMinorUnits returns the amount in minor units of a currency with the
+// given exponent, rounding half away from zero where it has fewer than
+// two fractional digits.
+func (a Amount) InMinorUnits(exponent int) int64 {
+	units := int64(a)
+	for ; exponent > 2; exponent-- {
+		units *= 10
+	}
+	if exponent == 2 {
+		return units
+	}
+
+	divisor := int64(1)
+	for ; exponent < 2; exponent++ {
+		divisor *= 10
+	}
+	if units < 0 {
+		return -((-units + divisor/2) / divisor)
+	}
+	return (units + divisor/2) / divisor
+}
+
+// FormatMinorUnits formats an amount in minor units as a plain decimal
+// with exponent fractional digits.
+func FormatMinorUnits(units int64, exponent int) string {
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	digits := strconv.FormatInt(units, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// CurrencyPrice is an entry of a product's price list: a fixed regular
+// price in a currency other than the product's own, used instead of
+// converting while the product is not on sale.
+type CurrencyPrice struct {
+	ProductID int64     `json:"product_id" db:"product_id"`
+	Currency  string    `json:"currency" db:"currency"`
+	Amount    Amount    `json:"amount" db:"amount"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CurrencyPriceRequest is the body of PUT /products/:id/prices/:currency.
+type CurrencyPriceRequest struct {
+	Amount *Amount `json:"amount" validate:"required,min=0,max=9999999999"`
+}
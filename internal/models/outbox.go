@@ -0,0 +1,21 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxMessage is a message waiting in the outbox table to be published.
+type OutboxMessage struct {
+	ID            int64           `json:"id" db:"id"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id" db:"aggregate_id"`
+	EventType     string          `json:"event_type" db:"event_type"`
+	Exchange      string          `json:"exchange" db:"exchange"`
+	RoutingKey    string          `json:"routing_key" db:"routing_key"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	LastError     string          `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty" db:"published_at"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Reasons of price changes.
+const (
+	PriceChangeCreated     = "created"
+	PriceChangeUpdated     = "updated"
+	PriceChangeSaleStarted = "sale_started"
+	PriceChangeSaleEnded   = "sale_ended"
+)
+
+// PriceHistoryWindow is the period price statistics cover by default.
+const PriceHistoryWindow = 30 * 24 * time.Hour
+
+// PriceChange records a change of the price a product sells for. OldPrice
+// is nil when there was no earlier price in Currency.
+type PriceChange struct {
+	ID         int64     `json:"id" db:"id"`
+	ProductID  int64     `json:"product_id" db:"product_id"`
+	Currency   string    `json:"currency" db:"currency"`
+	OldPrice   *Amount   `json:"old_price" db:"old_price"`
+	NewPrice   Amount    `json:"new_price" db:"new_price"`
+	ChangedBy  *int64    `json:"changed_by,omitempty" db:"changed_by"`
+	Reason     string    `json:"reason" db:"reason"`
+	ScheduleID *int64    `json:"schedule_id,omitempty" db:"schedule_id"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// PriceHistoryParams selects the period of GET /products/:id/price-history.
+// It defaults to the PriceHistoryWindow up to now.
+type PriceHistoryParams struct {
+	From *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To   *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// PriceStats sums up the prices a product sold for in a period, in its
+// current currency. Average weighs every price by how long it applied.
+// The price in effect when the period began counts as well, so Min is the
+// lowest price of the period even if it was set before. They are nil if
+// the product had no price in the period.
+type PriceStats struct {
+	Currency string    `json:"currency"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Min      *Amount   `json:"min"`
+	Max      *Amount   `json:"max"`
+	Average  *Amount   `json:"average"`
+}
+
+// PriceHistory is the response of GET /products/:id/price-history.
+type PriceHistory struct {
+	Changes []PriceChange `json:"changes"`
+	Stats   PriceStats    `json:"stats"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// PriceSchedule sells a product at SalePrice from StartsAt until EndsAt.
+// The schedules of a product never overlap.
+type PriceSchedule struct {
+	ID        int64     `json:"id" db:"id"`
+	ProductID int64     `json:"product_id" db:"product_id"`
+	SalePrice Amount    `json:"sale_price" db:"sale_price"`
+	StartsAt  time.Time `json:"starts_at" db:"starts_at"`
+	EndsAt    time.Time `json:"ends_at" db:"ends_at"`
+	Label     string    `json:"label,omitempty" db:"label"`
+	CreatedBy *int64    `json:"created_by,omitempty" db:"created_by"`
+	// StartPublishedAt and EndPublishedAt record when the price changes at
+	// either end were announced.
+	StartPublishedAt *time.Time `json:"-" db:"start_published_at"`
+	EndPublishedAt   *time.Time `json:"-" db:"end_published_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ActiveAt reports whether the schedule sets the price at t.
+func (s *PriceSchedule) ActiveAt(t time.Time) bool {
+	return !t.Before(s.StartsAt) && t.Before(s.EndsAt)
+}
+
+// PriceScheduleRequest is the body of POST /products/:id/price-schedules.
+type PriceScheduleRequest struct {
+	SalePrice *Amount   `json:"sale_price" validate:"required,min=0,max=9999999999"`
+	StartsAt  time.Time `json:"starts_at" validate:"required"`
+	EndsAt    time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+	Label     string    `json:"label" validate:"max=100"`
+}
@@ -6,31 +6,223 @@ import (
 	"github.com/lib/pq"
 )
 
+// Product is an item a seller offers. EffectivePrice is what it sells for
+// at the moment: the sale price of the active Sale, else ProductPrice.
 type Product struct {
-	ID                    int64         `json:"id" db:"id"`
-	UserID                int64         `json:"user_id" db:"user_id"`
-	ProductName           string        `json:"product_name" db:"product_name"`
-	ProductDescription    string        `json:"product_description" db:"product_description"`
-	ProductPrice          float64       `json:"product_price" db:"product_price"`
-	ProductImages         pq.StringArray `json:"product_images" db:"product_images"`
-	CompressedProductImages pq.StringArray `json:"compressed_product_images" db:"compressed_product_images"`
-	CreatedAt             time.Time     `json:"created_at" db:"created_at"`
-	UpdatedAt             time.Time     `json:"updated_at" db:"updated_at"`
+	ID                      int64             `json:"id" db:"id"`
+	UserID                  int64             `json:"user_id" db:"user_id"`
+	ProductName             string            `json:"product_name" db:"product_name"`
+	ProductDescription      string            `json:"product_description" db:"product_description"`
+	ProductPrice            Amount            `json:"product_price" db:"product_price"`
+	Currency                string            `json:"currency" db:"currency"`
+	ProductImages           pq.StringArray    `json:"product_images" db:"product_images"`
+	CompressedProductImages pq.StringArray    `json:"-" db:"compressed_product_images"`
+	Tags                    pq.StringArray    `json:"tags" db:"tags"`
+	Images                  []ProductImage    `json:"images,omitempty" db:"-"`
+	Categories              []ProductCategory `json:"categories,omitempty" db:"-"`
+	Variants                []ProductVariant  `json:"variants,omitempty" db:"-"`
+	EffectivePrice          Amount            `json:"effective_price" db:"-"`
+	Sale                    *PriceSchedule    `json:"sale,omitempty" db:"-"`
+	DisplayPrice            *DisplayPrice     `json:"display_price,omitempty" db:"-"`
+	CreatedAt               time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt               time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// ImageVariant is one rendition (thumbnail, medium, ...) of a product image.
+type ImageVariant struct {
+	ProductID   int64  `json:"-" db:"product_id"`
+	ImageIndex  int    `json:"-" db:"image_index"`
+	SourceImage string `json:"-" db:"source_image"`
+	SourceHash  string `json:"-" db:"source_hash"`
+	Name        string `json:"-" db:"variant"`
+	URL         string `json:"url" db:"url"`
+	Format      string `json:"format" db:"format"`
+	Width       int    `json:"width" db:"width"`
+	Height      int    `json:"height" db:"height"`
+	SizeBytes   int64  `json:"size_bytes" db:"size_bytes"`
+}
+
+// ProductImage groups the renditions generated from one source image,
+// keyed by rendition name.
+type ProductImage struct {
+	Source   string                  `json:"source"`
+	Variants map[string]ImageVariant `json:"variants"`
 }
 
 type ProductCreateRequest struct {
-	UserID             int64    `json:"user_id" validate:"required"`
-	ProductName        string   `json:"product_name" validate:"required,max=255"`
-	ProductDescription string   `json:"product_description"`
-	ProductPrice       float64  `json:"product_price" validate:"required,min=0"`
-	ProductImages      []string `json:"product_images" validate:"required"`
+	// UserID is the authenticated caller, never taken from the body.
+	UserID             int64  `json:"-" validate:"required"`
+	ProductName        string `json:"product_name" validate:"required,max=255"`
+	ProductDescription string `json:"product_description"`
+	ProductPrice       Amount `json:"product_price" validate:"required,min=0,max=9999999999"`
+	// Currency is an ISO 4217 code and defaults to DefaultCurrency.
+	Currency      string   `json:"currency" validate:"omitempty,iso4217"`
+	ProductImages []string `json:"product_images" validate:"required"`
+	Tags          []string `json:"tags" validate:"max=20,dive,required,max=50"`
 }
 
+// ProductUpdateRequest carries the editable fields of a product. It is the
+// full replacement body for PUT and the target document for PATCH.
+type ProductUpdateRequest struct {
+	ProductName        string `json:"product_name" validate:"required,max=255"`
+	ProductDescription string `json:"product_description"`
+	ProductPrice       Amount `json:"product_price" validate:"required,min=0,max=9999999999"`
+	// Currency is an ISO 4217 code; left empty, the current one is kept.
+	Currency      string   `json:"currency" validate:"omitempty,iso4217"`
+	ProductImages []string `json:"product_images" validate:"required"`
+	Tags          []string `json:"tags" validate:"max=20,dive,required,max=50"`
+}
+
+// Sort keys and orders of product listings.
+const (
+	SortPrice     = "price"
+	SortCreatedAt = "created_at"
+	SortName      = "name"
+	// SortRelevance ranks full-text matches and requires a query.
+	SortRelevance = "relevance"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// ProductFilterParams are the query parameters of the catalog listing.
+// Every filter is optional; without any, every seller's products are
+// listed.
 type ProductFilterParams struct {
-	UserID      int64   `json:"user_id"`
-	MinPrice    float64 `json:"min_price"`
-	MaxPrice    float64 `json:"max_price"`
-	ProductName string  `json:"product_name"`
-	Page        int     `json:"page"`
-	PageSize    int     `json:"page_size"`
-}
\ No newline at end of file
+	// UserID limits the listing to one seller.
+	UserID int64 `json:"user_id" form:"user_id"`
+	// Price filters, sorting and facets compare the products' regular
+	// prices, whatever their currency and sales.
+	MinPrice    Amount `json:"min_price" form:"min_price"`
+	MaxPrice    Amount `json:"max_price" form:"max_price"`
+	ProductName string `json:"product_name" form:"product_name"`
+	// Query is a full-text query in web search syntax.
+	Query string `json:"q" form:"q" validate:"required_if=Sort relevance,max=200"`
+	// Date ranges include their start and exclude their end.
+	CreatedAfter        *time.Time `json:"created_after" form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore       *time.Time `json:"created_before" form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedAfter        *time.Time `json:"updated_after" form:"updated_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedBefore       *time.Time `json:"updated_before" form:"updated_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	HasCompressedImages *bool      `json:"has_compressed_images" form:"has_compressed_images"`
+	// Tags only matches products carrying every one of them.
+	Tags []string `json:"tags" form:"tag" validate:"max=10,dive,max=50"`
+	// CategoryID matches products assigned to the category, or to any
+	// category below it with IncludeDescendants.
+	CategoryID         int64 `json:"category_id" form:"category_id" validate:"min=0"`
+	IncludeDescendants bool  `json:"include_descendants" form:"include_descendants"`
+	// Attributes matches products with a variant having all of them. It is
+	// bound from attr[size]=M&attr[color]=red by the route.
+	Attributes map[string]string `json:"attributes" form:"-" validate:"max=10"`
+	// Facets adds counts per tag, category and price bucket of every
+	// matching product to the page. PriceBuckets are the bucket bounds and
+	// default to DefaultPriceBuckets; the route binds them from
+	// price_bucket, since form binding cannot parse slices of amounts.
+	Facets       bool     `json:"facets" form:"facets"`
+	PriceBuckets []Amount `json:"price_buckets" form:"-" validate:"max=20,dive,min=0"`
+	// Sort defaults to relevance with a query and created_at otherwise.
+	Sort string `json:"sort" form:"sort" validate:"omitempty,oneof=price created_at name relevance"`
+	// Order defaults to desc for relevance and created_at, asc otherwise.
+	Order string `json:"order" form:"order" validate:"omitempty,oneof=asc desc"`
+	// Cursor continues a listing from the next_cursor or prev_cursor of an
+	// earlier page and takes precedence over Page.
+	Cursor string `json:"cursor" form:"cursor"`
+	// Count is exact (default), estimated, or none to skip counting.
+	Count    string `json:"count" form:"count" validate:"omitempty,oneof=exact estimated none"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size" validate:"max=100"`
+
+	// After is the decoded Cursor.
+	After *ProductCursor `json:"-" form:"-"`
+}
+
+// DefaultPriceBuckets are the bounds of the price facet unless a listing
+// asks for others.
+var DefaultPriceBuckets = []Amount{10_00, 25_00, 50_00, 100_00, 250_00, 500_00, 1000_00}
+
+// Count modes of product listings.
+const (
+	CountExact     = "exact"
+	CountEstimated = "estimated"
+	CountNone      = "none"
+)
+
+// ProductCursor marks a position in a sorted product listing: the sort key
+// and ID of the product next to it. Clients only see it signed and encoded.
+type ProductCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	// Key is the sort key as Postgres prints it, so it compares exactly.
+	Key string `json:"k"`
+	ID  int64  `json:"i"`
+	// Backward pages towards the start of the listing.
+	Backward bool `json:"b,omitempty"`
+	// Filters fingerprints the filters, so a cursor is only used with the
+	// listing it came from.
+	Filters string `json:"f"`
+}
+
+// ProductPage is one page of a product listing.
+type ProductPage struct {
+	Products []Product `json:"products"`
+	// TotalCount is nil when counting was skipped.
+	TotalCount     *int   `json:"total_count"`
+	CountEstimated bool   `json:"count_estimated,omitempty"`
+	NextCursor     string `json:"next_cursor,omitempty"`
+	PrevCursor     string `json:"prev_cursor,omitempty"`
+	// Facets is only computed on request.
+	Facets *ProductFacets `json:"facets,omitempty"`
+
+	// Next and Prev are the unsigned cursors of the neighbouring pages.
+	Next *ProductCursor `json:"-"`
+	Prev *ProductCursor `json:"-"`
+}
+
+// ProductSearchParams are the query parameters of GET /products/search.
+type ProductSearchParams struct {
+	// Query uses web search syntax: quoted phrases, "or" and -exclusions.
+	Query string `json:"q" form:"q" validate:"required,max=200"`
+	// Fuzzy also matches names similar to the query, to tolerate typos.
+	Fuzzy    bool `json:"fuzzy" form:"fuzzy"`
+	Page     int  `json:"page" form:"page"`
+	PageSize int  `json:"page_size" form:"page_size" validate:"max=100"`
+}
+
+// ProductSearchResult is a product matching a search, best matches first.
+type ProductSearchResult struct {
+	Product
+	Rank float64 `json:"rank"`
+	// Snippet is an HTML-escaped excerpt with the matched words wrapped in
+	// <mark> elements.
+	Snippet string `json:"snippet"`
+}
+
+// ProductFacets count the products matching a listing's filters, across
+// all its pages, by tag, category and price range.
+type ProductFacets struct {
+	// Tags are the most used tags, most used first.
+	Tags []TagFacet `json:"tags"`
+	// Categories count each category with its subcategories, depth first.
+	Categories []CategoryFacet `json:"categories"`
+	Prices     []PriceFacet    `json:"prices"`
+}
+
+type TagFacet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type CategoryFacet struct {
+	ID       int64  `json:"id"`
+	ParentID *int64 `json:"parent_id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Count    int    `json:"count"`
+}
+
+// PriceFacet counts the prices in [Min, Max). The lowest bucket has no Min
+// and the highest no Max.
+type PriceFacet struct {
+	Min   *Amount `json:"min"`
+	Max   *Amount `json:"max"`
+	Count int     `json:"count"`
+}
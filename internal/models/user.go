@@ -1,7 +1,45 @@
 package models
 
+import "time"
+
+// Roles a user can have.
+const (
+	RoleAdmin  = "admin"
+	RoleSeller = "seller"
+	RoleViewer = "viewer"
+)
+
 type User struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID       int64  `json:"id" db:"id"`
+	Username string `json:"username" db:"username"`
+	Email    string `json:"email" db:"email"`
+	Role     string `json:"role" db:"role"`
+	// PasswordHash is a bcrypt hash; users without one cannot log in.
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// UserCreateRequest is the body of POST /users.
+type UserCreateRequest struct {
+	Username string `json:"username" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	// bcrypt ignores everything past 72 bytes
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// UserUpdateRequest is the full replacement body of PUT /users/:id.
+type UserUpdateRequest struct {
+	Username string `json:"username" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	// Password is changed only when set.
+	Password string `json:"password,omitempty" validate:"omitempty,min=8,max=72"`
+	// Role is changed only when set, and only by admins.
+	Role string `json:"role,omitempty" validate:"omitempty,oneof=admin seller viewer"`
+}
+
+type UserFilterParams struct {
+	Email    string `json:"email" form:"email"`
+	Page     int    `json:"page" form:"page"`
+	PageSize int    `json:"page_size" form:"page_size" validate:"max=100"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ProductVariant is a sellable version of a product, e.g. one size and
+// color, identified by its SKU.
+type ProductVariant struct {
+	ID        int64  `json:"id" db:"id"`
+	ProductID int64  `json:"product_id" db:"product_id"`
+	SKU       string `json:"sku" db:"sku"`
+	// Attributes tell the variants of a product apart, e.g. size and color.
+	Attributes map[string]string `json:"attributes" db:"attributes"`
+	// Price overrides the product price, in the product's currency; nil
+	// sells at the product price.
+	Price       *Amount   `json:"price" db:"price"`
+	Barcode     string    `json:"barcode,omitempty" db:"barcode"`
+	WeightGrams *int      `json:"weight_grams,omitempty" db:"weight_grams"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ProductVariantRequest is the body of POST and PUT
+// /products/:id/variants. SKUs are unique across every product.
+type ProductVariantRequest struct {
+	SKU         string            `json:"sku" validate:"required,max=64,printascii"`
+	Attributes  map[string]string `json:"attributes" validate:"max=10,dive,keys,required,max=50,endkeys,required,max=100"`
+	Price       *Amount           `json:"price" validate:"omitempty,min=0,max=9999999999"`
+	Barcode     string            `json:"barcode" validate:"omitempty,max=32,numeric"`
+	WeightGrams *int              `json:"weight_grams" validate:"omitempty,min=0"`
+}
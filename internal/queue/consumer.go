@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// ImageTaskHandler processes one image task. Returning an error schedules
+// the task for a retry, or dead-letters it once attempts are exhausted.
+type ImageTaskHandler func(ctx context.Context, task *ImageProcessingTask) error
+
+// ConsumeChannel is the part of an AMQP channel the consumer reads
+// deliveries from.
+type ConsumeChannel interface {
+	ConsumeWithContext(ctx context.Context, queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp091.Table) (<-chan amqp091.Delivery, error)
+}
+
+// RabbitMQConsumer consumes image tasks with manual acknowledgements. At
+// most prefetch unacknowledged messages are delivered at a time, and as many
+// are handled concurrently.
+type RabbitMQConsumer struct {
+	conn      *amqp091.Connection
+	ch        ConsumeChannel
+	republish Publisher
+	retry     RetryPolicy
+	workers   int
+}
+
+// NewConsumer returns a consumer reading image tasks from ch with up to
+// workers handlers at a time, publishing retries and dead letters through
+// republish. The topology must already be declared.
+func NewConsumer(ch ConsumeChannel, republish Publisher, retry RetryPolicy, workers int) *RabbitMQConsumer {
+	return &RabbitMQConsumer{ch: ch, republish: republish, retry: retry, workers: workers}
+}
+
+func NewRabbitMQConsumer(rabbitMQURL string, prefetch int, retry RetryPolicy) (*RabbitMQConsumer, error) {
+	conn, err := ConnectRabbitMQ(rabbitMQURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := declareImageTopology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := declareRetryTopology(ch, retry); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	// Retries and dead letters are published on their own confirm-mode
+	// channel so the original is only acked once its copy is safe
+	publishCh, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	republish, err := newConfirmChannel(publishCh)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	consumer := NewConsumer(ch, republish, retry, prefetch)
+	consumer.conn = conn
+	return consumer, nil
+}
+
+// ConsumeImageProcessingTasks delivers image tasks to handler until ctx is
+// cancelled or the channel closes. Successfully handled messages are acked.
+// Failed messages are retried with exponential backoff and dead-lettered
+// once the retry policy is exhausted. Messages that can never succeed
+// (undecodable or of an unknown schema) are dead-lettered immediately. If
+// one worker fails, the others are stopped and the first error is returned.
+func (c *RabbitMQConsumer) ConsumeImageProcessingTasks(ctx context.Context, handler ImageTaskHandler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	deliveries, err := c.ch.ConsumeWithContext(ctx, ImageQueue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming %s: %w", ImageQueue, err)
+	}
+
+	workers := max(c.workers, 1)
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			errs <- c.consume(ctx, deliveries, handler)
+		}()
+	}
+
+	var firstErr error
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	return firstErr
+}
+
+// consume handles deliveries one at a time until ctx is cancelled or the
+// delivery channel closes.
+func (c *RabbitMQConsumer) consume(ctx context.Context, deliveries <-chan amqp091.Delivery, handler ImageTaskHandler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("delivery channel for %s closed", ImageQueue)
+			}
+			if err := c.handle(ctx, d, handler); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *RabbitMQConsumer) handle(ctx context.Context, d amqp091.Delivery, handler ImageTaskHandler) error {
+	task, err := DecodeImageProcessingTask(d.Body)
+	if err == nil {
+		err = handler(ctx, task)
+	}
+
+	if err == nil {
+		if err := d.Ack(false); err != nil {
+			return fmt.Errorf("failed to acknowledge message: %w", err)
+		}
+		return nil
+	}
+
+	// Shutting down mid-task is not the task's fault; let it be redelivered
+	if ctx.Err() != nil {
+		return d.Nack(false, true)
+	}
+
+	if pubErr := c.reschedule(d, err); pubErr != nil {
+		// The copy could not be stored, so keep the original instead
+		if err := d.Nack(false, true); err != nil {
+			return fmt.Errorf("failed to requeue message: %w", err)
+		}
+		return fmt.Errorf("failed to reschedule failed message: %w", pubErr)
+	}
+
+	if err := d.Ack(false); err != nil {
+		return fmt.Errorf("failed to acknowledge message: %w", err)
+	}
+	return nil
+}
+
+// reschedule publishes a failed delivery to a delay queue, or to the
+// dead-letter queue when it is poison or out of attempts.
+func (c *RabbitMQConsumer) reschedule(d amqp091.Delivery, cause error) error {
+	attempts := deliveryAttempts(d.Headers) + 1
+
+	if errors.Is(cause, ErrInvalidMessage) || attempts >= c.retry.MaxAttempts {
+		return c.republish.Publish(ImageDeadLetterExchange, ImageDeadLetterKey, failedCopy(d, amqp091.Table{
+			HeaderAttempts:      int32(attempts),
+			HeaderFailureReason: truncateError(cause),
+			HeaderFailedAt:      time.Now().UTC().Format(time.RFC3339),
+		}))
+	}
+
+	return c.republish.Publish(ImageRetryExchange, DelayQueueName(c.retry.Delay(attempts)), failedCopy(d, amqp091.Table{
+		HeaderAttempts:  int32(attempts),
+		HeaderLastError: truncateError(cause),
+	}))
+}
+
+// Close closes the connection the consumer dialed, and with it its
+// channels. Consumers built with NewConsumer own no connection.
+func (c *RabbitMQConsumer) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
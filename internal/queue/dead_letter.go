@@ -0,0 +1,210 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetter is a dead-lettered image task as shown by the DLQ tooling.
+type DeadLetter struct {
+	MessageID string          `json:"message_id,omitempty"`
+	Attempts  int             `json:"attempts"`
+	Reason    string          `json:"reason"`
+	FailedAt  string          `json:"failed_at"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// ErrUnknownCommand is returned for dead-letter commands that do not exist.
+var ErrUnknownCommand = errors.New("unknown dead-letter command")
+
+// DeadLetterChannel is the part of an AMQP channel the dead-letter admin
+// reads and purges the dead-letter queue with.
+type DeadLetterChannel interface {
+	Get(queue string, autoAck bool) (amqp091.Delivery, bool, error)
+	Nack(tag uint64, multiple, requeue bool) error
+	QueuePurge(name string, noWait bool) (int, error)
+}
+
+// DeadLetterAdmin inspects, replays and purges the image dead-letter queue.
+type DeadLetterAdmin struct {
+	conn      *amqp091.Connection
+	ch        DeadLetterChannel
+	publisher Publisher
+}
+
+// NewDeadLetterAdminWithChannel returns an admin reading dead letters from
+// ch and replaying them through publisher. The topology must already be
+// declared.
+func NewDeadLetterAdminWithChannel(ch DeadLetterChannel, publisher Publisher) *DeadLetterAdmin {
+	return &DeadLetterAdmin{ch: ch, publisher: publisher}
+}
+
+func NewDeadLetterAdmin(rabbitMQURL string) (*DeadLetterAdmin, error) {
+	conn, err := ConnectRabbitMQ(rabbitMQURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := declareImageTopology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := declareDeadLetterTopology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	confirmCh, err := newConfirmChannel(ch)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	admin := NewDeadLetterAdminWithChannel(ch, confirmCh)
+	admin.conn = conn
+	return admin, nil
+}
+
+// Inspect returns up to limit dead letters without removing them.
+func (a *DeadLetterAdmin) Inspect(limit int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	var lastTag uint64
+
+	for len(letters) < limit {
+		d, ok, err := a.ch.Get(ImageDeadLetterQueue, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead letter: %w", err)
+		}
+		if !ok {
+			break
+		}
+		lastTag = d.DeliveryTag
+		letters = append(letters, toDeadLetter(d))
+	}
+
+	// Put everything back, in order, with a single multiple-nack
+	if lastTag != 0 {
+		if err := a.ch.Nack(lastTag, true, true); err != nil {
+			return nil, fmt.Errorf("failed to requeue dead letters: %w", err)
+		}
+	}
+
+	return letters, nil
+}
+
+// Replay moves up to limit dead letters back onto the image queue with a
+// fresh attempt count and returns how many were moved.
+func (a *DeadLetterAdmin) Replay(limit int) (int, error) {
+	replayed := 0
+
+	for replayed < limit {
+		d, ok, err := a.ch.Get(ImageDeadLetterQueue, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read dead letter: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		msg := failedCopy(d, nil)
+		for _, header := range []string{HeaderAttempts, HeaderLastError, HeaderFailureReason, HeaderFailedAt} {
+			delete(msg.Headers, header)
+		}
+
+		if err := a.publisher.Publish(ImageExchange, ImageRoutingKey, msg); err != nil {
+			d.Nack(false, true)
+			return replayed, err
+		}
+		if err := d.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to acknowledge dead letter: %w", err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// Purge deletes every dead letter and returns how many were removed.
+func (a *DeadLetterAdmin) Purge() (int, error) {
+	count, err := a.ch.QueuePurge(ImageDeadLetterQueue, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge %s: %w", ImageDeadLetterQueue, err)
+	}
+	return count, nil
+}
+
+// Close closes the connection the admin dialed. Admins built with
+// NewDeadLetterAdminWithChannel own no connection.
+func (a *DeadLetterAdmin) Close() error {
+	if a.conn == nil {
+		return nil
+	}
+	return a.conn.Close()
+}
+
+// RunDeadLetterCommand runs one of the image processor's dlq commands,
+// inspect, replay or purge, against admin and writes its output to out.
+// limit bounds how many messages inspect and replay process.
+func RunDeadLetterCommand(admin *DeadLetterAdmin, command string, limit int, out io.Writer) error {
+	switch command {
+	case "inspect":
+		letters, err := admin.Inspect(limit)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(out)
+		for _, letter := range letters {
+			if err := encoder.Encode(letter); err != nil {
+				return err
+			}
+		}
+	case "replay":
+		count, err := admin.Replay(limit)
+		fmt.Fprintf(out, "replayed %d dead letters\n", count)
+		if err != nil {
+			return err
+		}
+	case "purge":
+		count, err := admin.Purge()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "purged %d dead letters\n", count)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, command)
+	}
+
+	return nil
+}
+
+func toDeadLetter(d amqp091.Delivery) DeadLetter {
+	letter := DeadLetter{
+		MessageID: d.MessageId,
+		Attempts:  deliveryAttempts(d.Headers),
+		Body:      json.RawMessage(d.Body),
+	}
+	letter.Reason, _ = d.Headers[HeaderFailureReason].(string)
+	letter.FailedAt, _ = d.Headers[HeaderFailedAt].(string)
+
+	if !json.Valid(d.Body) {
+		// Keep undecodable bodies readable in the JSON output
+		quoted, _ := json.Marshal(string(d.Body))
+		letter.Body = quoted
+	}
+	if letter.FailedAt == "" && !d.Timestamp.IsZero() {
+		letter.FailedAt = d.Timestamp.UTC().Format(time.RFC3339)
+	}
+
+	return letter
+}
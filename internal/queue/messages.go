@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"product-management/internal/models"
+)
+
+// ImageTaskSchemaVersion is the current version of ImageProcessingTask.
+// Consumers reject messages with a version they do not understand.
+// Version 2 added MessageID and ImageListHash; version 1 tasks are still
+// accepted but cannot be deduplicated.
+const ImageTaskSchemaVersion = 2
+
+// ImageTaskType is the AMQP type property of image processing messages.
+const ImageTaskType = "product.image.process"
+
+// ErrInvalidMessage marks a message that can never be processed, no matter
+// how often it is redelivered.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// ImageProcessingTask asks the image processor to render a product's images.
+type ImageProcessingTask struct {
+	Version int `json:"version"`
+	// MessageID identifies the task across redeliveries and republishes.
+	MessageID string   `json:"message_id,omitempty"`
+	ProductID int64    `json:"product_id"`
+	ImageURLs []string `json:"image_urls"`
+	// ImageListHash is ImageListHash(ImageURLs) at the time of enqueueing.
+	// It keeps the content_hash name it was introduced with on the wire.
+	ImageListHash string    `json:"content_hash,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NewImageProcessingTask builds a task with a fresh message ID using the
+// current schema version.
+func NewImageProcessingTask(productID int64, imageURLs []string) *ImageProcessingTask {
+	return &ImageProcessingTask{
+		Version:       ImageTaskSchemaVersion,
+		MessageID:     newMessageID(),
+		ProductID:     productID,
+		ImageURLs:     imageURLs,
+		ImageListHash: ImageListHash(imageURLs),
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// ImageListHash identifies a product's list of source image locations.
+// Equal lists hash equally, so a task can be matched against the product's
+// current images. Changes to the bytes behind a location are detected by
+// the worker, which hashes what it fetches.
+func ImageListHash(imageURLs []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(imageURLs, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func newMessageID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("failed to generate message ID: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// DecodeImageProcessingTask parses and validates a message body.
+func DecodeImageProcessingTask(body []byte) (*ImageProcessingTask, error) {
+	var task ImageProcessingTask
+	if err := json.Unmarshal(body, &task); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+
+	if task.Version < 1 || task.Version > ImageTaskSchemaVersion {
+		return nil, fmt.Errorf("%w: unsupported schema version %d", ErrInvalidMessage, task.Version)
+	}
+	if task.ProductID <= 0 {
+		return nil, fmt.Errorf("%w: missing product ID", ErrInvalidMessage)
+	}
+	if task.Version >= 2 && (task.MessageID == "" || task.ImageListHash == "") {
+		return nil, fmt.Errorf("%w: missing message ID or image list hash", ErrInvalidMessage)
+	}
+
+	return &task, nil
+}
+
+// LowStockEventType is the AMQP type property of low stock events.
+const LowStockEventType = "inventory.low_stock"
+
+// LowStockEvent reports that the available units of a product or variant
+// in a warehouse dropped to or below its low stock threshold.
+type LowStockEvent struct {
+	MessageID   string    `json:"message_id"`
+	ProductID   int64     `json:"product_id"`
+	VariantID   *int64    `json:"variant_id,omitempty"`
+	WarehouseID int64     `json:"warehouse_id"`
+	Available   int       `json:"available"`
+	Threshold   int       `json:"threshold"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// NewLowStockEvent builds an event with a fresh message ID.
+func NewLowStockEvent(productID int64, variantID *int64, warehouseID int64, available, threshold int) *LowStockEvent {
+	return &LowStockEvent{
+		MessageID:   newMessageID(),
+		ProductID:   productID,
+		VariantID:   variantID,
+		WarehouseID: warehouseID,
+		Available:   available,
+		Threshold:   threshold,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// PriceChangedEventType is the AMQP type property of price change events.
+const PriceChangedEventType = "product.price_changed"
+
+// PriceChangedEvent reports that the effective price of a product changed.
+type PriceChangedEvent struct {
+	MessageID string        `json:"message_id"`
+	ProductID int64         `json:"product_id"`
+	Currency  string        `json:"currency"`
+	OldPrice  models.Amount `json:"old_price"`
+	NewPrice  models.Amount `json:"new_price"`
+	Reason    string        `json:"reason"`
+	// ScheduleID is the price schedule that started or ended, if any.
+	ScheduleID *int64    `json:"schedule_id,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// NewPriceChangedEvent builds the event of a recorded price change with
+// a fresh message ID. The change must have an old price.
+func NewPriceChangedEvent(change *models.PriceChange) *PriceChangedEvent {
+	return &PriceChangedEvent{
+		MessageID:  newMessageID(),
+		ProductID:  change.ProductID,
+		Currency:   change.Currency,
+		OldPrice:   *change.OldPrice,
+		NewPrice:   change.NewPrice,
+		Reason:     change.Reason,
+		ScheduleID: change.ScheduleID,
+		ChangedAt:  change.ChangedAt.UTC(),
+		CreatedAt:  time.Now().UTC(),
+	}
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQPublisher publishes persistent messages on a confirm-mode channel,
+// so a nil error from a publish means the broker has taken responsibility
+// for the message.
+type RabbitMQPublisher struct {
+	conn *amqp091.Connection
+	ch   *confirmChannel
+}
+
+func NewRabbitMQPublisher(rabbitMQURL string) (*RabbitMQPublisher, error) {
+	conn, err := ConnectRabbitMQ(rabbitMQURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := declareImageTopology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := declareInventoryTopology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := declarePricingTopology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	confirmCh, err := newConfirmChannel(ch)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &RabbitMQPublisher{conn: conn, ch: confirmCh}, nil
+}
+
+// PublishImageProcessingTask publishes an image task and waits for the
+// broker to confirm it.
+func (p *RabbitMQPublisher) PublishImageProcessingTask(productID int64, imageURLs []string) error {
+	body, err := json.Marshal(NewImageProcessingTask(productID, imageURLs))
+	if err != nil {
+		return fmt.Errorf("failed to marshal image task: %w", err)
+	}
+
+	return p.ch.Publish(ImageExchange, ImageRoutingKey, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		Type:         ImageTaskType,
+		Timestamp:    time.Now(),
+		Headers:      amqp091.Table{"schema_version": int32(ImageTaskSchemaVersion)},
+		Body:         body,
+	})
+}
+
+// Message is an already encoded JSON message, e.g. one relayed from the
+// outbox table.
+type Message struct {
+	// ID becomes the AMQP message ID, letting consumers spot redeliveries.
+	ID        string
+	Type      string
+	Body      []byte
+	Timestamp time.Time
+}
+
+// Publish publishes msg persistently and waits for the broker to confirm it.
+func (p *RabbitMQPublisher) Publish(exchange, routingKey string, msg Message) error {
+	return p.ch.Publish(exchange, routingKey, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		MessageId:    msg.ID,
+		Type:         msg.Type,
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	})
+}
+
+func (p *RabbitMQPublisher) Close() error {
+	if err := p.ch.ch.Close(); err != nil {
+		p.conn.Close()
+		return fmt.Errorf("failed to close channel: %w", err)
+	}
+	return p.conn.Close()
+}
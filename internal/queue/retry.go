@@ -0,0 +1,152 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Retry and dead-letter topology for image tasks. Failed tasks are parked
+// in a delay queue whose TTL expiry dead-letters them back onto the image
+// exchange; tasks that exhaust their attempts land in the dead-letter queue.
+const (
+	ImageRetryExchange      = "product.images.retry"
+	ImageDeadLetterExchange = "product.images.dlx"
+	ImageDeadLetterQueue    = "product.images.dead"
+	ImageDeadLetterKey      = "image.dead"
+)
+
+// Headers recorded on retried and dead-lettered messages.
+const (
+	HeaderAttempts      = "x-attempts"
+	HeaderLastError     = "x-last-error"
+	HeaderFailureReason = "x-failure-reason"
+	HeaderFailedAt      = "x-failed-at"
+)
+
+// maxErrorHeaderLength keeps failure reasons from bloating message headers.
+const maxErrorHeaderLength = 1024
+
+// RetryPolicy controls how often and how quickly failed tasks are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a task is tried, including
+	// the first delivery.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Delay returns the backoff before the retry following the given failed
+// attempt: BaseDelay doubled for every earlier failure, capped at MaxDelay.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// DelayQueueName names the delay queue for a backoff. Queues are keyed by
+// their TTL rather than by attempt number, so changing the policy declares
+// new queues instead of conflicting with existing ones.
+func DelayQueueName(delay time.Duration) string {
+	return fmt.Sprintf("%s.%dms", ImageRetryExchange, delay.Milliseconds())
+}
+
+// declareRetryTopology declares the retry exchange, one delay queue per
+// distinct backoff in the policy, and the dead-letter exchange and queue.
+func declareRetryTopology(ch *amqp091.Channel, policy RetryPolicy) error {
+	if err := ch.ExchangeDeclare(ImageRetryExchange, amqp091.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", ImageRetryExchange, err)
+	}
+
+	declared := map[string]bool{}
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		delay := policy.Delay(attempt)
+		name := DelayQueueName(delay)
+		if declared[name] {
+			continue
+		}
+		declared[name] = true
+
+		args := amqp091.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    ImageExchange,
+			"x-dead-letter-routing-key": ImageRoutingKey,
+		}
+		if _, err := ch.QueueDeclare(name, true, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare delay queue %s: %w", name, err)
+		}
+		if err := ch.QueueBind(name, name, ImageRetryExchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind delay queue %s: %w", name, err)
+		}
+	}
+
+	return declareDeadLetterTopology(ch)
+}
+
+func declareDeadLetterTopology(ch *amqp091.Channel) error {
+	if err := ch.ExchangeDeclare(ImageDeadLetterExchange, amqp091.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", ImageDeadLetterExchange, err)
+	}
+
+	if _, err := ch.QueueDeclare(ImageDeadLetterQueue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", ImageDeadLetterQueue, err)
+	}
+
+	if err := ch.QueueBind(ImageDeadLetterQueue, ImageDeadLetterKey, ImageDeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind queue %s: %w", ImageDeadLetterQueue, err)
+	}
+
+	return nil
+}
+
+// deliveryAttempts returns how many times a message has already failed.
+func deliveryAttempts(headers amqp091.Table) int {
+	switch v := headers[HeaderAttempts].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// failedCopy republishes d with failure headers merged into its own.
+func failedCopy(d amqp091.Delivery, extra amqp091.Table) amqp091.Publishing {
+	headers := amqp091.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+
+	return amqp091.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp091.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Type:         d.Type,
+		Body:         d.Body,
+	}
+}
+
+func truncateError(err error) string {
+	msg := err.Error()
+	if len(msg) > maxErrorHeaderLength {
+		return msg[:maxErrorHeaderLength]
+	}
+	return msg
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+// ErrAPIKeyNotFound is returned when no API key matches an ID or hash.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKeyRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewAPIKeyRepository(db *sql.DB, logger *logger.Logger) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, rate_limit, last_used_at, revoked_at, created_at`
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, rate_limit)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query,
+		key.UserID, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.RateLimit,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create api key", logger.Error(err))
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *APIKeyRepository) FindByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	key, err := scanAPIKey(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAPIKeyNotFound
+		}
+		r.logger.Error("Failed to find api key", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// FindActiveByHash returns the unrevoked key with the given hash.
+func (r *APIKeyRepository) FindActiveByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+
+	key, err := scanAPIKey(conn(ctx, r.db).QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAPIKeyNotFound
+		}
+		r.logger.Error("Failed to find api key by hash", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListByUserID returns every key of a user, revoked ones included, newest
+// first.
+func (r *APIKeyRepository) ListByUserID(ctx context.Context, userID int64) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY id DESC`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("Failed to list api keys", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan api key", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// TouchLastUsed records that a key was used. It writes at most once a
+// minute per key so busy keys do not turn every request into a write.
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		  AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`, id)
+	if err != nil {
+		r.logger.Error("Failed to record api key use", logger.Error(err))
+		return fmt.Errorf("failed to record api key use: %w", err)
+	}
+
+	return nil
+}
+
+// Revoke disables a key. Revoking a revoked key is a no-op.
+func (r *APIKeyRepository) Revoke(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		r.logger.Error("Failed to revoke api key", logger.Error(err))
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	return nil
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var rateLimit sql.NullInt64
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		pq.Array(&key.Scopes),
+		&rateLimit,
+		&key.LastUsedAt,
+		&key.RevokedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if rateLimit.Valid {
+		limit := int(rateLimit.Int64)
+		key.RateLimit = &limit
+	}
+	return key, nil
+}
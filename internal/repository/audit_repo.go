@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+)
+
+type AuditRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewAuditRepository(db *sql.DB, logger *logger.Logger) *AuditRepository {
+	return &AuditRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create writes an audit entry. Called with the context of a transaction,
+// the entry is only kept if the change it records is.
+func (r *AuditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (entity_type, entity_id, action, actor_id, api_key_id, request_id, source_ip, changes)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, '')::INET, $8)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query,
+		entry.EntityType, entry.EntityID, entry.Action, entry.ActorID, entry.APIKeyID,
+		entry.RequestID, entry.SourceIP, []byte(entry.Changes),
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to write audit entry", logger.Error(err))
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// List returns one page of the audit entries matching params, newest
+// first, and how many match in total.
+func (r *AuditRepository) List(ctx context.Context, params *models.AuditFilterParams) ([]models.AuditEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if params.EntityType != "" {
+		conditions = append(conditions, "entity_type = "+addArg(params.EntityType))
+	}
+	if params.EntityID != 0 {
+		conditions = append(conditions, "entity_id = "+addArg(params.EntityID))
+	}
+	if params.ActorID != 0 {
+		conditions = append(conditions, "actor_id = "+addArg(params.ActorID))
+	}
+	if params.From != nil {
+		conditions = append(conditions, "created_at >= "+addArg(*params.From))
+	}
+	if params.To != nil {
+		conditions = append(conditions, "created_at < "+addArg(*params.To))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var totalCount int
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&totalCount)
+	if err != nil {
+		r.logger.Error("Failed to count audit entries", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	query := `
+		SELECT id, entity_type, entity_id, action, actor_id, api_key_id,
+			COALESCE(request_id, ''), COALESCE(HOST(source_ip), ''), changes, created_at
+		FROM audit_log` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT ` + addArg(params.PageSize) + ` OFFSET ` + addArg((params.Page-1)*params.PageSize)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list audit entries", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to retrieve audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var changes []byte
+		err := rows.Scan(&e.ID, &e.EntityType, &e.EntityID, &e.Action, &e.ActorID, &e.APIKeyID,
+			&e.RequestID, &e.SourceIP, &changes, &e.CreatedAt)
+		if err != nil {
+			r.logger.Error("Failed to scan audit entry", logger.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Changes = changes
+		entries = append(entries, e)
+	}
+
+	return entries, totalCount, rows.Err()
+}
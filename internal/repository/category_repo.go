@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+)
+
+var (
+	// ErrCategoryNotFound is returned when no category matches the given ID.
+	ErrCategoryNotFound = errors.New("category not found")
+	// ErrUnknownCategory is returned when a parent or a product assignment
+	// refers to a category that does not exist.
+	ErrUnknownCategory = errors.New("unknown category")
+	// ErrCategoryExists is returned when a sibling already has the slug.
+	ErrCategoryExists = errors.New("category slug already exists under this parent")
+	// ErrCategoryHasChildren is returned when deleting a category that
+	// still has subcategories.
+	ErrCategoryHasChildren = errors.New("category still has subcategories")
+	// ErrCategoryCycle is returned when a category would be moved below
+	// itself.
+	ErrCategoryCycle = errors.New("category cannot be moved below itself")
+)
+
+// categoryTreeLockID serialises changes to the tree, so concurrent moves
+// cannot build a cycle between them and a category created below a parent
+// that is being moved gets the parent's new path.
+const categoryTreeLockID = 7_281_845_302
+
+type CategoryRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewCategoryRepository(db *sql.DB, logger *logger.Logger) *CategoryRepository {
+	return &CategoryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const categoryColumns = `id, parent_id, name, slug, path, created_at, updated_at`
+
+// Create inserts a category below its parent, whose path it reads under the
+// tree lock.
+func (r *CategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	return runInTx(ctx, r.db, func(ctx context.Context) error {
+		if err := r.lockTree(ctx); err != nil {
+			return err
+		}
+
+		// The path ends with the category's own ID, so take it up front
+		query := `
+			INSERT INTO categories (id, parent_id, name, slug, path)
+			SELECT next.id, $1, $2, $3,
+			       COALESCE((SELECT path FROM categories WHERE id = $1), '/') || next.id || '/'
+			FROM (SELECT nextval('categories_id_seq') AS id) AS next
+			RETURNING id, path, created_at, updated_at
+		`
+
+		err := conn(ctx, r.db).QueryRowContext(ctx, query, category.ParentID, category.Name, category.Slug).
+			Scan(&category.ID, &category.Path, &category.CreatedAt, &category.UpdatedAt)
+		if err != nil {
+			if isPQError(err, pqForeignKeyViolation) {
+				return ErrUnknownCategory
+			}
+			if isPQError(err, pqUniqueViolation) {
+				return ErrCategoryExists
+			}
+			r.logger.Error("Failed to create category", logger.Error(err))
+			return fmt.Errorf("failed to create category: %w", err)
+		}
+		category.Depth = pathDepth(category.Path)
+
+		return nil
+	})
+}
+
+func (r *CategoryRepository) FindByID(ctx context.Context, id int64) (*models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = $1`
+
+	category, err := scanCategory(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCategoryNotFound
+		}
+		r.logger.Error("Failed to find category", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve category: %w", err)
+	}
+
+	return category, nil
+}
+
+// List returns every category depth first, each parent before its
+// children.
+func (r *CategoryRepository) List(ctx context.Context) ([]models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories ORDER BY path`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query)
+	if err != nil {
+		r.logger.Error("Failed to list categories", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan category", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, *category)
+	}
+
+	return categories, rows.Err()
+}
+
+// Update saves a category's name, slug and parent. Changing the parent
+// moves the whole subtree along.
+func (r *CategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	return runInTx(ctx, r.db, func(ctx context.Context) error {
+		if err := r.lockTree(ctx); err != nil {
+			return err
+		}
+
+		var oldPath string
+		err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT path FROM categories WHERE id = $1`, category.ID).Scan(&oldPath)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrCategoryNotFound
+			}
+			r.logger.Error("Failed to find category", logger.Error(err))
+			return fmt.Errorf("failed to retrieve category: %w", err)
+		}
+
+		newPath := "/" + strconv.FormatInt(category.ID, 10) + "/"
+		if category.ParentID != nil {
+			var parentPath string
+			err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT path FROM categories WHERE id = $1`, *category.ParentID).Scan(&parentPath)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					return ErrUnknownCategory
+				}
+				r.logger.Error("Failed to find parent category", logger.Error(err))
+				return fmt.Errorf("failed to retrieve parent category: %w", err)
+			}
+			if strings.HasPrefix(parentPath, oldPath) {
+				return ErrCategoryCycle
+			}
+			newPath = parentPath + newPath[1:]
+		}
+
+		query := `
+			UPDATE categories
+			SET parent_id = $1, name = $2, slug = $3, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $4
+			RETURNING created_at, updated_at
+		`
+		err = conn(ctx, r.db).QueryRowContext(ctx, query, category.ParentID, category.Name, category.Slug, category.ID).
+			Scan(&category.CreatedAt, &category.UpdatedAt)
+		if err != nil {
+			if isPQError(err, pqUniqueViolation) {
+				return ErrCategoryExists
+			}
+			r.logger.Error("Failed to update category", logger.Error(err))
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+
+		category.Path = newPath
+		category.Depth = pathDepth(newPath)
+		if newPath == oldPath {
+			return nil
+		}
+
+		// Rewrite the path prefix of the category and everything below it
+		_, err = conn(ctx, r.db).ExecContext(ctx, `
+			UPDATE categories
+			SET path = $1 || substr(path, $2)
+			WHERE path LIKE $3 || '%'
+		`, newPath, len(oldPath)+1, oldPath)
+		if err != nil {
+			r.logger.Error("Failed to move category subtree", logger.Error(err))
+			return fmt.Errorf("failed to move category subtree: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Delete removes a category and its product assignments. Categories with
+// subcategories cannot be deleted.
+func (r *CategoryRepository) Delete(ctx context.Context, id int64) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		if isPQError(err, pqForeignKeyViolation) {
+			return ErrCategoryHasChildren
+		}
+		r.logger.Error("Failed to delete category", logger.Error(err))
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+	if deleted == 0 {
+		return ErrCategoryNotFound
+	}
+
+	return nil
+}
+
+func scanCategory(row rowScanner) (*models.Category, error) {
+	category := &models.Category{}
+	err := row.Scan(
+		&category.ID,
+		&category.ParentID,
+		&category.Name,
+		&category.Slug,
+		&category.Path,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	category.Depth = pathDepth(category.Path)
+	return category, nil
+}
+
+// pathDepth is the number of ancestors on a materialized path.
+func pathDepth(path string) int {
+	return max(strings.Count(path, "/")-2, 0)
+}
+
+// lockTree takes the tree lock until the transaction in ctx ends.
+func (r *CategoryRepository) lockTree(ctx context.Context) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, categoryTreeLockID); err != nil {
+		r.logger.Error("Failed to lock category tree", logger.Error(err))
+		return fmt.Errorf("failed to lock category tree: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+)
+
+var (
+	// ErrUnknownWarehouse is returned when stock refers to a warehouse that
+	// does not exist.
+	ErrUnknownWarehouse = errors.New("unknown warehouse")
+	// ErrWarehouseExists is returned when the warehouse code is taken.
+	ErrWarehouseExists = errors.New("warehouse code already exists")
+	// ErrInsufficientStock is returned when a change would leave fewer
+	// units on hand than reserved, or reserve more than is available.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrReservationNotFound is returned when no reservation matches the
+	// given ID.
+	ErrReservationNotFound = errors.New("reservation not found")
+)
+
+type InventoryRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewInventoryRepository(db *sql.DB, logger *logger.Logger) *InventoryRepository {
+	return &InventoryRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *InventoryRepository) CreateWarehouse(ctx context.Context, warehouse *models.Warehouse) error {
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO warehouses (code, name) VALUES ($1, $2) RETURNING id, created_at`,
+		warehouse.Code, warehouse.Name,
+	).Scan(&warehouse.ID, &warehouse.CreatedAt)
+	if err != nil {
+		if isPQError(err, pqUniqueViolation) {
+			return ErrWarehouseExists
+		}
+		r.logger.Error("Failed to create warehouse", logger.Error(err))
+		return fmt.Errorf("failed to create warehouse: %w", err)
+	}
+
+	return nil
+}
+
+func (r *InventoryRepository) ListWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT id, code, name, created_at FROM warehouses ORDER BY id`)
+	if err != nil {
+		r.logger.Error("Failed to list warehouses", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve warehouses: %w", err)
+	}
+	defer rows.Close()
+
+	warehouses := []models.Warehouse{}
+	for rows.Next() {
+		var w models.Warehouse
+		if err := rows.Scan(&w.ID, &w.Code, &w.Name, &w.CreatedAt); err != nil {
+			r.logger.Error("Failed to scan warehouse", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan warehouse: %w", err)
+		}
+		warehouses = append(warehouses, w)
+	}
+
+	return warehouses, rows.Err()
+}
+
+const inventoryLevelColumns = `id, product_id, variant_id, warehouse_id, on_hand, reserved, low_stock_threshold, updated_at`
+
+// Adjust adds delta to the units on hand of the level identified by
+// level's product, variant and warehouse, creating the level on first
+// use, and sets its threshold when level.LowStockThreshold is set. The
+// level is updated in place.
+func (r *InventoryRepository) Adjust(ctx context.Context, level *models.InventoryLevel, delta int) error {
+	// The condition is checked against the locked, current row, so
+	// concurrent adjustments and reservations cannot overdraw it
+	query := `
+		INSERT INTO inventory_levels (product_id, variant_id, warehouse_id, on_hand, low_stock_threshold)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (product_id, COALESCE(variant_id, 0), warehouse_id) DO UPDATE
+		SET on_hand = inventory_levels.on_hand + EXCLUDED.on_hand,
+		    low_stock_threshold = COALESCE(EXCLUDED.low_stock_threshold, inventory_levels.low_stock_threshold),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE inventory_levels.on_hand + EXCLUDED.on_hand >= inventory_levels.reserved
+		RETURNING ` + inventoryLevelColumns
+
+	adjusted, err := scanInventoryLevel(conn(ctx, r.db).QueryRowContext(ctx, query,
+		level.ProductID, level.VariantID, level.WarehouseID, delta, level.LowStockThreshold,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows || isPQError(err, pqCheckViolation) {
+			return ErrInsufficientStock
+		}
+		if isPQError(err, pqForeignKeyViolation) {
+			return ErrUnknownWarehouse
+		}
+		r.logger.Error("Failed to adjust stock", logger.Error(err))
+		return fmt.Errorf("failed to adjust stock: %w", err)
+	}
+
+	*level = *adjusted
+	return nil
+}
+
+// Reserve holds quantity units of the level identified by level's
+// product, variant and warehouse, if that many are available. The level
+// is updated in place.
+func (r *InventoryRepository) Reserve(ctx context.Context, level *models.InventoryLevel, quantity int) error {
+	query := `
+		UPDATE inventory_levels
+		SET reserved = reserved + $1, updated_at = CURRENT_TIMESTAMP
+		WHERE product_id = $2 AND COALESCE(variant_id, 0) = COALESCE($3, 0) AND warehouse_id = $4
+		  AND on_hand - reserved >= $1
+		RETURNING ` + inventoryLevelColumns
+
+	reserved, err := scanInventoryLevel(conn(ctx, r.db).QueryRowContext(ctx, query,
+		quantity, level.ProductID, level.VariantID, level.WarehouseID,
+	))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInsufficientStock
+		}
+		r.logger.Error("Failed to reserve stock", logger.Error(err))
+		return fmt.Errorf("failed to reserve stock: %w", err)
+	}
+
+	*level = *reserved
+	return nil
+}
+
+// ApplyDelta changes the units on hand and reserved of a level, e.g. when
+// a reservation is committed or released.
+func (r *InventoryRepository) ApplyDelta(ctx context.Context, inventoryID int64, onHandDelta, reservedDelta int) (*models.InventoryLevel, error) {
+	query := `
+		UPDATE inventory_levels
+		SET on_hand = on_hand + $1, reserved = reserved + $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING ` + inventoryLevelColumns
+
+	level, err := scanInventoryLevel(conn(ctx, r.db).QueryRowContext(ctx, query, onHandDelta, reservedDelta, inventoryID))
+	if err != nil {
+		if isPQError(err, pqCheckViolation) {
+			return nil, ErrInsufficientStock
+		}
+		r.logger.Error("Failed to update stock", logger.Error(err))
+		return nil, fmt.Errorf("failed to update stock: %w", err)
+	}
+
+	return level, nil
+}
+
+// ListLevels returns a product's stock per variant and warehouse,
+// optionally narrowed down to one variant or warehouse.
+func (r *InventoryRepository) ListLevels(ctx context.Context, productID int64, params *models.InventoryParams) ([]models.InventoryLevel, error) {
+	conditions, args := inventoryConditions(productID, params)
+	query := `SELECT ` + inventoryLevelColumns + ` FROM inventory_levels WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY variant_id NULLS FIRST, warehouse_id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list stock", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve stock: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []models.InventoryLevel{}
+	for rows.Next() {
+		level, err := scanInventoryLevel(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan stock", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan stock: %w", err)
+		}
+		levels = append(levels, *level)
+	}
+
+	return levels, rows.Err()
+}
+
+func (r *InventoryRepository) CreateReservation(ctx context.Context, reservation *models.StockReservation) error {
+	query := `
+		INSERT INTO stock_reservations (inventory_id, quantity, status, reference)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, created_at, updated_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query,
+		reservation.InventoryID, reservation.Quantity, reservation.Status, reservation.Reference,
+	).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create reservation", logger.Error(err))
+		return fmt.Errorf("failed to create reservation: %w", err)
+	}
+
+	return nil
+}
+
+// FindReservationForUpdate returns a reservation and locks it until the
+// surrounding transaction ends, so it is committed or released only once.
+func (r *InventoryRepository) FindReservationForUpdate(ctx context.Context, id int64) (*models.StockReservation, error) {
+	query := `
+		SELECT r.id, r.inventory_id, l.product_id, r.quantity, r.status,
+		       COALESCE(r.reference, ''), r.created_at, r.updated_at
+		FROM stock_reservations r
+		JOIN inventory_levels l ON l.id = r.inventory_id
+		WHERE r.id = $1
+		FOR UPDATE OF r
+	`
+
+	reservation := &models.StockReservation{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&reservation.ID,
+		&reservation.InventoryID,
+		&reservation.ProductID,
+		&reservation.Quantity,
+		&reservation.Status,
+		&reservation.Reference,
+		&reservation.CreatedAt,
+		&reservation.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReservationNotFound
+		}
+		r.logger.Error("Failed to find reservation", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve reservation: %w", err)
+	}
+
+	return reservation, nil
+}
+
+func (r *InventoryRepository) SetReservationStatus(ctx context.Context, reservation *models.StockReservation, status string) error {
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+		UPDATE stock_reservations
+		SET status = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING updated_at
+	`, status, reservation.ID).Scan(&reservation.UpdatedAt)
+	if err != nil {
+		r.logger.Error("Failed to update reservation", logger.Error(err))
+		return fmt.Errorf("failed to update reservation: %w", err)
+	}
+
+	reservation.Status = status
+	return nil
+}
+
+// AddMovement appends an entry to the stock ledger.
+func (r *InventoryRepository) AddMovement(ctx context.Context, movement *models.StockMovement) error {
+	query := `
+		INSERT INTO stock_movements
+		(inventory_id, kind, on_hand_delta, reserved_delta, reservation_id, reason, actor_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query,
+		movement.InventoryID, movement.Kind, movement.OnHandDelta, movement.ReservedDelta,
+		movement.ReservationID, movement.Reason, movement.ActorID,
+	).Scan(&movement.ID, &movement.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to record stock movement", logger.Error(err))
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+
+	return nil
+}
+
+// ListMovements returns one page of a product's stock ledger, newest
+// first, and the number of entries.
+func (r *InventoryRepository) ListMovements(ctx context.Context, productID int64, params *models.StockMovementParams) ([]models.StockMovement, int, error) {
+	conditions, args := inventoryConditions(productID, &params.InventoryParams)
+	from := ` FROM stock_movements m JOIN inventory_levels l ON l.id = m.inventory_id WHERE ` +
+		strings.Join(conditions, " AND ")
+
+	var total int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*)`+from, args...).Scan(&total); err != nil {
+		r.logger.Error("Failed to count stock movements", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to count stock movements: %w", err)
+	}
+
+	args = append(args, params.PageSize, (params.Page-1)*params.PageSize)
+	query := `
+		SELECT m.id, m.inventory_id, m.kind, m.on_hand_delta, m.reserved_delta,
+		       m.reservation_id, COALESCE(m.reason, ''), m.actor_id, m.created_at` + from +
+		fmt.Sprintf(" ORDER BY m.id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list stock movements", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to retrieve stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var m models.StockMovement
+		err := rows.Scan(
+			&m.ID,
+			&m.InventoryID,
+			&m.Kind,
+			&m.OnHandDelta,
+			&m.ReservedDelta,
+			&m.ReservationID,
+			&m.Reason,
+			&m.ActorID,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			r.logger.Error("Failed to scan stock movement", logger.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan stock movement: %w", err)
+		}
+		movements = append(movements, m)
+	}
+
+	return movements, total, rows.Err()
+}
+
+// inventoryConditions select the inventory levels (aliased l where joined)
+// of a product matching params.
+func inventoryConditions(productID int64, params *models.InventoryParams) ([]string, []interface{}) {
+	conditions := []string{"product_id = $1"}
+	args := []interface{}{productID}
+	if params.VariantID != 0 {
+		args = append(args, params.VariantID)
+		conditions = append(conditions, fmt.Sprintf("variant_id = $%d", len(args)))
+	}
+	if params.WarehouseID != 0 {
+		args = append(args, params.WarehouseID)
+		conditions = append(conditions, fmt.Sprintf("warehouse_id = $%d", len(args)))
+	}
+	return conditions, args
+}
+
+func scanInventoryLevel(row rowScanner) (*models.InventoryLevel, error) {
+	level := &models.InventoryLevel{}
+	err := row.Scan(
+		&level.ID,
+		&level.ProductID,
+		&level.VariantID,
+		&level.WarehouseID,
+		&level.OnHand,
+		&level.Reserved,
+		&level.LowStockThreshold,
+		&level.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return level, nil
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+// OutboxRepository stores messages that must be published if, and only if,
+// the transaction that produced them commits.
+type OutboxRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewOutboxRepository(db *sql.DB, logger *logger.Logger) *OutboxRepository {
+	return &OutboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Enqueue adds a message to the outbox. Call it within the transaction of
+// the change the message announces.
+func (r *OutboxRepository) Enqueue(ctx context.Context, msg *models.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox
+		(aggregate_type, aggregate_id, event_type, exchange, routing_key, payload)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		msg.AggregateType,
+		msg.AggregateID,
+		msg.EventType,
+		msg.Exchange,
+		msg.RoutingKey,
+		[]byte(msg.Payload),
+	).Scan(&msg.ID, &msg.CreatedAt)
+
+	if err != nil {
+		r.logger.Error("Failed to enqueue outbox message", logger.Error(err))
+		return fmt.Errorf("failed to enqueue outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// outboxClaimLockID serialises claims, so a relay never claims a message
+// while an earlier one of the same aggregate is being claimed by another.
+const outboxClaimLockID = 7_281_845_301
+
+// Claim leases up to limit due messages to the caller until leaseUntil and
+// returns them in insertion order. Leased messages are not due for other
+// relays. A message is only due once no earlier unpublished message of the
+// same aggregate is waiting, so messages of one aggregate are published in
+// the order they were enqueued. Call it within a transaction.
+func (r *OutboxRepository) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]models.OutboxMessage, error) {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, outboxClaimLockID); err != nil {
+		r.logger.Error("Failed to lock outbox", logger.Error(err))
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+
+	query := `
+		UPDATE outbox
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT o.id
+			FROM outbox o
+			WHERE o.published_at IS NULL AND o.next_attempt_at <= CURRENT_TIMESTAMP
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox earlier
+				WHERE earlier.aggregate_type = o.aggregate_type
+				AND earlier.aggregate_id = o.aggregate_id
+				AND earlier.id < o.id
+				AND earlier.published_at IS NULL
+				AND earlier.next_attempt_at > CURRENT_TIMESTAMP
+			)
+			ORDER BY o.id
+			LIMIT $1
+		)
+		RETURNING id, aggregate_type, aggregate_id, event_type, exchange,
+		          routing_key, payload, attempts, COALESCE(last_error, ''), created_at
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, limit, leaseUntil)
+	if err != nil {
+		r.logger.Error("Failed to claim outbox messages", logger.Error(err))
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.OutboxMessage
+	for rows.Next() {
+		var msg models.OutboxMessage
+		err := rows.Scan(
+			&msg.ID,
+			&msg.AggregateType,
+			&msg.AggregateID,
+			&msg.EventType,
+			&msg.Exchange,
+			&msg.RoutingKey,
+			&msg.Payload,
+			&msg.Attempts,
+			&msg.LastError,
+			&msg.CreatedAt,
+		)
+		if err != nil {
+			r.logger.Error("Failed to scan outbox message", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+
+	return messages, nil
+}
+
+// MarkPublished records that the broker accepted a message.
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox
+		SET published_at = CURRENT_TIMESTAMP, attempts = attempts + 1, last_error = NULL
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		r.logger.Error("Failed to mark outbox message published", logger.Error(err))
+		return fmt.Errorf("failed to mark outbox message published: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed publish; the message stays pending and is
+// next tried at retryAt.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2
+		WHERE id = $3
+	`, reason, retryAt, id)
+	if err != nil {
+		r.logger.Error("Failed to mark outbox message failed", logger.Error(err))
+		return fmt.Errorf("failed to mark outbox message failed: %w", err)
+	}
+
+	return nil
+}
+
+// Release makes claimed messages that were not attempted due again.
+func (r *OutboxRepository) Release(ctx context.Context, ids []int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE outbox
+		SET next_attempt_at = CURRENT_TIMESTAMP
+		WHERE id = ANY($1) AND published_at IS NULL
+	`, pq.Array(ids))
+	if err != nil {
+		r.logger.Error("Failed to release outbox messages", logger.Error(err))
+		return fmt.Errorf("failed to release outbox messages: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePublishedBefore removes messages published before cutoff and
+// returns how many were removed.
+func (r *OutboxRepository) DeletePublishedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM outbox
+		WHERE published_at IS NOT NULL AND published_at < $1
+	`, cutoff)
+	if err != nil {
+		r.logger.Error("Failed to delete published outbox messages", logger.Error(err))
+		return 0, fmt.Errorf("failed to delete published outbox messages: %w", err)
+	}
+
+	return result.RowsAffected()
+}
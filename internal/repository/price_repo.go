@@ -0,0 +1,368 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrPriceNotFound is returned when a product's price list has no
+	// price in the given currency.
+	ErrPriceNotFound = errors.New("price not found")
+	// ErrScheduleNotFound is returned when no price schedule of the product
+	// matches the given ID.
+	ErrScheduleNotFound = errors.New("price schedule not found")
+	// ErrScheduleOverlap is returned when a price schedule overlaps another
+	// one of the same product.
+	ErrScheduleOverlap = errors.New("price schedule overlaps another one")
+)
+
+type PriceRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewPriceRepository(db *sql.DB, logger *logger.Logger) *PriceRepository {
+	return &PriceRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// SetPrice adds a price to a product's price list, replacing the price in
+// the same currency.
+func (r *PriceRepository) SetPrice(ctx context.Context, price *models.CurrencyPrice) error {
+	query := `
+		INSERT INTO product_prices (product_id, currency, amount)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (product_id, currency) DO UPDATE
+		SET amount = EXCLUDED.amount, updated_at = CURRENT_TIMESTAMP
+		RETURNING created_at, updated_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, price.ProductID, price.Currency, price.Amount).
+		Scan(&price.CreatedAt, &price.UpdatedAt)
+	if err != nil {
+		if isPQError(err, pqForeignKeyViolation) {
+			return ErrProductNotFound
+		}
+		r.logger.Error("Failed to set price", logger.Error(err))
+		return fmt.Errorf("failed to set price: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PriceRepository) DeletePrice(ctx context.Context, productID int64, currency string) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`DELETE FROM product_prices WHERE product_id = $1 AND currency = $2`, productID, currency)
+	if err != nil {
+		r.logger.Error("Failed to delete price", logger.Error(err))
+		return fmt.Errorf("failed to delete price: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete price: %w", err)
+	}
+	if deleted == 0 {
+		return ErrPriceNotFound
+	}
+
+	return nil
+}
+
+// ListPrices returns a product's price list ordered by currency.
+func (r *PriceRepository) ListPrices(ctx context.Context, productID int64) ([]models.CurrencyPrice, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT product_id, currency, amount, created_at, updated_at
+		FROM product_prices
+		WHERE product_id = $1
+		ORDER BY currency
+	`, productID)
+	if err != nil {
+		r.logger.Error("Failed to list prices", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := []models.CurrencyPrice{}
+	for rows.Next() {
+		var p models.CurrencyPrice
+		if err := rows.Scan(&p.ProductID, &p.Currency, &p.Amount, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			r.logger.Error("Failed to scan price", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan price: %w", err)
+		}
+		prices = append(prices, p)
+	}
+
+	return prices, rows.Err()
+}
+
+// FindPrices returns the listed prices in currency of the given products,
+// keyed by product ID. Products without one are left out.
+func (r *PriceRepository) FindPrices(ctx context.Context, productIDs []int64, currency string) (map[int64]models.Amount, error) {
+	prices := make(map[int64]models.Amount)
+	if len(productIDs) == 0 {
+		return prices, nil
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT product_id, amount FROM product_prices WHERE product_id = ANY($1) AND currency = $2`,
+		pq.Array(productIDs), currency,
+	)
+	if err != nil {
+		r.logger.Error("Failed to find prices", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve prices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID int64
+		var amount models.Amount
+		if err := rows.Scan(&productID, &amount); err != nil {
+			r.logger.Error("Failed to scan price", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan price: %w", err)
+		}
+		prices[productID] = amount
+	}
+
+	return prices, rows.Err()
+}
+
+const priceScheduleColumns = `id, product_id, sale_price, starts_at, ends_at, COALESCE(label, ''), created_by,
+	start_published_at, end_published_at, created_at`
+
+func (r *PriceRepository) CreateSchedule(ctx context.Context, schedule *models.PriceSchedule) error {
+	query := `
+		INSERT INTO price_schedules (product_id, sale_price, starts_at, ends_at, label, created_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query,
+		schedule.ProductID, schedule.SalePrice, schedule.StartsAt, schedule.EndsAt, schedule.Label, schedule.CreatedBy,
+	).Scan(&schedule.ID, &schedule.CreatedAt)
+	if err != nil {
+		if isPQError(err, pqExclusionViolation) {
+			return ErrScheduleOverlap
+		}
+		if isPQError(err, pqForeignKeyViolation) {
+			return ErrProductNotFound
+		}
+		r.logger.Error("Failed to create price schedule", logger.Error(err))
+		return fmt.Errorf("failed to create price schedule: %w", err)
+	}
+
+	return nil
+}
+
+// FindScheduleForUpdate returns a schedule of the given product and locks
+// it until the surrounding transaction ends.
+func (r *PriceRepository) FindScheduleForUpdate(ctx context.Context, productID, id int64) (*models.PriceSchedule, error) {
+	query := `SELECT ` + priceScheduleColumns + ` FROM price_schedules WHERE id = $1 AND product_id = $2 FOR UPDATE`
+
+	schedule, err := scanPriceSchedule(conn(ctx, r.db).QueryRowContext(ctx, query, id, productID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrScheduleNotFound
+		}
+		r.logger.Error("Failed to find price schedule", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve price schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (r *PriceRepository) DeleteSchedule(ctx context.Context, id int64) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM price_schedules WHERE id = $1`, id); err != nil {
+		r.logger.Error("Failed to delete price schedule", logger.Error(err))
+		return fmt.Errorf("failed to delete price schedule: %w", err)
+	}
+
+	return nil
+}
+
+// ListSchedules returns a product's price schedules in the order they
+// start.
+func (r *PriceRepository) ListSchedules(ctx context.Context, productID int64) ([]models.PriceSchedule, error) {
+	return r.querySchedules(ctx,
+		`SELECT `+priceScheduleColumns+` FROM price_schedules WHERE product_id = $1 ORDER BY starts_at`,
+		productID,
+	)
+}
+
+// FindCurrentSchedules returns the schedules of the given products that
+// have not ended at now, in the order they start.
+func (r *PriceRepository) FindCurrentSchedules(ctx context.Context, productIDs []int64, now time.Time) ([]models.PriceSchedule, error) {
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	return r.querySchedules(ctx,
+		`SELECT `+priceScheduleColumns+` FROM price_schedules
+		 WHERE product_id = ANY($1) AND ends_at > $2
+		 ORDER BY starts_at`,
+		pq.Array(productIDs), now,
+	)
+}
+
+// ClaimDueSchedules returns up to limit schedules that started or ended by
+// now without being announced, and locks them until the surrounding
+// transaction ends. Schedules locked by another transaction are skipped,
+// so several instances can announce in parallel.
+func (r *PriceRepository) ClaimDueSchedules(ctx context.Context, now time.Time, limit int) ([]models.PriceSchedule, error) {
+	return r.querySchedules(ctx,
+		`SELECT `+priceScheduleColumns+` FROM price_schedules
+		 WHERE (start_published_at IS NULL AND starts_at <= $1)
+		    OR (end_published_at IS NULL AND ends_at <= $1)
+		 ORDER BY LEAST(starts_at, ends_at)
+		 LIMIT $2
+		 FOR UPDATE SKIP LOCKED`,
+		now, limit,
+	)
+}
+
+// MarkSchedulePublished records the announcement times of a schedule.
+func (r *PriceRepository) MarkSchedulePublished(ctx context.Context, schedule *models.PriceSchedule) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE price_schedules SET start_published_at = $1, end_published_at = $2 WHERE id = $3`,
+		schedule.StartPublishedAt, schedule.EndPublishedAt, schedule.ID,
+	)
+	if err != nil {
+		r.logger.Error("Failed to mark price schedule published", logger.Error(err))
+		return fmt.Errorf("failed to update price schedule: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PriceRepository) querySchedules(ctx context.Context, query string, args ...interface{}) ([]models.PriceSchedule, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("Failed to list price schedules", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve price schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := []models.PriceSchedule{}
+	for rows.Next() {
+		schedule, err := scanPriceSchedule(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan price schedule", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan price schedule: %w", err)
+		}
+		schedules = append(schedules, *schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+func scanPriceSchedule(row rowScanner) (*models.PriceSchedule, error) {
+	schedule := &models.PriceSchedule{}
+	err := row.Scan(
+		&schedule.ID,
+		&schedule.ProductID,
+		&schedule.SalePrice,
+		&schedule.StartsAt,
+		&schedule.EndsAt,
+		&schedule.Label,
+		&schedule.CreatedBy,
+		&schedule.StartPublishedAt,
+		&schedule.EndPublishedAt,
+		&schedule.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// RecordChange adds a change to the price history of a product.
+func (r *PriceRepository) RecordChange(ctx context.Context, change *models.PriceChange) error {
+	query := `
+		INSERT INTO product_price_history (product_id, currency, old_price, new_price, changed_by, reason, schedule_id, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query,
+		change.ProductID, change.Currency, change.OldPrice, change.NewPrice,
+		change.ChangedBy, change.Reason, change.ScheduleID, change.ChangedAt,
+	).Scan(&change.ID)
+	if err != nil {
+		r.logger.Error("Failed to record price change", logger.Error(err))
+		return fmt.Errorf("failed to record price change: %w", err)
+	}
+
+	return nil
+}
+
+// ListChanges returns the price changes of a product from from until to,
+// oldest first.
+func (r *PriceRepository) ListChanges(ctx context.Context, productID int64, from, to time.Time) ([]models.PriceChange, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT id, product_id, currency, old_price, new_price, changed_by, reason, schedule_id, changed_at
+		FROM product_price_history
+		WHERE product_id = $1 AND changed_at >= $2 AND changed_at < $3
+		ORDER BY changed_at, id
+	`, productID, from, to)
+	if err != nil {
+		r.logger.Error("Failed to list price changes", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve price history: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.PriceChange{}
+	for rows.Next() {
+		var c models.PriceChange
+		err := rows.Scan(&c.ID, &c.ProductID, &c.Currency, &c.OldPrice, &c.NewPrice,
+			&c.ChangedBy, &c.Reason, &c.ScheduleID, &c.ChangedAt)
+		if err != nil {
+			r.logger.Error("Failed to scan price change", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan price change: %w", err)
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// PriceStats fills in the lowest, highest and time-weighted average price
+// in stats.Currency of a product from stats.From until stats.To. Every
+// change starts a span that lasts until the next one; spans are clipped to
+// the period, and empty ones never applied.
+func (r *PriceRepository) PriceStats(ctx context.Context, productID int64, stats *models.PriceStats) error {
+	query := `
+		WITH spans AS (
+			SELECT currency, new_price, changed_at AS since,
+				LEAD(changed_at) OVER (ORDER BY changed_at, id) AS until
+			FROM product_price_history
+			WHERE product_id = $1
+		), clipped AS (
+			SELECT new_price, GREATEST(since, $3) AS since, LEAST(COALESCE(until, $4), $4) AS until
+			FROM spans
+			WHERE currency = $2 AND since < $4 AND (until IS NULL OR (until > $3 AND until > since))
+		)
+		SELECT MIN(new_price), MAX(new_price),
+			ROUND(SUM(new_price * EXTRACT(EPOCH FROM until - since)) / NULLIF(SUM(EXTRACT(EPOCH FROM until - since)), 0), 2)
+		FROM clipped
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, productID, stats.Currency, stats.From, stats.To).
+		Scan(&stats.Min, &stats.Max, &stats.Average)
+	if err != nil {
+		r.logger.Error("Failed to compute price stats", logger.Error(err))
+		return fmt.Errorf("failed to compute price stats: %w", err)
+	}
+
+	return nil
+}
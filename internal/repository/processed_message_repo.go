@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"product-management/pkg/logger"
+)
+
+// ProcessedMessageRepository remembers which messages were already handled.
+type ProcessedMessageRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewProcessedMessageRepository(db *sql.DB, logger *logger.Logger) *ProcessedMessageRepository {
+	return &ProcessedMessageRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// IsProcessed reports whether messageID was already handled.
+func (r *ProcessedMessageRepository) IsProcessed(ctx context.Context, messageID string) (bool, error) {
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM processed_messages WHERE message_id = $1)`,
+		messageID,
+	).Scan(&exists)
+	if err != nil {
+		r.logger.Error("Failed to look up processed message", logger.Error(err))
+		return false, fmt.Errorf("failed to look up processed message: %w", err)
+	}
+
+	return exists, nil
+}
+
+// MarkProcessed records messageID and reports whether this call recorded
+// it. It returns false if another consumer recorded it first; called within
+// the transaction of the work, that transaction should then be rolled back.
+func (r *ProcessedMessageRepository) MarkProcessed(ctx context.Context, messageID string, productID int64, imageListHash string) (bool, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `
+		INSERT INTO processed_messages (message_id, product_id, content_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (message_id) DO NOTHING
+	`, messageID, productID, imageListHash)
+	if err != nil {
+		r.logger.Error("Failed to record processed message", logger.Error(err))
+		return false, fmt.Errorf("failed to record processed message: %w", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record processed message: %w", err)
+	}
+
+	return inserted == 1, nil
+}
+
+// DeleteProcessedBefore forgets messages processed before cutoff and
+// returns how many were removed.
+func (r *ProcessedMessageRepository) DeleteProcessedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`DELETE FROM processed_messages WHERE processed_at < $1`,
+		cutoff,
+	)
+	if err != nil {
+		r.logger.Error("Failed to delete processed messages", logger.Error(err))
+		return 0, fmt.Errorf("failed to delete processed messages: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -3,7 +3,14 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 
 	"product-management/internal/models"
 	"product-management/pkg/logger"
@@ -11,6 +18,9 @@ import (
 	"github.com/lib/pq"
 )
 
+// ErrProductNotFound is returned when no product matches the given ID.
+var ErrProductNotFound = errors.New("product not found")
+
 type ProductRepository struct {
 	db     *sql.DB
 	logger *logger.Logger
@@ -26,22 +36,27 @@ func NewProductRepository(db *sql.DB, logger *logger.Logger) *ProductRepository
 func (r *ProductRepository) Create(ctx context.Context, product *models.Product) error {
 	query := `
 		INSERT INTO products 
-		(user_id, product_name, product_description, product_price, product_images) 
-		VALUES ($1, $2, $3, $4, $5)
+		(user_id, product_name, product_description, product_price, currency, product_images, tags) 
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, '{}'::TEXT[]))
 		RETURNING id, created_at, updated_at
 	`
 
-	err := r.db.QueryRowContext(
-		ctx, 
-		query, 
-		product.UserID, 
-		product.ProductName, 
-		product.ProductDescription, 
-		product.ProductPrice, 
-		pq.Array(product.ProductImages),
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		product.UserID,
+		product.ProductName,
+		product.ProductDescription,
+		product.ProductPrice,
+		product.Currency,
+		product.ProductImages,
+		product.Tags,
 	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
 
 	if err != nil {
+		if isPQError(err, pqForeignKeyViolation) {
+			return ErrUserNotFound
+		}
 		r.logger.Error("Failed to create product", logger.Error(err))
 		return fmt.Errorf("failed to create product: %w", err)
 	}
@@ -50,30 +65,12 @@ func (r *ProductRepository) Create(ctx context.Context, product *models.Product)
 }
 
 func (r *ProductRepository) FindByID(ctx context.Context, id int64) (*models.Product, error) {
-	query := `
-		SELECT id, user_id, product_name, product_description, 
-		       product_price, product_images, compressed_product_images, 
-		       created_at, updated_at 
-		FROM products 
-		WHERE id = $1
-	`
-
-	product := &models.Product{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&product.ID, 
-		&product.UserID, 
-		&product.ProductName, 
-		&product.ProductDescription, 
-		&product.ProductPrice, 
-		pq.Array(&product.ProductImages),
-		pq.Array(&product.CompressedProductImages),
-		&product.CreatedAt, 
-		&product.UpdatedAt,
-	)
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
 
+	product, err := scanProduct(conn(ctx, r.db).QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("product not found")
+			return nil, ErrProductNotFound
 		}
 		r.logger.Error("Failed to find product", logger.Error(err))
 		return nil, fmt.Errorf("failed to retrieve product: %w", err)
@@ -82,112 +79,710 @@ func (r *ProductRepository) FindByID(ctx context.Context, id int64) (*models.Pro
 	return product, nil
 }
 
-func (r *ProductRepository) FindByUserID(ctx context.Context, params *models.ProductFilterParams) ([]models.Product, int, error) {
-	baseQuery := `
-		SELECT id, user_id, product_name, product_description, 
-		       product_price, product_images, compressed_product_images, 
-		       created_at, updated_at 
-		FROM products 
-		WHERE user_id = $1
-	`
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM products 
-		WHERE user_id = $1
-	`
+// productColumns are the products columns scanned by scanProduct.
+const productColumns = `id, user_id, product_name, product_description,
+		       product_price, currency, product_images, compressed_product_images,
+		       tags, created_at, updated_at`
 
+// List returns one page of products matching params, across every seller
+// unless params.UserID is set. Products with equal sort keys are ordered by
+// ID so pages are stable. Pages start after params.After when set, which
+// stays correct while products are added and is as fast on deep pages as
+// on the first one; otherwise params.Page is used.
+func (r *ProductRepository) List(ctx context.Context, params *models.ProductFilterParams) (*models.ProductPage, error) {
 	var conditions []string
 	var args []interface{}
-	args = append(args, params.UserID)
-	argPos := 2
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
 
+	if params.UserID != 0 {
+		conditions = append(conditions, "user_id = "+addArg(params.UserID))
+	}
 	if params.MinPrice > 0 {
-		conditions = append(conditions, fmt.Sprintf("product_price >= $%d", argPos))
-		args = append(args, params.MinPrice)
-		argPos++
+		conditions = append(conditions, "product_price >= "+addArg(params.MinPrice))
 	}
-
 	if params.MaxPrice > 0 {
-		conditions = append(conditions, fmt.Sprintf("product_price <= $%d", argPos))
-		args = append(args, params.MaxPrice)
-		argPos++
+		conditions = append(conditions, "product_price <= "+addArg(params.MaxPrice))
 	}
-
 	if params.ProductName != "" {
-		conditions = append(conditions, fmt.Sprintf("product_name ILIKE $%d", argPos))
-		args = append(args, "%"+params.ProductName+"%")
-		argPos++
+		conditions = append(conditions, "product_name ILIKE "+addArg("%"+params.ProductName+"%"))
+	}
+	if params.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= "+addArg(*params.CreatedAfter))
+	}
+	if params.CreatedBefore != nil {
+		conditions = append(conditions, "created_at < "+addArg(*params.CreatedBefore))
+	}
+	if params.UpdatedAfter != nil {
+		conditions = append(conditions, "updated_at >= "+addArg(*params.UpdatedAfter))
+	}
+	if params.UpdatedBefore != nil {
+		conditions = append(conditions, "updated_at < "+addArg(*params.UpdatedBefore))
+	}
+	if params.HasCompressedImages != nil {
+		if *params.HasCompressedImages {
+			conditions = append(conditions, "cardinality(compressed_product_images) > 0")
+		} else {
+			conditions = append(conditions, "COALESCE(cardinality(compressed_product_images), 0) = 0")
+		}
+	}
+	if len(params.Tags) > 0 {
+		conditions = append(conditions, "tags @> "+addArg(pq.Array(params.Tags)))
+	}
+	if len(params.Attributes) > 0 {
+		attributes, err := json.Marshal(params.Attributes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal attribute filter: %w", err)
+		}
+		conditions = append(conditions,
+			"id IN (SELECT product_id FROM product_variants WHERE attributes @> "+addArg(string(attributes))+"::JSONB)")
+	}
+	if params.CategoryID != 0 {
+		if params.IncludeDescendants {
+			// The subtree is every path starting with the category's own,
+			// a prefix match idx_categories_path serves
+			conditions = append(conditions, `id IN (
+				SELECT pc.product_id FROM product_categories pc
+				JOIN categories c ON c.id = pc.category_id
+				WHERE c.path LIKE (SELECT path FROM categories WHERE id = `+addArg(params.CategoryID)+`) || '%')`)
+		} else {
+			conditions = append(conditions,
+				"id IN (SELECT product_id FROM product_categories WHERE category_id = "+addArg(params.CategoryID)+")")
+		}
 	}
 
-	// Apply conditions
+	rank := "0"
+	if params.Query != "" {
+		query := "websearch_to_tsquery('english', " + addArg(params.Query) + ")"
+		conditions = append(conditions, "search_vector @@ "+query)
+		rank = "ts_rank_cd(search_vector, " + query + ")"
+	}
+
+	page := &models.ProductPage{Products: []models.Product{}}
+
+	// Count before the cursor narrows the conditions down
+	switch params.Count {
+	case models.CountNone:
+	case models.CountEstimated:
+		total, err := r.estimateCount(ctx, conditions, args)
+		if err != nil {
+			return nil, err
+		}
+		page.TotalCount = &total
+		page.CountEstimated = true
+	default:
+		total, err := r.count(ctx, conditions, args)
+		if err != nil {
+			return nil, err
+		}
+		page.TotalCount = &total
+	}
+
+	if params.Facets {
+		facets, err := r.facets(ctx, conditions, args, params.PriceBuckets)
+		if err != nil {
+			return nil, err
+		}
+		page.Facets = facets
+	}
+
+	sortColumn, sortType, ok := productSortColumn(params.Sort, rank)
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort key %q", params.Sort)
+	}
+
+	// A backward page is read in reverse order from its cursor and flipped
+	// afterwards
+	backward := params.After != nil && params.After.Backward
+	descending := (params.Order == models.OrderDesc) != backward
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if params.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s::%s, %s)",
+			sortColumn, comparison, addArg(params.After.Key), sortType, addArg(params.After.ID)))
+	}
+
+	where := ""
 	if len(conditions) > 0 {
-		baseQuery += " AND " + strings.Join(conditions, " AND ")
-		countQuery += " AND " + strings.Join(conditions, " AND ")
+		where = " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	// Pagination
+	// Pagination; one extra row tells whether there is a further page
 	if params.Page < 1 {
 		params.Page = 1
 	}
 	if params.PageSize < 1 {
 		params.PageSize = 10
 	}
-	offset := (params.Page - 1) * params.PageSize
-	baseQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argPos, argPos+1)
-	args = append(args, params.PageSize, offset)
+	offset := 0
+	if params.After == nil {
+		offset = (params.Page - 1) * params.PageSize
+	}
 
-	// Get total count
-	var totalCount int
-	err := r.db.QueryRowContext(ctx, countQuery, args[:len(args)-2]...).Scan(&totalCount)
+	query := `SELECT ` + productColumns + `, (` + sortColumn + `)::TEXT FROM products` + where +
+		fmt.Sprintf(" ORDER BY %s %s, id %s", sortColumn, direction, direction) +
+		fmt.Sprintf(" LIMIT %s OFFSET %s", addArg(params.PageSize+1), addArg(offset))
+
+	// Execute query
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
 	if err != nil {
+		r.logger.Error("Failed to find products", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		product, err := scanProduct(rows, &key)
+		if err != nil {
+			r.logger.Error("Failed to scan product", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		page.Products = append(page.Products, *product)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to retrieve products: %w", err)
+	}
+
+	more := len(page.Products) > params.PageSize
+	if more {
+		page.Products = page.Products[:params.PageSize]
+		keys = keys[:params.PageSize]
+	}
+	if backward {
+		slices.Reverse(page.Products)
+		slices.Reverse(keys)
+	}
+	if len(page.Products) == 0 {
+		return page, nil
+	}
+
+	cursorAt := func(i int, backward bool) *models.ProductCursor {
+		return &models.ProductCursor{
+			Sort:     params.Sort,
+			Order:    params.Order,
+			Key:      keys[i],
+			ID:       page.Products[i].ID,
+			Backward: backward,
+		}
+	}
+	last := len(page.Products) - 1
+	if backward {
+		// Coming back from a later page, so there is always a next one
+		page.Next = cursorAt(last, false)
+		if more {
+			page.Prev = cursorAt(0, true)
+		}
+	} else {
+		if more {
+			page.Next = cursorAt(last, false)
+		}
+		if params.After != nil || offset > 0 {
+			page.Prev = cursorAt(0, true)
+		}
+	}
+
+	return page, nil
+}
+
+// productSortColumn returns the expression and Postgres type of a sort
+// key. rank is the relevance expression of the listing.
+func productSortColumn(sort, rank string) (string, string, bool) {
+	switch sort {
+	case models.SortPrice:
+		return "product_price", "NUMERIC", true
+	case models.SortCreatedAt:
+		return "created_at", "TIMESTAMPTZ", true
+	case models.SortName:
+		return "product_name", "TEXT", true
+	case models.SortRelevance:
+		return rank, "REAL", true
+	}
+	return "", "", false
+}
+
+func (r *ProductRepository) count(ctx context.Context, conditions []string, args []interface{}) (int, error) {
+	query := `SELECT COUNT(*) FROM products`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
 		r.logger.Error("Failed to count products", logger.Error(err))
-		return nil, 0, fmt.Errorf("failed to count products: %w", err)
+		return 0, fmt.Errorf("failed to count products: %w", err)
 	}
 
-	// Execute query
-	rows, err := r.db.QueryContext(ctx, baseQuery, args...)
+	return total, nil
+}
+
+// facetTagLimit caps the tag facet to the most used tags.
+const facetTagLimit = 50
+
+// facets counts the products matching conditions by tag, by category and
+// by price bucket, in one round trip. A product counts towards a category
+// and all of its ancestors, like the include_descendants filter.
+func (r *ProductRepository) facets(ctx context.Context, conditions []string, args []interface{}, priceBuckets []models.Amount) (*models.ProductFacets, error) {
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Leave the caller's args alone; the listing appends its own
+	args = append(args[:len(args):len(args)], pq.Array(priceBuckets))
+
+	query := `
+		WITH matched AS (
+			SELECT id, tags, product_price FROM products` + where + `
+		)
+		SELECT
+			(SELECT COALESCE(json_agg(t ORDER BY t.count DESC, t.value), '[]')
+			 FROM (
+				SELECT tag AS value, COUNT(*) AS count
+				FROM matched, unnest(tags) AS tag
+				GROUP BY tag
+				ORDER BY count DESC, tag
+				LIMIT ` + strconv.Itoa(facetTagLimit) + `
+			 ) t),
+			(SELECT COALESCE(json_agg(c ORDER BY c.path), '[]')
+			 FROM (
+				SELECT a.id, a.parent_id, a.name, a.slug, a.path, COUNT(DISTINCT m.id) AS count
+				FROM matched m
+				JOIN product_categories pc ON pc.product_id = m.id
+				JOIN categories c ON c.id = pc.category_id
+				JOIN categories a ON a.id = ANY(string_to_array(trim(BOTH '/' FROM c.path), '/')::BIGINT[])
+				GROUP BY a.id
+			 ) c),
+			(SELECT COALESCE(json_agg(p ORDER BY p.bucket), '[]')
+			 FROM (
+				SELECT width_bucket(product_price, $` + strconv.Itoa(len(args)) + `::NUMERIC[]) AS bucket, COUNT(*) AS count
+				FROM matched
+				GROUP BY bucket
+			 ) p)
+	`
+
+	var tags, categories, prices []byte
+	if err := conn(ctx, r.db).QueryRowContext(ctx, query, args...).Scan(&tags, &categories, &prices); err != nil {
+		r.logger.Error("Failed to count product facets", logger.Error(err))
+		return nil, fmt.Errorf("failed to count product facets: %w", err)
+	}
+
+	facets := &models.ProductFacets{}
+	var buckets []struct {
+		Bucket int `json:"bucket"`
+		Count  int `json:"count"`
+	}
+	for _, facet := range []struct {
+		data []byte
+		dest interface{}
+	}{{tags, &facets.Tags}, {categories, &facets.Categories}, {prices, &buckets}} {
+		if err := json.Unmarshal(facet.data, facet.dest); err != nil {
+			return nil, fmt.Errorf("failed to parse product facets: %w", err)
+		}
+	}
+
+	// width_bucket numbers the range below the first bound 0 and the one
+	// from the last bound on len(priceBuckets); report every range, empty
+	// ones included, so storefronts can render a stable list
+	counts := make(map[int]int, len(buckets))
+	for _, b := range buckets {
+		counts[b.Bucket] = b.Count
+	}
+	facets.Prices = make([]models.PriceFacet, 0, len(priceBuckets)+1)
+	for i := 0; i <= len(priceBuckets); i++ {
+		price := models.PriceFacet{Count: counts[i]}
+		if i > 0 {
+			price.Min = &priceBuckets[i-1]
+		}
+		if i < len(priceBuckets) {
+			price.Max = &priceBuckets[i]
+		}
+		facets.Prices = append(facets.Prices, price)
+	}
+
+	return facets, nil
+}
+
+// estimateCount returns the planner's estimate of the matching products
+// instead of counting them: the table statistics in pg_class without
+// filters, the row estimate of the query plan with them.
+func (r *ProductRepository) estimateCount(ctx context.Context, conditions []string, args []interface{}) (int, error) {
+	if len(conditions) == 0 {
+		var estimate float64
+		err := conn(ctx, r.db).QueryRowContext(ctx,
+			`SELECT reltuples FROM pg_class WHERE oid = 'products'::regclass`,
+		).Scan(&estimate)
+		if err != nil {
+			r.logger.Error("Failed to estimate product count", logger.Error(err))
+			return 0, fmt.Errorf("failed to estimate product count: %w", err)
+		}
+		// Tables never analyzed report -1
+		return int(math.Max(estimate, 0)), nil
+	}
+
+	var plan []byte
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`EXPLAIN (FORMAT JSON) SELECT 1 FROM products WHERE `+strings.Join(conditions, " AND "), args...,
+	).Scan(&plan)
 	if err != nil {
-		r.logger.Error("Failed to find products", logger.Error(err))
-		return nil, 0, fmt.Errorf("failed to retrieve products: %w", err)
+		r.logger.Error("Failed to estimate product count", logger.Error(err))
+		return 0, fmt.Errorf("failed to estimate product count: %w", err)
+	}
+
+	var explained []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &explained); err != nil {
+		return 0, fmt.Errorf("failed to parse query plan: %w", err)
+	}
+	if len(explained) == 0 {
+		return 0, errors.New("failed to parse query plan: empty plan")
+	}
+
+	return int(explained[0].Plan.Rows), nil
+}
+
+// Highlight delimiters ts_headline puts around matched words. Control
+// characters cannot occur in product text, so the snippet can be escaped
+// before they are turned into markup.
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// Search finds products matching a web search query, best ranked first.
+// With params.Fuzzy, names similar to the query match as well and the
+// similarity adds to the rank.
+func (r *ProductRepository) Search(ctx context.Context, params *models.ProductSearchParams) ([]models.ProductSearchResult, int, error) {
+	match := `p.search_vector @@ q.query`
+	rank := `ts_rank_cd(p.search_vector, q.query)`
+	if params.Fuzzy {
+		match = `(` + match + ` OR p.product_name % $1)`
+		rank += ` + similarity(p.product_name, $1)`
+	}
+
+	countQuery := `
+		SELECT COUNT(*)
+		FROM products p, websearch_to_tsquery('english', $1) AS q(query)
+		WHERE ` + match
+
+	// Snippets are built only for the rows of the page, not for every match
+	query := `
+		SELECT p.id, p.user_id, p.product_name, p.product_description,
+		       p.product_price, p.currency, p.product_images, p.compressed_product_images,
+		       p.tags, p.created_at, p.updated_at, p.rank,
+		       ts_headline('english', p.product_name || ': ' || COALESCE(p.product_description, ''), q.query,
+		                   'StartSel=' || $4 || ', StopSel=' || $5 || ', MaxWords=30, MinWords=10, MaxFragments=2')
+		FROM (
+			SELECT p.*, ` + rank + ` AS rank
+			FROM products p, websearch_to_tsquery('english', $1) AS q(query)
+			WHERE ` + match + `
+			ORDER BY rank DESC, p.id
+			LIMIT $2 OFFSET $3
+		) p, websearch_to_tsquery('english', $1) AS q(query)
+		ORDER BY p.rank DESC, p.id
+	`
+
+	var totalCount int
+	err := conn(ctx, r.db).QueryRowContext(ctx, countQuery, params.Query).Scan(&totalCount)
+	if err != nil {
+		r.logger.Error("Failed to count search results", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	offset := (params.Page - 1) * params.PageSize
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query,
+		params.Query, params.PageSize, offset, highlightStart, highlightStop,
+	)
+	if err != nil {
+		r.logger.Error("Failed to search products", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to search products: %w", err)
 	}
 	defer rows.Close()
 
-	var products []models.Product
+	results := []models.ProductSearchResult{}
 	for rows.Next() {
-		var product models.Product
+		var rank float64
+		var snippet string
+		product, err := scanProduct(rows, &rank, &snippet)
+		if err != nil {
+			r.logger.Error("Failed to scan search result", logger.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		results = append(results, models.ProductSearchResult{
+			Product: *product,
+			Rank:    rank,
+			Snippet: highlightSnippet(snippet),
+		})
+	}
+
+	return results, totalCount, rows.Err()
+}
+
+// highlightSnippet escapes a ts_headline snippet and marks the matched
+// words with <mark> elements.
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer(
+		highlightStart, "<mark>",
+		highlightStop, "</mark>",
+	).Replace(html.EscapeString(snippet))
+}
+
+func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
+	query := `
+		UPDATE products
+		SET product_name = $1, product_description = $2, product_price = $3, currency = $4,
+		    product_images = $5, compressed_product_images = $6, tags = COALESCE($7, '{}'::TEXT[]),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8
+		RETURNING user_id, created_at, updated_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		product.ProductName,
+		product.ProductDescription,
+		product.ProductPrice,
+		product.Currency,
+		product.ProductImages,
+		product.CompressedProductImages,
+		product.Tags,
+		product.ID,
+	).Scan(&product.UserID, &product.CreatedAt, &product.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+		r.logger.Error("Failed to update product", logger.Error(err))
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a product and returns the owning user ID so callers can
+// invalidate that user's cached listings.
+func (r *ProductRepository) Delete(ctx context.Context, id int64) (int64, error) {
+	query := `
+		DELETE FROM products
+		WHERE id = $1
+		RETURNING user_id
+	`
+
+	var userID int64
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrProductNotFound
+		}
+		r.logger.Error("Failed to delete product", logger.Error(err))
+		return 0, fmt.Errorf("failed to delete product: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ReplaceImageVariants swaps a product's renditions for a new set and
+// records the primary rendition URLs in compressed_product_images, all in
+// one transaction. It returns the owning user ID.
+func (r *ProductRepository) ReplaceImageVariants(ctx context.Context, productID int64, variants []models.ImageVariant, compressedImages []string) (int64, error) {
+	var userID int64
+	err := runInTx(ctx, r.db, func(ctx context.Context) error {
+		err := conn(ctx, r.db).QueryRowContext(ctx, `
+			UPDATE products
+			SET compressed_product_images = $1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $2
+			RETURNING user_id
+		`, pq.Array(compressedImages), productID).Scan(&userID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrProductNotFound
+			}
+			r.logger.Error("Failed to update compressed images", logger.Error(err))
+			return fmt.Errorf("failed to update compressed images: %w", err)
+		}
+
+		if err := r.DeleteImageVariants(ctx, productID); err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO product_image_variants
+			(product_id, image_index, source_image, source_hash, variant, url, format, width, height, size_bytes)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10)
+		`
+		for _, v := range variants {
+			_, err := conn(ctx, r.db).ExecContext(ctx, query,
+				productID, v.ImageIndex, v.SourceImage, v.SourceHash, v.Name, v.URL, v.Format, v.Width, v.Height, v.SizeBytes,
+			)
+			if err != nil {
+				r.logger.Error("Failed to insert image variant", logger.Error(err))
+				return fmt.Errorf("failed to insert image variant: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// FindImageVariants returns a product's renditions ordered by source image.
+func (r *ProductRepository) FindImageVariants(ctx context.Context, productID int64) ([]models.ImageVariant, error) {
+	query := `
+		SELECT product_id, image_index, source_image, COALESCE(source_hash, ''),
+		       variant, url, format, width, height, size_bytes
+		FROM product_image_variants
+		WHERE product_id = $1
+		ORDER BY image_index, id
+	`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, productID)
+	if err != nil {
+		r.logger.Error("Failed to find image variants", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve image variants: %w", err)
+	}
+	defer rows.Close()
+
+	var variants []models.ImageVariant
+	for rows.Next() {
+		var v models.ImageVariant
 		err := rows.Scan(
-			&product.ID, 
-			&product.UserID, 
-			&product.ProductName, 
-			&product.ProductDescription, 
-			&product.ProductPrice, 
-			pq.Array(&product.ProductImages),
-			pq.Array(&product.CompressedProductImages),
-			&product.CreatedAt, 
-			&product.UpdatedAt,
+			&v.ProductID,
+			&v.ImageIndex,
+			&v.SourceImage,
+			&v.SourceHash,
+			&v.Name,
+			&v.URL,
+			&v.Format,
+			&v.Width,
+			&v.Height,
+			&v.SizeBytes,
 		)
 		if err != nil {
-			r.logger.Error("Failed to scan product", logger.Error(err))
-			return nil, 0, fmt.Errorf("failed to scan product: %w", err)
+			r.logger.Error("Failed to scan image variant", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan image variant: %w", err)
 		}
-		products = append(products, product)
+		variants = append(variants, v)
+	}
+
+	return variants, rows.Err()
+}
+
+// DeleteImageVariants drops every rendition of a product, e.g. after its
+// source images were replaced.
+func (r *ProductRepository) DeleteImageVariants(ctx context.Context, productID int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM product_image_variants WHERE product_id = $1`, productID)
+	if err != nil {
+		r.logger.Error("Failed to delete image variants", logger.Error(err))
+		return fmt.Errorf("failed to delete image variants: %w", err)
 	}
 
-	return products, totalCount, nil
+	return nil
+}
+
+// ReplaceCategories assigns a product to exactly the given categories.
+func (r *ProductRepository) ReplaceCategories(ctx context.Context, productID int64, categoryIDs []int64) error {
+	return runInTx(ctx, r.db, func(ctx context.Context) error {
+		_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM product_categories WHERE product_id = $1`, productID)
+		if err != nil {
+			r.logger.Error("Failed to clear product categories", logger.Error(err))
+			return fmt.Errorf("failed to clear product categories: %w", err)
+		}
+		if len(categoryIDs) == 0 {
+			return nil
+		}
+
+		_, err = conn(ctx, r.db).ExecContext(ctx, `
+			INSERT INTO product_categories (product_id, category_id)
+			SELECT $1, unnest($2::BIGINT[])
+			ON CONFLICT DO NOTHING
+		`, productID, pq.Array(categoryIDs))
+		if err != nil {
+			if isPQError(err, pqForeignKeyViolation) {
+				return ErrUnknownCategory
+			}
+			r.logger.Error("Failed to assign product categories", logger.Error(err))
+			return fmt.Errorf("failed to assign product categories: %w", err)
+		}
+
+		return nil
+	})
 }
 
-func (r *ProductRepository) UpdateCompressedImages(ctx context.Context, productID int64, compressedImages []string) error {
+// FindCategories returns the categories a product is assigned to, each
+// with its breadcrumb from the root.
+func (r *ProductRepository) FindCategories(ctx context.Context, productID int64) ([]models.ProductCategory, error) {
+	// Join every category with its ancestors, which are the IDs on its path
 	query := `
-		UPDATE products 
-		SET compressed_product_images = $1, updated_at = CURRENT_TIMESTAMP 
-		WHERE id = $2
+		SELECT c.id, a.id, a.name, a.slug
+		FROM product_categories pc
+		JOIN categories c ON c.id = pc.category_id
+		JOIN categories a ON a.id = ANY(string_to_array(trim(BOTH '/' FROM c.path), '/')::BIGINT[])
+		WHERE pc.product_id = $1
+		ORDER BY c.path, length(a.path)
 	`
 
-	_, err := r.db.ExecContext(ctx, query, pq.Array(compressedImages), productID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, productID)
 	if err != nil {
-		r.logger.Error("Failed to update compressed images", logger.Error(err))
-		return fmt.Errorf("failed to update compressed images: %w", err)
+		r.logger.Error("Failed to find product categories", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve product categories: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
-}
\ No newline at end of file
+	var categories []models.ProductCategory
+	for rows.Next() {
+		var categoryID int64
+		var step models.CategoryRef
+		if err := rows.Scan(&categoryID, &step.ID, &step.Name, &step.Slug); err != nil {
+			r.logger.Error("Failed to scan product category", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan product category: %w", err)
+		}
+
+		if len(categories) == 0 || categories[len(categories)-1].ID != categoryID {
+			categories = append(categories, models.ProductCategory{CategoryRef: models.CategoryRef{ID: categoryID}})
+		}
+		current := &categories[len(categories)-1]
+		current.Breadcrumbs = append(current.Breadcrumbs, step)
+		if step.ID == categoryID {
+			current.CategoryRef = step
+		}
+	}
+
+	return categories, rows.Err()
+}
+
+// scanProduct scans the productColumns of a row, followed by extra.
+func scanProduct(row rowScanner, extra ...interface{}) (*models.Product, error) {
+	product := &models.Product{}
+	dest := append([]interface{}{
+		&product.ID,
+		&product.UserID,
+		&product.ProductName,
+		&product.ProductDescription,
+		&product.ProductPrice,
+		&product.Currency,
+		&product.ProductImages,
+		&product.CompressedProductImages,
+		&product.Tags,
+		&product.CreatedAt,
+		&product.UpdatedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
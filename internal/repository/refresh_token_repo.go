@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+)
+
+// ErrRefreshTokenNotFound is returned when no refresh token matches a hash.
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type RefreshTokenRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewRefreshTokenRepository(db *sql.DB, logger *logger.Logger) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, token.UserID, token.TokenHash, token.FamilyID, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		r.logger.Error("Failed to create refresh token", logger.Error(err))
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// FindByHashForUpdate returns the token with the given hash and locks it
+// until the surrounding transaction ends, so concurrent refreshes of the
+// same token are serialised.
+func (r *RefreshTokenRepository) FindByHashForUpdate(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+		FOR UPDATE
+	`
+
+	token := &models.RefreshToken{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.FamilyID,
+		&token.ExpiresAt,
+		&token.RevokedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRefreshTokenNotFound
+		}
+		r.logger.Error("Failed to find refresh token", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh token", logger.Error(err))
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes every token descended from the same login.
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`, familyID)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh token family", logger.Error(err))
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every token of a user, signing them out of all
+// sessions.
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		r.logger.Error("Failed to revoke refresh tokens of user", logger.Error(err))
+		return fmt.Errorf("failed to revoke refresh tokens of user: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredBefore removes tokens that expired before cutoff and returns
+// how many were removed.
+func (r *RefreshTokenRepository) DeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1`, cutoff)
+	if err != nil {
+		r.logger.Error("Failed to delete expired refresh tokens", logger.Error(err))
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -1,10 +1,196 @@
 package repository
 
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrUserNotFound is returned when no user matches the given ID or email.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when the username or email is already taken.
+	ErrUserExists = errors.New("username or email already exists")
+	// ErrUserHasProducts is returned when deleting a user who still owns
+	// products.
+	ErrUserHasProducts = errors.New("user still owns products")
+)
+
+// Postgres error codes the repositories translate into sentinel errors.
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+	pqCheckViolation      = "23514"
+	pqExclusionViolation  = "23P01"
+)
+
 type UserRepository struct {
-	// Define repository fields
+	db     *sql.DB
+	logger *logger.Logger
 }
 
-func (ur *UserRepository) CreateUser() error {
-	// Implement user creation logic
+func NewUserRepository(db *sql.DB, logger *logger.Logger) *UserRepository {
+	return &UserRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const userColumns = `id, username, email, role, COALESCE(password_hash, ''), created_at, updated_at`
+
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	query := `
+		INSERT INTO users (username, email, role, password_hash)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		RETURNING id, created_at, updated_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, user.Username, user.Email, user.Role, user.PasswordHash).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if isPQError(err, pqUniqueViolation) {
+			return ErrUserExists
+		}
+		r.logger.Error("Failed to create user", logger.Error(err))
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
 	return nil
 }
+
+func (r *UserRepository) FindByID(ctx context.Context, id int64) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	user, err := scanUser(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		r.logger.Error("Failed to find user", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+
+	return user, nil
+}
+
+// FindByEmail looks a user up by email, ignoring case.
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE LOWER(email) = LOWER($1)`
+
+	user, err := scanUser(conn(ctx, r.db).QueryRowContext(ctx, query, email))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		r.logger.Error("Failed to find user by email", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+
+	return user, nil
+}
+
+// List returns one page of users ordered by ID, and the total user count.
+func (r *UserRepository) List(ctx context.Context, page, pageSize int) ([]models.User, int, error) {
+	var totalCount int
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&totalCount)
+	if err != nil {
+		r.logger.Error("Failed to count users", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users ORDER BY id LIMIT $1 OFFSET $2`
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pageSize, (page-1)*pageSize)
+	if err != nil {
+		r.logger.Error("Failed to list users", logger.Error(err))
+		return nil, 0, fmt.Errorf("failed to retrieve users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan user", logger.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+
+	return users, totalCount, rows.Err()
+}
+
+// Update saves a user's username and email, and the role and password hash
+// if they are set; empty values keep the stored ones.
+func (r *UserRepository) Update(ctx context.Context, user *models.User) error {
+	query := `
+		UPDATE users
+		SET username = $1, email = $2,
+		    role = COALESCE(NULLIF($3, ''), role),
+		    password_hash = COALESCE(NULLIF($4, ''), password_hash),
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING role, created_at, updated_at
+	`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, user.Username, user.Email, user.Role, user.PasswordHash, user.ID).
+		Scan(&user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		if isPQError(err, pqUniqueViolation) {
+			return ErrUserExists
+		}
+		r.logger.Error("Failed to update user", logger.Error(err))
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a user. Users who still own products cannot be deleted.
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		if isPQError(err, pqForeignKeyViolation) {
+			return ErrUserHasProducts
+		}
+		r.logger.Error("Failed to delete user", logger.Error(err))
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if deleted == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// isPQError reports whether err is a Postgres error with the given code.
+func isPQError(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+)
+
+var (
+	// ErrVariantNotFound is returned when no variant of the product matches
+	// the given ID.
+	ErrVariantNotFound = errors.New("variant not found")
+	// ErrSKUExists is returned when another variant already has the SKU.
+	ErrSKUExists = errors.New("sku already exists")
+)
+
+type ProductVariantRepository struct {
+	db     *sql.DB
+	logger *logger.Logger
+}
+
+func NewProductVariantRepository(db *sql.DB, logger *logger.Logger) *ProductVariantRepository {
+	return &ProductVariantRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const variantColumns = `id, product_id, sku, attributes, price, COALESCE(barcode, ''), weight_grams, created_at, updated_at`
+
+func (r *ProductVariantRepository) Create(ctx context.Context, variant *models.ProductVariant) error {
+	attributes, err := json.Marshal(variant.Attributes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal variant attributes: %w", err)
+	}
+
+	query := `
+		INSERT INTO product_variants (product_id, sku, attributes, price, barcode, weight_grams)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)
+		RETURNING id, created_at, updated_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query,
+		variant.ProductID, variant.SKU, string(attributes), variant.Price, variant.Barcode, variant.WeightGrams,
+	).Scan(&variant.ID, &variant.CreatedAt, &variant.UpdatedAt)
+	if err != nil {
+		if isPQError(err, pqUniqueViolation) {
+			return ErrSKUExists
+		}
+		if isPQError(err, pqForeignKeyViolation) {
+			return ErrProductNotFound
+		}
+		r.logger.Error("Failed to create variant", logger.Error(err))
+		return fmt.Errorf("failed to create variant: %w", err)
+	}
+
+	return nil
+}
+
+// FindByID returns a variant of the given product.
+func (r *ProductVariantRepository) FindByID(ctx context.Context, productID, id int64) (*models.ProductVariant, error) {
+	query := `SELECT ` + variantColumns + ` FROM product_variants WHERE id = $1 AND product_id = $2`
+
+	variant, err := scanVariant(conn(ctx, r.db).QueryRowContext(ctx, query, id, productID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrVariantNotFound
+		}
+		r.logger.Error("Failed to find variant", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve variant: %w", err)
+	}
+
+	return variant, nil
+}
+
+// ListByProductID returns a product's variants in the order they were
+// added.
+func (r *ProductVariantRepository) ListByProductID(ctx context.Context, productID int64) ([]models.ProductVariant, error) {
+	query := `SELECT ` + variantColumns + ` FROM product_variants WHERE product_id = $1 ORDER BY id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, productID)
+	if err != nil {
+		r.logger.Error("Failed to list variants", logger.Error(err))
+		return nil, fmt.Errorf("failed to retrieve variants: %w", err)
+	}
+	defer rows.Close()
+
+	variants := []models.ProductVariant{}
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			r.logger.Error("Failed to scan variant", logger.Error(err))
+			return nil, fmt.Errorf("failed to scan variant: %w", err)
+		}
+		variants = append(variants, *variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// Update replaces every editable field of a variant of the given product.
+func (r *ProductVariantRepository) Update(ctx context.Context, variant *models.ProductVariant) error {
+	attributes, err := json.Marshal(variant.Attributes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal variant attributes: %w", err)
+	}
+
+	query := `
+		UPDATE product_variants
+		SET sku = $1, attributes = $2, price = $3, barcode = NULLIF($4, ''),
+		    weight_grams = $5, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $6 AND product_id = $7
+		RETURNING created_at, updated_at
+	`
+
+	err = conn(ctx, r.db).QueryRowContext(ctx, query,
+		variant.SKU, string(attributes), variant.Price, variant.Barcode, variant.WeightGrams, variant.ID, variant.ProductID,
+	).Scan(&variant.CreatedAt, &variant.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrVariantNotFound
+		}
+		if isPQError(err, pqUniqueViolation) {
+			return ErrSKUExists
+		}
+		r.logger.Error("Failed to update variant", logger.Error(err))
+		return fmt.Errorf("failed to update variant: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a variant of the given product.
+func (r *ProductVariantRepository) Delete(ctx context.Context, productID, id int64) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`DELETE FROM product_variants WHERE id = $1 AND product_id = $2`, id, productID)
+	if err != nil {
+		r.logger.Error("Failed to delete variant", logger.Error(err))
+		return fmt.Errorf("failed to delete variant: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete variant: %w", err)
+	}
+	if deleted == 0 {
+		return ErrVariantNotFound
+	}
+
+	return nil
+}
+
+func scanVariant(row rowScanner) (*models.ProductVariant, error) {
+	variant := &models.ProductVariant{}
+	var attributes []byte
+	err := row.Scan(
+		&variant.ID,
+		&variant.ProductID,
+		&variant.SKU,
+		&attributes,
+		&variant.Price,
+		&variant.Barcode,
+		&variant.WeightGrams,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(attributes, &variant.Attributes); err != nil {
+		return nil, fmt.Errorf("failed to parse variant attributes: %w", err)
+	}
+	return variant, nil
+}
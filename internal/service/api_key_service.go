@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognise.
+const apiKeyPrefix = "pmk_"
+
+// APIKeyService issues and checks API keys for machine clients.
+type APIKeyService struct {
+	apiKeyRepo *repository.APIKeyRepository
+	userRepo   *repository.UserRepository
+	validator  *validator.Validate
+	logger     *logger.Logger
+	policy     UserPolicy
+}
+
+func NewAPIKeyService(apiKeyRepo *repository.APIKeyRepository, userRepo *repository.UserRepository, logger *logger.Logger) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+		validator:  validator.New(),
+		logger:     logger,
+	}
+}
+
+// CreateAPIKey issues a key acting as actor. The key is returned only
+// here; afterwards only its hash is known.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, actor *models.Identity, req *models.APIKeyCreateRequest) (*models.CreatedAPIKey, error) {
+	if err := requireUser(actor); err != nil {
+		return nil, err
+	}
+
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(randomBytes(32))
+	apiKey := models.APIKey{
+		UserID:    actor.UserID,
+		Name:      req.Name,
+		Prefix:    key[:len(apiKeyPrefix)+8],
+		KeyHash:   hashToken(key),
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, &apiKey); err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// ListAPIKeys returns the caller's keys.
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, actor *models.Identity) ([]models.APIKey, error) {
+	if err := requireUser(actor); err != nil {
+		return nil, err
+	}
+
+	return s.apiKeyRepo.ListByUserID(ctx, actor.UserID)
+}
+
+// RevokeAPIKey disables a key for good. Admins may revoke anyone's keys.
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, actor *models.Identity, keyID int64) error {
+	key, err := s.apiKeyRepo.FindByID(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.policy.Authorize(actor, ActionDelete, key.UserID); err != nil {
+		return err
+	}
+
+	return s.apiKeyRepo.Revoke(ctx, keyID)
+}
+
+// Authenticate checks an API key and returns the identity it acts as. The
+// owner's role is read on every call, so role changes and deleted owners
+// take effect immediately.
+func (s *APIKeyService) Authenticate(ctx context.Context, key string) (*models.Identity, error) {
+	apiKey, err := s.apiKeyRepo.FindActiveByHash(ctx, hashToken(key))
+	if err == repository.ErrAPIKeyNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := s.userRepo.FindByID(ctx, apiKey.UserID)
+	if err == repository.ErrUserNotFound {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage tracking is best effort and must not fail the request
+	_ = s.apiKeyRepo.TouchLastUsed(ctx, apiKey.ID)
+
+	identity := &models.Identity{
+		UserID:   owner.ID,
+		Role:     owner.Role,
+		APIKeyID: apiKey.ID,
+		Scopes:   apiKey.Scopes,
+	}
+	if apiKey.RateLimit != nil {
+		identity.RateLimit = *apiKey.RateLimit
+	}
+
+	return identity, nil
+}
+
+// requireUser rejects anonymous callers and API keys: keys are managed by
+// their users, an API key cannot mint or revoke keys.
+func requireUser(actor *models.Identity) error {
+	if actor == nil || actor.IsAPIKey() {
+		return ErrForbidden
+	}
+	return nil
+}
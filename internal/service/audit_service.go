@@ -0,0 +1,226 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type requestInfoKey struct{}
+
+// WithRequestInfo returns a context carrying the HTTP request a change is
+// made in, for its audit entry.
+func WithRequestInfo(ctx context.Context, info models.RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// requestInfoFrom returns the request carried by ctx; it is empty for
+// changes not made in one.
+func requestInfoFrom(ctx context.Context) models.RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(models.RequestInfo)
+	return info
+}
+
+// AuditService lets admins look through the audit log.
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+	validator *validator.Validate
+	logger    *logger.Logger
+	policy    AuditPolicy
+}
+
+func NewAuditService(auditRepo *repository.AuditRepository, logger *logger.Logger) *AuditService {
+	return &AuditService{
+		auditRepo: auditRepo,
+		validator: validator.New(),
+		logger:    logger,
+	}
+}
+
+// ListEntries returns one page of the audit entries matching params,
+// newest first, and how many match in total.
+func (s *AuditService) ListEntries(ctx context.Context, actor *models.Identity, params *models.AuditFilterParams) ([]models.AuditEntry, int, error) {
+	if err := s.policy.Authorize(actor); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.validator.Struct(params); err != nil {
+		return nil, 0, fmt.Errorf("validation error: %w", err)
+	}
+	if params.From != nil && params.To != nil && !params.From.Before(*params.To) {
+		return nil, 0, ErrInvalidPeriod
+	}
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 20
+	}
+
+	return s.auditRepo.List(ctx, params)
+}
+
+// recordAudit writes the audit entry of a change from before to after,
+// either of which is nil for created and deleted entities. Called within
+// the transaction of the change, the entry is written if and only if the
+// change is. Updates that change nothing are not recorded. actor is nil
+// for changes the system makes.
+func recordAudit(ctx context.Context, auditRepo *repository.AuditRepository, actor *models.Identity, entityType string, entityID int64, action string, before, after interface{}) error {
+	changes, err := auditChanges(before, after)
+	if err != nil {
+		return err
+	}
+	if action == models.AuditActionUpdate && len(changes) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit changes: %w", err)
+	}
+
+	info := requestInfoFrom(ctx)
+	entry := &models.AuditEntry{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		RequestID:  info.RequestID,
+		SourceIP:   info.SourceIP,
+		Changes:    data,
+	}
+	if actor != nil {
+		entry.ActorID = &actor.UserID
+		if actor.IsAPIKey() {
+			entry.APIKeyID = &actor.APIKeyID
+		}
+	}
+
+	return auditRepo.Create(ctx, entry)
+}
+
+// auditChanges compares the JSON fields of before and after and returns
+// those that differ.
+func auditChanges(before, after interface{}) (map[string]models.AuditChange, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]models.AuditChange)
+	for name, value := range beforeFields {
+		if !bytes.Equal(value, afterFields[name]) {
+			changes[name] = models.AuditChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			changes[name] = models.AuditChange{After: value}
+		}
+	}
+
+	return changes, nil
+}
+
+func auditFields(state interface{}) (map[string]json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audited state: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audited state: %w", err)
+	}
+
+	return fields, nil
+}
+
+// productAudit is the audited state of a product: the fields its owner
+// sets, not those derived when it is read or rendered by the image worker.
+type productAudit struct {
+	UserID             int64         `json:"user_id"`
+	ProductName        string        `json:"product_name"`
+	ProductDescription string        `json:"product_description"`
+	ProductPrice       models.Amount `json:"product_price"`
+	Currency           string        `json:"currency"`
+	ProductImages      []string      `json:"product_images"`
+	Tags               []string      `json:"tags"`
+}
+
+func productAuditOf(product *models.Product) productAudit {
+	return productAudit{
+		UserID:             product.UserID,
+		ProductName:        product.ProductName,
+		ProductDescription: product.ProductDescription,
+		ProductPrice:       product.ProductPrice,
+		Currency:           product.Currency,
+		ProductImages:      auditList(product.ProductImages),
+		Tags:               auditList(product.Tags),
+	}
+}
+
+// imageAudit is the audited state of a product's rendered images.
+type imageAudit struct {
+	CompressedProductImages []string `json:"compressed_product_images"`
+}
+
+func imageAuditOf(compressedImages []string) imageAudit {
+	return imageAudit{CompressedProductImages: auditList(compressedImages)}
+}
+
+// categoryAudit is the audited state of a product's categories.
+type categoryAudit struct {
+	CategoryIDs []int64 `json:"category_ids"`
+}
+
+func categoryAuditOf(categories []models.ProductCategory) categoryAudit {
+	ids := []int64{}
+	for _, category := range categories {
+		ids = append(ids, category.ID)
+	}
+	return categoryAudit{CategoryIDs: ids}
+}
+
+// auditList returns list, with nil as an empty list, so the two do not
+// show as a change.
+func auditList(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// userAudit is the audited state of a user. Password hashes stay out of
+// the log; a changed password shows as Password going from empty to
+// auditRedacted.
+type userAudit struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Password string `json:"password,omitempty"`
+}
+
+const auditRedacted = "[redacted]"
+
+func userAuditOf(user *models.User) userAudit {
+	return userAudit{
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}
+}
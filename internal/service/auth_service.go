@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials is returned when an email and password do not
+	// match a user.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned for missing, malformed, expired or
+	// revoked tokens.
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// AuthConfig controls token signing and lifetimes.
+type AuthConfig struct {
+	// Secret signs access tokens with HMAC-SHA256.
+	Secret          []byte
+	Issuer          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// AuthService logs users in and issues short-lived JWT access tokens along
+// with refresh tokens that are rotated on every use.
+type AuthService struct {
+	userRepo         *repository.UserRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	transactor       *repository.Transactor
+	validator        *validator.Validate
+	logger           *logger.Logger
+	config           AuthConfig
+}
+
+func NewAuthService(
+	userRepo *repository.UserRepository,
+	refreshTokenRepo *repository.RefreshTokenRepository,
+	transactor *repository.Transactor,
+	logger *logger.Logger,
+	config AuthConfig,
+) *AuthService {
+	return &AuthService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		transactor:       transactor,
+		validator:        validator.New(),
+		logger:           logger,
+		config:           config,
+	}
+}
+
+// accessClaims are the claims of an access token. The subject is the user
+// ID.
+type accessClaims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// dummyPasswordHash is compared against when the email is unknown, so
+// unknown emails take as long to reject as wrong passwords.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// Login checks an email and password and starts a new refresh token family.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest) (*models.TokenPair, error) {
+	req.Email = normalizeEmail(req.Email)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	user, err := s.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil && err != repository.ErrUserNotFound {
+		return nil, err
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(req.Password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.issueTokens(ctx, user, hex.EncodeToString(randomBytes(16)))
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token is revoked; presenting it again revokes its whole family, since
+// that means it was stolen or the client misbehaves.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	var pair *models.TokenPair
+	reused := false
+
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		token, err := s.refreshTokenRepo.FindByHashForUpdate(ctx, hashToken(refreshToken))
+		if err == repository.ErrRefreshTokenNotFound {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if token.RevokedAt != nil {
+			// Commit the family revocation rather than rolling it back
+			reused = true
+			return s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID)
+		}
+		if time.Now().After(token.ExpiresAt) {
+			return ErrInvalidToken
+		}
+
+		if err := s.refreshTokenRepo.Revoke(ctx, token.ID); err != nil {
+			return err
+		}
+
+		// Reload the user so role changes apply from the next access token
+		user, err := s.userRepo.FindByID(ctx, token.UserID)
+		if err == repository.ErrUserNotFound {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		pair, err = s.issueTokens(ctx, user, token.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		s.logger.Warn("Revoked refresh token family after token reuse")
+		return nil, ErrInvalidToken
+	}
+
+	return pair, nil
+}
+
+// Logout revokes every refresh token descended from the same login.
+// Unknown tokens are ignored.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		token, err := s.refreshTokenRepo.FindByHashForUpdate(ctx, hashToken(refreshToken))
+		if err == repository.ErrRefreshTokenNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID)
+	})
+}
+
+// Authenticate validates an access token and returns the identity it
+// carries.
+func (s *AuthService) Authenticate(accessToken string) (*models.Identity, error) {
+	claims := &accessClaims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return s.config.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.config.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || userID <= 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return &models.Identity{UserID: userID, Role: claims.Role}, nil
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, familyID string) (*models.TokenPair, error) {
+	now := time.Now()
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.config.Issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.config.AccessTokenTTL)),
+		},
+	}).SignedString(s.config.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	refreshToken := base64.RawURLEncoding.EncodeToString(randomBytes(32))
+	err = s.refreshTokenRepo.Create(ctx, &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(s.config.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.config.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// hashPassword hashes a password for storage.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// hashToken is how refresh tokens are stored. Tokens are random, so a fast
+// hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomBytes(size int) []byte {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("failed to generate random bytes: %v", err))
+	}
+	return b
+}
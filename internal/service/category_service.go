@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"product-management/internal/cache"
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// CategoryService manages the category tree products are filed under.
+type CategoryService struct {
+	categoryRepo *repository.CategoryRepository
+	validator    *validator.Validate
+	logger       *logger.Logger
+	redisCache   *cache.RedisCache
+	policy       ReferenceDataPolicy
+}
+
+func NewCategoryService(categoryRepo *repository.CategoryRepository, logger *logger.Logger, redisCache *cache.RedisCache) *CategoryService {
+	return &CategoryService{
+		categoryRepo: categoryRepo,
+		validator:    validator.New(),
+		logger:       logger,
+		redisCache:   redisCache,
+	}
+}
+
+func (s *CategoryService) CreateCategory(ctx context.Context, actor *models.Identity, req *models.CategoryRequest) (*models.Category, error) {
+	if err := s.policy.Authorize(actor, ActionCreate); err != nil {
+		return nil, err
+	}
+
+	normalizeCategoryRequest(req)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	category := &models.Category{
+		ParentID: req.ParentID,
+		Name:     req.Name,
+		Slug:     req.Slug,
+	}
+	if err := s.categoryRepo.Create(ctx, category); err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+func (s *CategoryService) GetCategory(ctx context.Context, actor *models.Identity, categoryID int64) (*models.Category, error) {
+	if err := s.policy.Authorize(actor, ActionRead); err != nil {
+		return nil, err
+	}
+
+	return s.categoryRepo.FindByID(ctx, categoryID)
+}
+
+// ListCategories returns the whole tree depth first, each parent before
+// its children.
+func (s *CategoryService) ListCategories(ctx context.Context, actor *models.Identity) ([]models.Category, error) {
+	if err := s.policy.Authorize(actor, ActionList); err != nil {
+		return nil, err
+	}
+
+	return s.categoryRepo.List(ctx)
+}
+
+// UpdateCategory renames a category or moves it, with its subcategories,
+// below another parent.
+func (s *CategoryService) UpdateCategory(ctx context.Context, actor *models.Identity, categoryID int64, req *models.CategoryRequest) (*models.Category, error) {
+	if err := s.policy.Authorize(actor, ActionUpdate); err != nil {
+		return nil, err
+	}
+
+	normalizeCategoryRequest(req)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	category := &models.Category{
+		ID:       categoryID,
+		ParentID: req.ParentID,
+		Name:     req.Name,
+		Slug:     req.Slug,
+	}
+	if err := s.categoryRepo.Update(ctx, category); err != nil {
+		return nil, err
+	}
+
+	s.invalidateCategorizedProducts(ctx)
+
+	return category, nil
+}
+
+// DeleteCategory removes a leaf category; its products stay, unassigned
+// from it.
+func (s *CategoryService) DeleteCategory(ctx context.Context, actor *models.Identity, categoryID int64) error {
+	if err := s.policy.Authorize(actor, ActionDelete); err != nil {
+		return err
+	}
+
+	if err := s.categoryRepo.Delete(ctx, categoryID); err != nil {
+		return err
+	}
+
+	s.invalidateCategorizedProducts(ctx)
+
+	return nil
+}
+
+// invalidateCategorizedProducts drops every cached product and listing,
+// since cached breadcrumbs and category filters may refer to the old tree.
+// The tree changes rarely, so this is cheaper than tracking which products
+// sit below a category.
+func (s *CategoryService) invalidateCategorizedProducts(ctx context.Context) {
+	if s.redisCache == nil {
+		return
+	}
+
+	for _, pattern := range []string{"product:*", "products:*"} {
+		if err := s.redisCache.DeleteByPattern(ctx, pattern); err != nil {
+			s.logger.Error("Failed to invalidate product cache", logger.Error(err))
+		}
+	}
+}
+
+// normalizeCategoryRequest trims names and turns slugs into lower-case,
+// dash-separated words.
+func normalizeCategoryRequest(req *models.CategoryRequest) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Slug = strings.Join(strings.Fields(strings.ToLower(req.Slug)), "-")
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"product-management/internal/models"
+)
+
+// ErrInvalidCursor is returned for cursors that were tampered with or
+// belong to a listing with other filters or another sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor signs a cursor so clients cannot forge positions. The
+// result is opaque: base64url(JSON) "." base64url(HMAC-SHA256).
+func encodeCursor(secret []byte, cursor *models.ProductCursor) (string, error) {
+	payload, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(signCursor(secret, payload)), nil
+}
+
+// decodeCursor verifies and decodes a cursor made by encodeCursor.
+func decodeCursor(secret []byte, token string) (*models.ProductCursor, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, signCursor(secret, payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &models.ProductCursor{}
+	if err := json.Unmarshal(payload, cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func signCursor(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("product-cursor:"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// filterFingerprint identifies the filters and sort order of a listing,
+// leaving out the position, page size and what else is reported besides
+// the products.
+func filterFingerprint(params *models.ProductFilterParams) (string, error) {
+	filters := *params
+	filters.Cursor = ""
+	filters.Count = ""
+	filters.Facets = false
+	filters.PriceBuckets = nil
+	filters.Page = 0
+	filters.PageSize = 0
+
+	data, err := json.Marshal(&filters)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal filters: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
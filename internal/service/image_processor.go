@@ -1,6 +1,246 @@
 package service
 
-func ProcessImage(filePath string) error {
-	// Implement image processing logic
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"product-management/internal/models"
+	"product-management/internal/storage"
+	"product-management/pkg/utils"
+)
+
+// ErrImageTooLarge is returned for source images over MaxSourceBytes.
+// Fetching them again will not help.
+var ErrImageTooLarge = errors.New("image too large")
+
+// formatOriginal keeps the format of the source image.
+const formatOriginal = "original"
+
+// RenditionSpec describes one named rendition generated for every image.
+type RenditionSpec struct {
+	Name string
+	// MaxDimension bounds the longest side of the output, in pixels.
+	MaxDimension int
+	// Format is jpeg, png, gif, webp or original.
+	Format string
+}
+
+// ParseRenditions parses a comma separated list of name:max_dimension[:format]
+// entries, e.g. "thumbnail:200,large:1600,large_webp:1600:webp".
+func ParseRenditions(spec string) ([]RenditionSpec, error) {
+	var renditions []RenditionSpec
+	seen := map[string]bool{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid rendition %q: want name:max_dimension[:format]", entry)
+		}
+
+		maxDimension, err := strconv.Atoi(parts[1])
+		if err != nil || maxDimension <= 0 {
+			return nil, fmt.Errorf("invalid rendition %q: max dimension must be a positive integer", entry)
+		}
+
+		format := formatOriginal
+		if len(parts) == 3 {
+			format = parts[2]
+		}
+		switch format {
+		case formatOriginal, "jpeg", "png", "gif", "webp":
+		default:
+			return nil, fmt.Errorf("invalid rendition %q: unsupported format %s", entry, format)
+		}
+
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("duplicate rendition %q", parts[0])
+		}
+		seen[parts[0]] = true
+
+		renditions = append(renditions, RenditionSpec{Name: parts[0], MaxDimension: maxDimension, Format: format})
+	}
+
+	if len(renditions) == 0 {
+		return nil, fmt.Errorf("at least one image rendition is required")
+	}
+
+	return renditions, nil
+}
+
+// ImageProcessorConfig controls how source images are re-encoded.
+type ImageProcessorConfig struct {
+	Renditions []RenditionSpec
+	// PrimaryRendition is stored as the product's compressed image.
+	PrimaryRendition string
+	// Quality is the JPEG encoding quality, 1-100.
+	Quality int
+	// MaxSourceBytes is the largest source image accepted, in bytes.
+	MaxSourceBytes int64
+}
+
+// ImageProcessor fetches product images, renders every configured variant
+// and writes them to a blob store.
+type ImageProcessor struct {
+	source ImageSource
+	store  storage.BlobStore
+	config ImageProcessorConfig
+}
+
+func NewImageProcessor(source ImageSource, store storage.BlobStore, config ImageProcessorConfig) *ImageProcessor {
+	return &ImageProcessor{
+		source: source,
+		store:  store,
+		config: config,
+	}
+}
+
+// SourceImage is the fetched original of a product image.
+type SourceImage struct {
+	Location string
+	Data     []byte
+	// Hash is the SHA-256 of Data. Renditions record it, so that new bytes
+	// served at an unchanged location are told apart from the old ones.
+	Hash string
+}
+
+// FetchImage reads the source image at location, up to MaxSourceBytes.
+func (p *ImageProcessor) FetchImage(ctx context.Context, location string) (*SourceImage, error) {
+	rc, err := p.source.Open(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if p.config.MaxSourceBytes > 0 {
+		// Read one byte more than allowed to tell a source that fits
+		// exactly from one that was cut off
+		r = io.LimitReader(rc, p.config.MaxSourceBytes+1)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", location, err)
+	}
+	if p.config.MaxSourceBytes > 0 && int64(len(data)) > p.config.MaxSourceBytes {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrImageTooLarge, location, p.config.MaxSourceBytes)
+	}
+
+	sum := sha256.Sum256(data)
+	return &SourceImage{Location: location, Data: data, Hash: hex.EncodeToString(sum[:])}, nil
+}
+
+// ProcessImage fetches the image at location and renders every configured
+// rendition of it.
+func (p *ImageProcessor) ProcessImage(ctx context.Context, productID int64, location string) ([]models.ImageVariant, error) {
+	source, err := p.FetchImage(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	return p.RenderImage(ctx, productID, source)
+}
+
+// RenderImage renders every configured rendition of a fetched image. The
+// source is decoded once. Output keys are derived from the source location,
+// so reprocessing the same image overwrites previous output.
+func (p *ImageProcessor) RenderImage(ctx context.Context, productID int64, source *SourceImage) ([]models.ImageVariant, error) {
+	location := source.Location
+	img, sourceFormat, err := utils.DecodeImage(bytes.NewReader(source.Data))
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", location, err)
+	}
+
+	prefix := imagePrefix(productID, location)
+
+	variants := make([]models.ImageVariant, 0, len(p.config.Renditions))
+	for _, rendition := range p.config.Renditions {
+		format := rendition.Format
+		if format == formatOriginal {
+			format = sourceFormat
+		}
+
+		resized := utils.ResizeToFit(img, rendition.MaxDimension)
+		data, err := utils.EncodeImage(resized, format, p.config.Quality)
+		if err != nil {
+			return nil, fmt.Errorf("image %s, rendition %s: %w", location, rendition.Name, err)
+		}
+
+		key := fmt.Sprintf("%s/%s.%s", prefix, rendition.Name, utils.Extension(format))
+		if err := p.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), utils.ContentType(format)); err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, models.ImageVariant{
+			ProductID:   productID,
+			SourceImage: location,
+			SourceHash:  source.Hash,
+			Name:        rendition.Name,
+			URL:         p.store.URL(key),
+			Format:      format,
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			SizeBytes:   int64(len(data)),
+		})
+	}
+
+	return variants, nil
+}
+
+// PruneImages deletes the stored renditions of every image of a product
+// except those of the images in keep, e.g. after the product's images were
+// replaced or the product was deleted.
+func (p *ImageProcessor) PruneImages(ctx context.Context, productID int64, keep []string) error {
+	objects, err := p.store.List(ctx, fmt.Sprintf("products/%d/", productID))
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, location := range keep {
+		kept[imagePrefix(productID, location)] = true
+	}
+
+	for _, object := range objects {
+		if kept[path.Dir(object.Key)] {
+			continue
+		}
+		if err := p.store.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
+
+// imagePrefix is the key prefix of every rendition of the image at location.
+func imagePrefix(productID int64, location string) string {
+	sum := sha256.Sum256([]byte(location))
+	return fmt.Sprintf("products/%d/%s", productID, hex.EncodeToString(sum[:8]))
+}
+
+// primaryURL picks the URL recorded in compressed_product_images for one
+// source image, falling back to the first rendition.
+func (p *ImageProcessor) primaryURL(variants []models.ImageVariant) string {
+	for _, v := range variants {
+		if v.Name == p.config.PrimaryRendition {
+			return v.URL
+		}
+	}
+	if len(variants) > 0 {
+		return variants[0].URL
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for image URLs that lead to an address
+// inside the worker's network: loopback, private or link-local, which
+// includes cloud metadata endpoints.
+var ErrForbiddenAddress = errors.New("image host is not a public address")
+
+// ImageSource fetches the original bytes of a product image.
+type ImageSource interface {
+	Open(ctx context.Context, location string) (io.ReadCloser, error)
+}
+
+// FileImageSource reads images from a directory on the local filesystem.
+// Every location, absolute or not, is resolved inside Root so product
+// images cannot be used to read arbitrary files from the worker host.
+type FileImageSource struct {
+	Root string
+}
+
+func (s *FileImageSource) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	if s.Root == "" {
+		return nil, fmt.Errorf("local image source is not configured for %s", location)
+	}
+
+	path := location
+	if u, err := url.Parse(location); err == nil && u.Scheme == "file" {
+		path = u.Path
+	}
+
+	// Cleaning a rooted path strips any leading ".." elements
+	path = filepath.Join(s.Root, filepath.Clean("/"+path))
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image %s: %w", location, err)
+	}
+	return f, nil
+}
+
+// HTTPImageSource downloads images over HTTP(S). Image URLs come from
+// sellers, so Client should be one from NewPublicHTTPClient; a nil Client
+// is one.
+type HTTPImageSource struct {
+	Client *http.Client
+}
+
+// NewPublicHTTPClient returns a client that only connects to public
+// addresses. The check is made on every connection, after DNS resolution,
+// so neither a host name resolving to an internal address nor a redirect
+// to one gets through.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: refuseInternalAddress}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on the client's behalf, unchecked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// refuseInternalAddress is a net.Dialer Control hook that fails dials to
+// anything but public unicast addresses.
+func refuseInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// defaultImageClient is used by HTTPImageSource values without a Client.
+var defaultImageClient = NewPublicHTTPClient(30 * time.Second)
+
+func (s *HTTPImageSource) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for %s: %w", location, err)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = defaultImageClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch image %s: %w", location, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch image %s: unexpected status %d", location, resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// SchemeImageSource dispatches to HTTP for http(s) URLs and to the local
+// filesystem for file URLs and plain paths.
+type SchemeImageSource struct {
+	HTTP ImageSource
+	File ImageSource
+}
+
+// NewImageSource returns the default source used by the image processor.
+func NewImageSource(client *http.Client, fileRoot string) *SchemeImageSource {
+	return &SchemeImageSource{
+		HTTP: &HTTPImageSource{Client: client},
+		File: &FileImageSource{Root: fileRoot},
+	}
+}
+
+func (s *SchemeImageSource) Open(ctx context.Context, location string) (io.ReadCloser, error) {
+	u, err := url.Parse(location)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return s.HTTP.Open(ctx, location)
+	}
+	return s.File.Open(ctx, location)
+}
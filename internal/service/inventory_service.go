@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"product-management/internal/models"
+	"product-management/internal/queue"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrReservationClosed is returned when committing or releasing a
+// reservation that was already committed or released.
+var ErrReservationClosed = errors.New("reservation is no longer pending")
+
+// InventoryService tracks stock per product or variant and warehouse.
+// Every change is recorded in the stock ledger in the same transaction.
+type InventoryService struct {
+	inventoryRepo   *repository.InventoryRepository
+	productRepo     *repository.ProductRepository
+	variantRepo     *repository.ProductVariantRepository
+	outboxRepo      *repository.OutboxRepository
+	transactor      *repository.Transactor
+	validator       *validator.Validate
+	logger          *logger.Logger
+	policy          ProductPolicy
+	warehousePolicy ReferenceDataPolicy
+}
+
+func NewInventoryService(
+	inventoryRepo *repository.InventoryRepository,
+	productRepo *repository.ProductRepository,
+	variantRepo *repository.ProductVariantRepository,
+	outboxRepo *repository.OutboxRepository,
+	transactor *repository.Transactor,
+	logger *logger.Logger,
+) *InventoryService {
+	return &InventoryService{
+		inventoryRepo: inventoryRepo,
+		productRepo:   productRepo,
+		variantRepo:   variantRepo,
+		outboxRepo:    outboxRepo,
+		transactor:    transactor,
+		validator:     validator.New(),
+		logger:        logger,
+	}
+}
+
+func (s *InventoryService) CreateWarehouse(ctx context.Context, actor *models.Identity, req *models.WarehouseCreateRequest) (*models.Warehouse, error) {
+	if err := s.warehousePolicy.Authorize(actor, ActionCreate); err != nil {
+		return nil, err
+	}
+
+	req.Code = strings.ToLower(strings.TrimSpace(req.Code))
+	req.Name = strings.TrimSpace(req.Name)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	warehouse := &models.Warehouse{Code: req.Code, Name: req.Name}
+	if err := s.inventoryRepo.CreateWarehouse(ctx, warehouse); err != nil {
+		return nil, err
+	}
+
+	return warehouse, nil
+}
+
+func (s *InventoryService) ListWarehouses(ctx context.Context, actor *models.Identity) ([]models.Warehouse, error) {
+	if err := s.warehousePolicy.Authorize(actor, ActionList); err != nil {
+		return nil, err
+	}
+
+	return s.inventoryRepo.ListWarehouses(ctx)
+}
+
+// GetStock returns a product's stock per variant and warehouse. Everyone
+// who may see the product may see its stock.
+func (s *InventoryService) GetStock(ctx context.Context, actor *models.Identity, productID int64, params *models.InventoryParams) ([]models.InventoryLevel, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionRead, productID); err != nil {
+		return nil, err
+	}
+
+	if err := s.validator.Struct(params); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	return s.inventoryRepo.ListLevels(ctx, productID, params)
+}
+
+// AdjustStock adds to or takes from the units on hand, e.g. for deliveries,
+// stocktakes or damaged goods. Units held by reservations cannot be taken.
+func (s *InventoryService) AdjustStock(ctx context.Context, actor *models.Identity, productID int64, req *models.StockAdjustRequest) (*models.InventoryLevel, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionManageInventory, productID); err != nil {
+		return nil, err
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	level := &models.InventoryLevel{
+		ProductID:         productID,
+		VariantID:         req.VariantID,
+		WarehouseID:       req.WarehouseID,
+		LowStockThreshold: req.LowStockThreshold,
+	}
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkVariant(ctx, productID, req.VariantID); err != nil {
+			return err
+		}
+		if err := s.inventoryRepo.Adjust(ctx, level, req.Delta); err != nil {
+			return err
+		}
+
+		err := s.inventoryRepo.AddMovement(ctx, &models.StockMovement{
+			InventoryID: level.ID,
+			Kind:        models.MovementAdjust,
+			OnHandDelta: req.Delta,
+			Reason:      req.Reason,
+			ActorID:     &actor.UserID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.checkLowStock(ctx, level, level.Available()-req.Delta)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return level, nil
+}
+
+// ReserveStock holds units for an order. It fails with
+// repository.ErrInsufficientStock rather than reserve more than is
+// available, however many reservations run at once.
+func (s *InventoryService) ReserveStock(ctx context.Context, actor *models.Identity, productID int64, req *models.StockReserveRequest) (*models.StockReservation, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionManageInventory, productID); err != nil {
+		return nil, err
+	}
+
+	req.Reference = strings.TrimSpace(req.Reference)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	level := &models.InventoryLevel{
+		ProductID:   productID,
+		VariantID:   req.VariantID,
+		WarehouseID: req.WarehouseID,
+	}
+	reservation := &models.StockReservation{
+		ProductID: productID,
+		Quantity:  req.Quantity,
+		Status:    models.ReservationPending,
+		Reference: req.Reference,
+		Level:     level,
+	}
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.checkVariant(ctx, productID, req.VariantID); err != nil {
+			return err
+		}
+		if err := s.inventoryRepo.Reserve(ctx, level, req.Quantity); err != nil {
+			return err
+		}
+
+		reservation.InventoryID = level.ID
+		if err := s.inventoryRepo.CreateReservation(ctx, reservation); err != nil {
+			return err
+		}
+
+		err := s.inventoryRepo.AddMovement(ctx, &models.StockMovement{
+			InventoryID:   level.ID,
+			Kind:          models.MovementReserve,
+			ReservedDelta: req.Quantity,
+			ReservationID: &reservation.ID,
+			ActorID:       &actor.UserID,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.checkLowStock(ctx, level, level.Available()+req.Quantity)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// CommitReservation takes the reserved units off the shelf for good, e.g.
+// once the order shipped.
+func (s *InventoryService) CommitReservation(ctx context.Context, actor *models.Identity, reservationID int64) (*models.StockReservation, error) {
+	return s.closeReservation(ctx, actor, reservationID, models.ReservationCommitted)
+}
+
+// ReleaseReservation makes the reserved units available again, e.g. when
+// the order was cancelled.
+func (s *InventoryService) ReleaseReservation(ctx context.Context, actor *models.Identity, reservationID int64) (*models.StockReservation, error) {
+	return s.closeReservation(ctx, actor, reservationID, models.ReservationReleased)
+}
+
+// closeReservation commits or releases a pending reservation. The
+// reservation stays locked until the stock and ledger are updated, so it
+// is only ever closed once.
+func (s *InventoryService) closeReservation(ctx context.Context, actor *models.Identity, reservationID int64, status string) (*models.StockReservation, error) {
+	var reservation *models.StockReservation
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		reservation, err = s.inventoryRepo.FindReservationForUpdate(ctx, reservationID)
+		if err != nil {
+			return err
+		}
+		if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionManageInventory, reservation.ProductID); err != nil {
+			return err
+		}
+		if reservation.Status != models.ReservationPending {
+			return ErrReservationClosed
+		}
+
+		movement := &models.StockMovement{
+			InventoryID:   reservation.InventoryID,
+			ReservedDelta: -reservation.Quantity,
+			ReservationID: &reservation.ID,
+			ActorID:       &actor.UserID,
+		}
+		if status == models.ReservationCommitted {
+			movement.Kind = models.MovementCommit
+			movement.OnHandDelta = -reservation.Quantity
+		} else {
+			movement.Kind = models.MovementRelease
+		}
+
+		reservation.Level, err = s.inventoryRepo.ApplyDelta(ctx, reservation.InventoryID, movement.OnHandDelta, movement.ReservedDelta)
+		if err != nil {
+			return err
+		}
+		if err := s.inventoryRepo.SetReservationStatus(ctx, reservation, status); err != nil {
+			return err
+		}
+		return s.inventoryRepo.AddMovement(ctx, movement)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reservation, nil
+}
+
+// ListMovements returns one page of a product's stock ledger, newest
+// first. Only those who may manage the stock may read it.
+func (s *InventoryService) ListMovements(ctx context.Context, actor *models.Identity, productID int64, params *models.StockMovementParams) ([]models.StockMovement, int, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionManageInventory, productID); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.validator.Struct(params); err != nil {
+		return nil, 0, fmt.Errorf("validation error: %w", err)
+	}
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 50
+	}
+
+	return s.inventoryRepo.ListMovements(ctx, productID, params)
+}
+
+// checkVariant makes sure a variant belongs to the product.
+func (s *InventoryService) checkVariant(ctx context.Context, productID int64, variantID *int64) error {
+	if variantID == nil {
+		return nil
+	}
+
+	_, err := s.variantRepo.FindByID(ctx, productID, *variantID)
+	return err
+}
+
+// checkLowStock enqueues a low stock event when a change brought the
+// available units of level from above its threshold to or below it. Only
+// the crossing is reported, not every change while stock stays low.
+func (s *InventoryService) checkLowStock(ctx context.Context, level *models.InventoryLevel, availableBefore int) error {
+	if level.LowStockThreshold == nil {
+		return nil
+	}
+	threshold := *level.LowStockThreshold
+	if availableBefore <= threshold || level.Available() > threshold {
+		return nil
+	}
+
+	payload, err := json.Marshal(queue.NewLowStockEvent(level.ProductID, level.VariantID, level.WarehouseID, level.Available(), threshold))
+	if err != nil {
+		return fmt.Errorf("failed to marshal low stock event: %w", err)
+	}
+
+	return s.outboxRepo.Enqueue(ctx, &models.OutboxMessage{
+		AggregateType: "inventory",
+		AggregateID:   level.ID,
+		EventType:     queue.LowStockEventType,
+		Exchange:      queue.InventoryExchange,
+		RoutingKey:    queue.LowStockRoutingKey,
+		Payload:       payload,
+	})
+}
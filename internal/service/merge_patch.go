@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPatch is returned when a PATCH body is not a valid JSON merge
+// patch document.
+var ErrInvalidPatch = errors.New("invalid merge patch")
+
+// mergePatch applies an RFC 7386 JSON merge patch to target and returns the
+// resulting document.
+func mergePatch(target, patch []byte) ([]byte, error) {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	// A product is an object; any other patch would replace it wholesale
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%w: patch must be a JSON object", ErrInvalidPatch)
+	}
+
+	var targetDoc interface{}
+	if err := json.Unmarshal(target, &targetDoc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal patch target: %w", err)
+	}
+
+	return json.Marshal(mergeValue(targetDoc, patchDoc))
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = mergeValue(targetObj[key], value)
+	}
+
+	return targetObj
+}
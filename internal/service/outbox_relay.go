@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/queue"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+)
+
+// outboxCleanupInterval is how often published messages past their
+// retention are deleted.
+const outboxCleanupInterval = time.Hour
+
+// outboxClaimLease is how long claimed messages are reserved for the relay
+// that claimed them. It must outlast publishing a batch; messages of a
+// relay that died mid-batch are published by another once it runs out.
+const outboxClaimLease = 10 * time.Minute
+
+// MessagePublisher publishes a message and returns once the broker has
+// accepted it. *queue.RabbitMQPublisher implements it.
+type MessagePublisher interface {
+	Publish(exchange, routingKey string, msg queue.Message) error
+}
+
+// OutboxRelayConfig controls how often and how much the relay publishes.
+type OutboxRelayConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+	// Retention is how long published messages are kept before cleanup.
+	Retention time.Duration
+	// RetryBaseDelay and RetryMaxDelay bound the backoff of a message that
+	// failed to publish: the delay doubles with every failed attempt.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+// OutboxRelay publishes pending outbox messages and marks them sent.
+// Delivery is at least once: a message is marked only after the broker
+// confirmed it, so a crash in between publishes it again once its claim
+// runs out. Messages of one aggregate are published in order.
+type OutboxRelay struct {
+	transactor *repository.Transactor
+	outboxRepo *repository.OutboxRepository
+	publisher  MessagePublisher
+	logger     *logger.Logger
+	config     OutboxRelayConfig
+	retry      queue.RetryPolicy
+	loop       pollLoop
+}
+
+// NewOutboxRelay returns a relay, or an error if the batch size or poll
+// interval in config is not positive.
+func NewOutboxRelay(
+	transactor *repository.Transactor,
+	outboxRepo *repository.OutboxRepository,
+	publisher MessagePublisher,
+	logger *logger.Logger,
+	config OutboxRelayConfig,
+) (*OutboxRelay, error) {
+	loop, err := newPollLoop(config.BatchSize, config.PollInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outbox relay config: %w", err)
+	}
+
+	return &OutboxRelay{
+		transactor: transactor,
+		outboxRepo: outboxRepo,
+		publisher:  publisher,
+		logger:     logger,
+		config:     config,
+		retry:      queue.RetryPolicy{BaseDelay: config.RetryBaseDelay, MaxDelay: config.RetryMaxDelay},
+		loop:       loop,
+	}, nil
+}
+
+// Run relays messages until ctx is cancelled. Full batches are followed
+// immediately by the next one; otherwise the relay waits PollInterval.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	lastCleanup := time.Now()
+	r.loop.run(ctx, func(ctx context.Context) (int, error) {
+		published, err := r.RelayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("Outbox relay failed", logger.Error(err))
+		}
+
+		if r.config.Retention > 0 && time.Since(lastCleanup) >= outboxCleanupInterval {
+			lastCleanup = time.Now()
+			if _, err := r.outboxRepo.DeletePublishedBefore(ctx, time.Now().Add(-r.config.Retention)); err != nil && ctx.Err() == nil {
+				r.logger.Error("Outbox cleanup failed", logger.Error(err))
+			}
+		}
+
+		return published, err
+	})
+}
+
+// RelayBatch publishes up to BatchSize due messages in order and returns
+// how many were published. The messages are claimed in a short transaction
+// and published after it commits, so no rows stay locked while the broker
+// confirms them; each is marked published once it has been confirmed. It
+// stops at the first publish failure, since later messages would most
+// likely fail too. The failure is recorded on the message, which backs off
+// before it is tried again, so a message the broker keeps rejecting does
+// not hold up those of other aggregates. Claimed messages that were not
+// attempted are released straight away.
+func (r *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	var messages []models.OutboxMessage
+	err := r.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		messages, err = r.outboxRepo.Claim(ctx, r.config.BatchSize, time.Now().Add(outboxClaimLease))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The outcome of a publish is recorded even when stopping
+	record := context.WithoutCancel(ctx)
+	for i, msg := range messages {
+		if ctx.Err() != nil {
+			return i, r.release(record, messages[i:], ctx.Err())
+		}
+
+		err := r.publisher.Publish(msg.Exchange, msg.RoutingKey, queue.Message{
+			ID:        outboxMessageID(msg.ID),
+			Type:      msg.EventType,
+			Body:      msg.Payload,
+			Timestamp: msg.CreatedAt,
+		})
+		if err != nil {
+			attempts := msg.Attempts + 1
+			retryAt := time.Now().Add(r.retry.Delay(attempts))
+			publishErr := fmt.Errorf("failed to publish outbox message %d (attempt %d, next at %s): %w",
+				msg.ID, attempts, retryAt.Format(time.RFC3339), err)
+			if err := r.outboxRepo.MarkFailed(record, msg.ID, err.Error(), retryAt); err != nil {
+				return i, r.release(record, messages[i:], errors.Join(publishErr, err))
+			}
+			return i, r.release(record, messages[i+1:], publishErr)
+		}
+
+		// A message that cannot be marked is published again once its
+		// claim runs out, which consumers must tolerate anyway
+		if err := r.outboxRepo.MarkPublished(record, msg.ID); err != nil {
+			return i + 1, r.release(record, messages[i+1:], err)
+		}
+	}
+
+	return len(messages), nil
+}
+
+// release hands claimed messages back for the next run, rather than leaving
+// them to wait out their claim, and returns cause.
+func (r *OutboxRelay) release(ctx context.Context, messages []models.OutboxMessage, cause error) error {
+	if len(messages) == 0 {
+		return cause
+	}
+
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	if err := r.outboxRepo.Release(ctx, ids); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
+
+// outboxMessageID is the AMQP message ID of an outbox row. It is stable
+// across republishes, so consumers can recognise duplicates.
+func outboxMessageID(id int64) string {
+	return fmt.Sprintf("outbox-%d", id)
+}
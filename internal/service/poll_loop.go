@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// pollLoop drives background work done in batches: a full batch is
+// followed immediately by the next one, anything less waits for the next
+// tick.
+type pollLoop struct {
+	batchSize int
+	interval  time.Duration
+}
+
+func newPollLoop(batchSize int, interval time.Duration) (pollLoop, error) {
+	if batchSize < 1 {
+		return pollLoop{}, fmt.Errorf("batch size must be at least 1, got %d", batchSize)
+	}
+	if interval <= 0 {
+		return pollLoop{}, fmt.Errorf("poll interval must be positive, got %s", interval)
+	}
+
+	return pollLoop{batchSize: batchSize, interval: interval}, nil
+}
+
+// run calls batch until ctx is cancelled. batch returns how many items it
+// handled; after an error the loop waits for the next tick.
+func (l pollLoop) run(ctx context.Context, batch func(ctx context.Context) (int, error)) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		handled, err := batch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil && handled >= l.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
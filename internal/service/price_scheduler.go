@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/queue"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+)
+
+// PriceSchedulerConfig controls how often and how much the price scheduler
+// announces.
+type PriceSchedulerConfig struct {
+	BatchSize    int
+	PollInterval time.Duration
+}
+
+// PriceScheduler announces the price changes of price schedules: it
+// records a price change and enqueues a price changed event when a sale
+// starts and again when it ends. Effective prices need no job, they are
+// computed on read; cached products expire at the same boundaries.
+type PriceScheduler struct {
+	transactor  *repository.Transactor
+	priceRepo   *repository.PriceRepository
+	productRepo *repository.ProductRepository
+	outboxRepo  *repository.OutboxRepository
+	logger      *logger.Logger
+	config      PriceSchedulerConfig
+	loop        pollLoop
+}
+
+// NewPriceScheduler returns a scheduler, or an error if the batch size or
+// poll interval in config is not positive.
+func NewPriceScheduler(
+	transactor *repository.Transactor,
+	priceRepo *repository.PriceRepository,
+	productRepo *repository.ProductRepository,
+	outboxRepo *repository.OutboxRepository,
+	logger *logger.Logger,
+	config PriceSchedulerConfig,
+) (*PriceScheduler, error) {
+	loop, err := newPollLoop(config.BatchSize, config.PollInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid price scheduler config: %w", err)
+	}
+
+	return &PriceScheduler{
+		transactor:  transactor,
+		priceRepo:   priceRepo,
+		productRepo: productRepo,
+		outboxRepo:  outboxRepo,
+		logger:      logger,
+		config:      config,
+		loop:        loop,
+	}, nil
+}
+
+// Run announces price changes until ctx is cancelled. Full batches are
+// followed immediately by the next one; otherwise the scheduler waits
+// PollInterval.
+func (p *PriceScheduler) Run(ctx context.Context) {
+	p.loop.run(ctx, func(ctx context.Context) (int, error) {
+		announced, err := p.AnnounceDue(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			p.logger.Error("Price schedule announcement failed", logger.Error(err))
+		}
+		return announced, err
+	})
+}
+
+// AnnounceDue records and enqueues the price changes of up to BatchSize
+// schedules that started or ended by now and returns how many schedules it
+// handled. The changes are written in the transaction that marks the
+// schedules, so each one is recorded and announced exactly once.
+func (p *PriceScheduler) AnnounceDue(ctx context.Context, now time.Time) (int, error) {
+	handled := 0
+
+	err := p.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		schedules, err := p.priceRepo.ClaimDueSchedules(ctx, now, p.config.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		for i := range schedules {
+			schedule := &schedules[i]
+			product, err := p.productRepo.FindByID(ctx, schedule.ProductID)
+			if err != nil {
+				return err
+			}
+
+			started := schedule.StartPublishedAt == nil
+			ended := schedule.EndPublishedAt == nil && !schedule.EndsAt.After(now)
+
+			start := &models.PriceChange{
+				ProductID:  product.ID,
+				Currency:   product.Currency,
+				OldPrice:   &product.ProductPrice,
+				NewPrice:   schedule.SalePrice,
+				ChangedBy:  schedule.CreatedBy,
+				Reason:     models.PriceChangeSaleStarted,
+				ScheduleID: &schedule.ID,
+				ChangedAt:  schedule.StartsAt,
+			}
+			end := &models.PriceChange{
+				ProductID:  product.ID,
+				Currency:   product.Currency,
+				OldPrice:   &schedule.SalePrice,
+				NewPrice:   product.ProductPrice,
+				ChangedBy:  schedule.CreatedBy,
+				Reason:     models.PriceChangeSaleEnded,
+				ScheduleID: &schedule.ID,
+				ChangedAt:  schedule.EndsAt,
+			}
+
+			switch {
+			case started && ended:
+				// The whole sale passed unannounced, e.g. while no scheduler
+				// ran. It still belongs in the history, but the price is
+				// back where it was, so there is nothing to announce.
+				for _, change := range []*models.PriceChange{start, end} {
+					if err := p.priceRepo.RecordChange(ctx, change); err != nil {
+						return err
+					}
+				}
+			case started:
+				err = recordPriceChange(ctx, p.priceRepo, p.outboxRepo, start)
+			case ended:
+				err = recordPriceChange(ctx, p.priceRepo, p.outboxRepo, end)
+			}
+			if err != nil {
+				return err
+			}
+
+			if started {
+				schedule.StartPublishedAt = &now
+			}
+			if ended {
+				schedule.EndPublishedAt = &now
+			}
+			if err := p.priceRepo.MarkSchedulePublished(ctx, schedule); err != nil {
+				return err
+			}
+		}
+
+		handled = len(schedules)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return handled, nil
+}
+
+// recordPriceChange adds a change to the price history of its product
+// and announces it, unless there is no old price to compare with.
+func recordPriceChange(ctx context.Context, priceRepo *repository.PriceRepository, outboxRepo *repository.OutboxRepository, change *models.PriceChange) error {
+	if err := priceRepo.RecordChange(ctx, change); err != nil {
+		return err
+	}
+	if change.OldPrice == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(queue.NewPriceChangedEvent(change))
+	if err != nil {
+		return fmt.Errorf("failed to marshal price changed event: %w", err)
+	}
+
+	return outboxRepo.Enqueue(ctx, &models.OutboxMessage{
+		AggregateType: "product",
+		AggregateID:   change.ProductID,
+		EventType:     queue.PriceChangedEventType,
+		Exchange:      queue.PricingExchange,
+		RoutingKey:    queue.PriceChangedRoutingKey,
+		Payload:       payload,
+	})
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"product-management/internal/currency"
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	// ErrOwnCurrency is returned when a price list entry is in the currency
+	// of the product itself, where the product price applies.
+	ErrOwnCurrency = errors.New("price list prices must be in another currency than the product's")
+	// ErrInvalidPeriod is returned when a period ends before it begins.
+	ErrInvalidPeriod = errors.New("period must end after it begins")
+)
+
+// PriceService manages products' price lists and shows prices in the
+// currency a client asks for.
+type PriceService struct {
+	priceRepo   *repository.PriceRepository
+	productRepo *repository.ProductRepository
+	rates       currency.RateProvider
+	validator   *validator.Validate
+	logger      *logger.Logger
+	policy      ProductPolicy
+}
+
+func NewPriceService(priceRepo *repository.PriceRepository, productRepo *repository.ProductRepository, rates currency.RateProvider, logger *logger.Logger) *PriceService {
+	return &PriceService{
+		priceRepo:   priceRepo,
+		productRepo: productRepo,
+		rates:       rates,
+		validator:   validator.New(),
+		logger:      logger,
+	}
+}
+
+func (s *PriceService) ListPrices(ctx context.Context, actor *models.Identity, productID int64) ([]models.CurrencyPrice, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionRead, productID); err != nil {
+		return nil, err
+	}
+
+	return s.priceRepo.ListPrices(ctx, productID)
+}
+
+// SetPrice fixes a product's price in another currency, replacing any
+// earlier price in it.
+func (s *PriceService) SetPrice(ctx context.Context, actor *models.Identity, productID int64, code string, req *models.CurrencyPriceRequest) (*models.CurrencyPrice, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err = s.validCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if code == product.Currency {
+		return nil, ErrOwnCurrency
+	}
+
+	price := &models.CurrencyPrice{ProductID: productID, Currency: code, Amount: *req.Amount}
+	if err := s.priceRepo.SetPrice(ctx, price); err != nil {
+		return nil, err
+	}
+
+	return price, nil
+}
+
+// DeletePrice removes a price from a product's price list, so the price
+// is converted again.
+func (s *PriceService) DeletePrice(ctx context.Context, actor *models.Identity, productID int64, code string) error {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID); err != nil {
+		return err
+	}
+
+	code, err := s.validCurrency(code)
+	if err != nil {
+		return err
+	}
+
+	return s.priceRepo.DeletePrice(ctx, productID, code)
+}
+
+// PriceHistory returns the price changes of a product in a period and the
+// statistics of its prices then, such as the lowest price of the last 30
+// days that must be shown next to a reduced price. The period defaults to
+// the models.PriceHistoryWindow before To, and To to now.
+func (s *PriceService) PriceHistory(ctx context.Context, actor *models.Identity, productID int64, params *models.PriceHistoryParams) (*models.PriceHistory, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionRead, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	if params.To != nil {
+		to = *params.To
+	}
+	from := to.Add(-models.PriceHistoryWindow)
+	if params.From != nil {
+		from = *params.From
+	}
+	if !from.Before(to) {
+		return nil, ErrInvalidPeriod
+	}
+
+	changes, err := s.priceRepo.ListChanges(ctx, productID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := models.PriceStats{Currency: product.Currency, From: from.UTC(), To: to.UTC()}
+	if err := s.priceRepo.PriceStats(ctx, productID, &stats); err != nil {
+		return nil, err
+	}
+
+	return &models.PriceHistory{Changes: changes, Stats: stats}, nil
+}
+
+// Localize sets the DisplayPrice of products in the given currency from
+// what they sell for, their EffectivePrice: that price itself if the
+// product is in the currency, else its price list price, else the price
+// converted at the current exchange rate and rounded to the currency's
+// minor unit. Price list prices are regular prices, so a running sale
+// overrides them and its sale price is converted instead. It fails with
+// currency.ErrRateUnavailable rather than leave a product without a price.
+func (s *PriceService) Localize(ctx context.Context, code string, products ...*models.Product) error {
+	code, err := s.validCurrency(code)
+	if err != nil {
+		return err
+	}
+
+	var foreign []int64
+	for _, product := range products {
+		if product.Currency != code && product.Sale == nil {
+			foreign = append(foreign, product.ID)
+		}
+	}
+	listed, err := s.priceRepo.FindPrices(ctx, foreign, code)
+	if err != nil {
+		return err
+	}
+
+	exponent := models.CurrencyExponent(code)
+	rates := make(map[string]*big.Rat)
+	for _, product := range products {
+		display := &models.DisplayPrice{Currency: code}
+		if product.Currency == code {
+			display.Amount, display.Source = product.EffectivePrice.InMinorUnits(exponent), models.PriceSourceBase
+		} else if amount, ok := listed[product.ID]; ok {
+			display.Amount, display.Source = amount.InMinorUnits(exponent), models.PriceSourceList
+		} else {
+			rate, ok := rates[product.Currency]
+			if !ok {
+				if rate, err = s.rates.Rate(ctx, product.Currency, code); err != nil {
+					return err
+				}
+				rates[product.Currency] = rate
+			}
+			display.Amount, display.Source = currency.Convert(product.EffectivePrice, rate, exponent), models.PriceSourceExchangeRate
+		}
+		product.DisplayPrice = display
+	}
+
+	return nil
+}
+
+// validCurrency normalizes and validates an ISO 4217 currency code.
+func (s *PriceService) validCurrency(code string) (string, error) {
+	code = normalizeCurrency(code)
+	if err := s.validator.Var(code, "required,iso4217"); err != nil {
+		return "", fmt.Errorf("validation error: %w", err)
+	}
+	return code, nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"product-management/internal/models"
+)
+
+// ErrScheduleEnded is returned when a price schedule would end before it
+// is created.
+var ErrScheduleEnded = errors.New("price schedule ends in the past")
+
+// CreatePriceSchedule schedules a sale price for a product. It may start
+// right away but must not overlap the product's other schedules.
+func (s *ProductService) CreatePriceSchedule(ctx context.Context, actor *models.Identity, productID int64, req *models.PriceScheduleRequest) (*models.PriceSchedule, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Label = strings.TrimSpace(req.Label)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+	if !req.EndsAt.After(time.Now()) {
+		return nil, ErrScheduleEnded
+	}
+
+	schedule := &models.PriceSchedule{
+		ProductID: product.ID,
+		SalePrice: *req.SalePrice,
+		StartsAt:  req.StartsAt.UTC(),
+		EndsAt:    req.EndsAt.UTC(),
+		Label:     req.Label,
+		CreatedBy: &actor.UserID,
+	}
+	if err := s.priceRepo.CreateSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	return schedule, nil
+}
+
+// ListPriceSchedules returns a product's past, running and upcoming
+// schedules. Only those who may change the product see its upcoming sales.
+func (s *ProductService) ListPriceSchedules(ctx context.Context, actor *models.Identity, productID int64) ([]models.PriceSchedule, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID); err != nil {
+		return nil, err
+	}
+
+	return s.priceRepo.ListSchedules(ctx, productID)
+}
+
+// DeletePriceSchedule cancels a schedule. Cancelling a running sale whose
+// start was announced announces its end as well.
+func (s *ProductService) DeletePriceSchedule(ctx context.Context, actor *models.Identity, productID, scheduleID int64) error {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return err
+	}
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		schedule, err := s.priceRepo.FindScheduleForUpdate(ctx, productID, scheduleID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if schedule.ActiveAt(now) && schedule.StartPublishedAt != nil {
+			err := recordPriceChange(ctx, s.priceRepo, s.outboxRepo, &models.PriceChange{
+				ProductID:  product.ID,
+				Currency:   product.Currency,
+				OldPrice:   &schedule.SalePrice,
+				NewPrice:   product.ProductPrice,
+				ChangedBy:  &actor.UserID,
+				Reason:     models.PriceChangeSaleEnded,
+				ScheduleID: &schedule.ID,
+				ChangedAt:  now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.priceRepo.DeleteSchedule(ctx, schedule.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	return nil
+}
+
+// recordRegularPriceChange records that actor changed the regular price or
+// currency of a product. While a sale runs the product keeps selling at the
+// sale price, so a new regular price is only recorded when the sale ends.
+func (s *ProductService) recordRegularPriceChange(ctx context.Context, actor *models.Identity, product *models.Product, oldPrice models.Amount, oldCurrency string) error {
+	now := time.Now()
+	schedules, err := s.priceRepo.FindCurrentSchedules(ctx, []int64{product.ID}, now)
+	if err != nil {
+		return err
+	}
+
+	oldEffective, newEffective := oldPrice, product.ProductPrice
+	for i := range schedules {
+		if schedules[i].ActiveAt(now) {
+			oldEffective, newEffective = schedules[i].SalePrice, schedules[i].SalePrice
+		}
+	}
+
+	change := &models.PriceChange{
+		ProductID: product.ID,
+		Currency:  product.Currency,
+		NewPrice:  newEffective,
+		ChangedBy: &actor.UserID,
+		Reason:    models.PriceChangeUpdated,
+		ChangedAt: now,
+	}
+	if product.Currency == oldCurrency {
+		if oldEffective == newEffective {
+			return nil
+		}
+		change.OldPrice = &oldEffective
+	}
+
+	return recordPriceChange(ctx, s.priceRepo, s.outboxRepo, change)
+}
+
+// applyPriceSchedules sets the effective price and running sale of
+// products at now. It returns when the next of their schedules starts or
+// ends, after which the prices are stale, or the zero time if none will.
+func (s *ProductService) applyPriceSchedules(ctx context.Context, now time.Time, products ...*models.Product) (time.Time, error) {
+	ids := make([]int64, len(products))
+	byID := make(map[int64]*models.Product, len(products))
+	for i, product := range products {
+		product.EffectivePrice, product.Sale = product.ProductPrice, nil
+		ids[i] = product.ID
+		byID[product.ID] = product
+	}
+
+	schedules, err := s.priceRepo.FindCurrentSchedules(ctx, ids, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var next time.Time
+	for i := range schedules {
+		schedule := &schedules[i]
+		boundary := schedule.StartsAt
+		if schedule.ActiveAt(now) {
+			product := byID[schedule.ProductID]
+			product.EffectivePrice, product.Sale = schedule.SalePrice, schedule
+			boundary = schedule.EndsAt
+		}
+		if next.IsZero() || boundary.Before(next) {
+			next = boundary
+		}
+	}
+
+	return next, nil
+}
+
+// cacheTTL shortens ttl so that a cache entry expires when the prices in
+// it change next.
+func cacheTTL(ttl time.Duration, now, nextPriceChange time.Time) time.Duration {
+	if !nextPriceChange.IsZero() && nextPriceChange.Sub(now) < ttl {
+		return nextPriceChange.Sub(now)
+	}
+	return ttl
+}
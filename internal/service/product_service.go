@@ -2,43 +2,88 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"product-management/internal/cache"
 	"product-management/internal/models"
-	"product-management/internal/repository"
 	"product-management/internal/queue"
-	"product-management/internal/cache"
+	"product-management/internal/repository"
 	"product-management/pkg/logger"
 
 	"github.com/go-playground/validator/v10"
 )
 
+// ErrUnknownUser is returned when a product is created for a user that
+// does not exist.
+var ErrUnknownUser = errors.New("unknown user")
+
+// errDuplicateTask rolls back the work of a task another consumer finished
+// first.
+var errDuplicateTask = errors.New("image task already processed")
+
 type ProductService struct {
-	productRepo     *repository.ProductRepository
-	validator       *validator.Validate
-	logger          *logger.Logger
-	rabbitMQPublisher *queue.RabbitMQPublisher
-	redisCache      *cache.RedisCache
+	productRepo    *repository.ProductRepository
+	variantRepo    *repository.ProductVariantRepository
+	priceRepo      *repository.PriceRepository
+	outboxRepo     *repository.OutboxRepository
+	processedRepo  *repository.ProcessedMessageRepository
+	auditRepo      *repository.AuditRepository
+	transactor     *repository.Transactor
+	validator      *validator.Validate
+	logger         *logger.Logger
+	redisCache     *cache.RedisCache
+	imageProcessor *ImageProcessor
+	policy         ProductPolicy
+	// cursorSecret signs listing cursors.
+	cursorSecret []byte
 }
 
 func NewProductService(
 	productRepo *repository.ProductRepository,
+	variantRepo *repository.ProductVariantRepository,
+	priceRepo *repository.PriceRepository,
+	outboxRepo *repository.OutboxRepository,
+	processedRepo *repository.ProcessedMessageRepository,
+	auditRepo *repository.AuditRepository,
+	transactor *repository.Transactor,
 	logger *logger.Logger,
-	rabbitMQPublisher *queue.RabbitMQPublisher,
 	redisCache *cache.RedisCache,
+	imageProcessor *ImageProcessor,
+	cursorSecret []byte,
 ) *ProductService {
 	return &ProductService{
-		productRepo:     productRepo,
-		validator:       validator.New(),
-		logger:          logger,
-		rabbitMQPublisher: rabbitMQPublisher,
-		redisCache:      redisCache,
+		productRepo:    productRepo,
+		variantRepo:    variantRepo,
+		priceRepo:      priceRepo,
+		outboxRepo:     outboxRepo,
+		processedRepo:  processedRepo,
+		auditRepo:      auditRepo,
+		transactor:     transactor,
+		validator:      validator.New(),
+		logger:         logger,
+		redisCache:     redisCache,
+		imageProcessor: imageProcessor,
+		cursorSecret:   cursorSecret,
 	}
 }
 
-func (s *ProductService) CreateProduct(ctx context.Context, req *models.ProductCreateRequest) (*models.Product, error) {
+func (s *ProductService) CreateProduct(ctx context.Context, actor *models.Identity, req *models.ProductCreateRequest) (*models.Product, error) {
+	if err := s.policy.Authorize(actor, ActionCreate, req.UserID); err != nil {
+		return nil, err
+	}
+
 	// Validate input
+	req.Tags = normalizeTags(req.Tags)
+	req.Currency = normalizeCurrency(req.Currency)
+	if req.Currency == "" {
+		req.Currency = models.DefaultCurrency
+	}
 	if err := s.validator.Struct(req); err != nil {
 		return nil, fmt.Errorf("validation error: %w", err)
 	}
@@ -49,30 +94,59 @@ func (s *ProductService) CreateProduct(ctx context.Context, req *models.ProductC
 		ProductName:        req.ProductName,
 		ProductDescription: req.ProductDescription,
 		ProductPrice:       req.ProductPrice,
+		Currency:           req.Currency,
+		EffectivePrice:     req.ProductPrice,
 		ProductImages:      req.ProductImages,
+		Tags:               req.Tags,
 		CreatedAt:          time.Now(),
 		UpdatedAt:          time.Now(),
 	}
 
-	// Save to database
-	if err := s.productRepo.Create(ctx, product); err != nil {
+	// Save the product and its image task together, so the task is
+	// published if and only if the product exists
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.productRepo.Create(ctx, product); err != nil {
+			return err
+		}
+		err := recordPriceChange(ctx, s.priceRepo, s.outboxRepo, &models.PriceChange{
+			ProductID: product.ID,
+			Currency:  product.Currency,
+			NewPrice:  product.ProductPrice,
+			ChangedBy: &actor.UserID,
+			Reason:    models.PriceChangeCreated,
+			ChangedAt: product.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.auditRepo, actor, models.AuditEntityProduct, product.ID, models.AuditActionCreate, nil, productAuditOf(product)); err != nil {
+			return err
+		}
+		if len(product.ProductImages) == 0 {
+			return nil
+		}
+		return s.enqueueImageTask(ctx, product)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownUser, req.UserID)
+		}
 		return nil, err
 	}
 
-	// Publish image processing message
-	if err := s.rabbitMQPublisher.PublishImageProcessingTask(product.ID, product.ProductImages); err != nil {
-		s.logger.Error("Failed to publish image processing task", logger.Error(err))
-		// Log error but don't fail the entire operation
-	}
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
 
 	return product, nil
 }
 
-func (s *ProductService) GetProductByID(ctx context.Context, productID int64) (*models.Product, error) {
+func (s *ProductService) GetProductByID(ctx context.Context, actor *models.Identity, productID int64) (*models.Product, error) {
 	// Try cache first
 	cacheKey := fmt.Sprintf("product:%d", productID)
 	cachedProduct, err := s.redisCache.Get(ctx, cacheKey)
 	if err == nil && cachedProduct != nil {
+		if err := s.policy.Authorize(actor, ActionRead, cachedProduct.UserID); err != nil {
+			return nil, err
+		}
 		return cachedProduct, nil
 	}
 
@@ -81,69 +155,666 @@ func (s *ProductService) GetProductByID(ctx context.Context, productID int64) (*
 	if err != nil {
 		return nil, err
 	}
+	if err := s.policy.Authorize(actor, ActionRead, product.UserID); err != nil {
+		return nil, err
+	}
 
-	// Cache the result
-	if err := s.redisCache.Set(ctx, cacheKey, product, 1*time.Hour); err != nil {
+	variants, err := s.productRepo.FindImageVariants(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	product.Images = groupImageVariants(product.ProductImages, variants)
+
+	product.Categories, err = s.productRepo.FindCategories(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	product.Variants, err = s.variantRepo.ListByProductID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	nextPriceChange, err := s.applyPriceSchedules(ctx, now, product)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the result until it expires or the price changes
+	if err := s.redisCache.Set(ctx, cacheKey, product, cacheTTL(1*time.Hour, now, nextPriceChange)); err != nil {
 		s.logger.Error("Failed to cache product", logger.Error(err))
 	}
 
 	return product, nil
 }
 
-func (s *ProductService) ListProducts(ctx context.Context, params *models.ProductFilterParams) ([]models.Product, int, error) {
+// ListProducts lists the catalog, optionally narrowed down by seller and
+// other filters. Pages are addressed by the cursors of earlier pages, or by
+// page number.
+func (s *ProductService) ListProducts(ctx context.Context, actor *models.Identity, params *models.ProductFilterParams) (*models.ProductPage, error) {
+	if err := s.policy.Authorize(actor, ActionList, params.UserID); err != nil {
+		return nil, err
+	}
+
 	// Validate input
+	params.Query = strings.TrimSpace(params.Query)
+	params.Tags = normalizeTags(params.Tags)
+	params.Attributes = normalizeAttributes(params.Attributes)
 	if err := s.validator.Struct(params); err != nil {
-		return nil, 0, fmt.Errorf("validation error: %w", err)
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	if params.Sort == "" {
+		params.Sort = models.SortCreatedAt
+		if params.Query != "" {
+			params.Sort = models.SortRelevance
+		}
+	}
+	if params.Order == "" {
+		params.Order = models.OrderAsc
+		if params.Sort == models.SortRelevance || params.Sort == models.SortCreatedAt {
+			params.Order = models.OrderDesc
+		}
+	}
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 10
+	}
+	if params.Facets {
+		params.PriceBuckets = normalizePriceBuckets(params.PriceBuckets)
+	} else {
+		params.PriceBuckets = nil
+	}
+
+	fingerprint, err := filterFingerprint(params)
+	if err != nil {
+		return nil, err
+	}
+	if params.Cursor != "" {
+		cursor, err := decodeCursor(s.cursorSecret, params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Filters != fingerprint || cursor.Sort != params.Sort || cursor.Order != params.Order {
+			return nil, ErrInvalidCursor
+		}
+		params.After = cursor
 	}
 
-	// Generate cache key
-	cacheKey := fmt.Sprintf("products:%d:%f:%f:%s:%d:%d", 
-		params.UserID, 
-		params.MinPrice, 
-		params.MaxPrice, 
-		params.ProductName, 
-		params.Page, 
-		params.PageSize,
-	)
+	// Generate cache key. Listings of one seller are kept under that
+	// seller's prefix and catalog-wide ones under products:0, so a change
+	// to a product only invalidates the listings that may contain it.
+	filters, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal filters: %w", err)
+	}
+	cacheKey := fmt.Sprintf("products:%d:%x", params.UserID, sha256.Sum256(filters))
 
 	// Try cache first
-	cachedProducts, totalCount, err := s.redisCache.GetList(ctx, cacheKey)
-	if err == nil && cachedProducts != nil {
-		return cachedProducts, totalCount, nil
+	cachedPage, err := s.redisCache.GetList(ctx, cacheKey)
+	if err == nil && cachedPage != nil {
+		return cachedPage, nil
 	}
 
 	// Fetch from database
-	products, total, err := s.productRepo.FindByUserID(ctx, params)
+	page, err := s.productRepo.List(ctx, params)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+
+	now := time.Now()
+	products := make([]*models.Product, len(page.Products))
+	for i := range page.Products {
+		products[i] = &page.Products[i]
+	}
+	nextPriceChange, err := s.applyPriceSchedules(ctx, now, products...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range []struct {
+		cursor *models.ProductCursor
+		token  *string
+	}{{page.Next, &page.NextCursor}, {page.Prev, &page.PrevCursor}} {
+		if c.cursor == nil {
+			continue
+		}
+		c.cursor.Filters = fingerprint
+		if *c.token, err = encodeCursor(s.cursorSecret, c.cursor); err != nil {
+			return nil, err
+		}
 	}
 
 	// Cache the result
-	if err := s.redisCache.SetList(ctx, cacheKey, products, total, 30*time.Minute); err != nil {
+	if err := s.redisCache.SetList(ctx, cacheKey, page, cacheTTL(30*time.Minute, now, nextPriceChange)); err != nil {
 		s.logger.Error("Failed to cache product list", logger.Error(err))
 	}
 
-	return products, total, nil
+	return page, nil
 }
 
-func (s *ProductService) ProcessProductImages(ctx context.Context, productID int64, imageURLs []string) error {
-	// Simulate image processing (would typically be in a separate microservice)
-	compressedImages := make([]string, len(imageURLs))
-	for i, url := range imageURLs {
-		// In a real-world scenario, this would call an image processing service
-		compressedImages[i] = fmt.Sprintf("compressed_%s", url)
+// SearchProducts finds products of every seller matching a full-text
+// query, best matches first. Results are not cached: queries rarely repeat
+// and the GIN index makes them cheap.
+func (s *ProductService) SearchProducts(ctx context.Context, actor *models.Identity, params *models.ProductSearchParams) ([]models.ProductSearchResult, int, error) {
+	if err := s.policy.Authorize(actor, ActionRead, 0); err != nil {
+		return nil, 0, err
 	}
 
-	// Update product with compressed images
-	if err := s.productRepo.UpdateCompressedImages(ctx, productID, compressedImages); err != nil {
+	params.Query = strings.TrimSpace(params.Query)
+	if err := s.validator.Struct(params); err != nil {
+		return nil, 0, fmt.Errorf("validation error: %w", err)
+	}
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 10
+	}
+
+	results, total, err := s.productRepo.Search(ctx, params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	products := make([]*models.Product, len(results))
+	for i := range results {
+		products[i] = &results[i].Product
+	}
+	if _, err := s.applyPriceSchedules(ctx, time.Now(), products...); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// ProcessImageTask handles an image task delivered at least once. Tasks
+// already processed and tasks for images the product no longer has succeed
+// without doing any work. The source images are fetched every time, but
+// only those whose bytes differ from what was last rendered are rendered.
+func (s *ProductService) ProcessImageTask(ctx context.Context, task *queue.ImageProcessingTask) error {
+	if task.MessageID != "" {
+		processed, err := s.processedRepo.IsProcessed(ctx, task.MessageID)
+		if err != nil {
+			return err
+		}
+		if processed {
+			s.logger.Info(fmt.Sprintf("Skipping duplicate image task %s", task.MessageID))
+			return nil
+		}
+	}
+
+	product, err := s.productRepo.FindByID(ctx, task.ProductID)
+	if err != nil {
+		if errors.Is(err, repository.ErrProductNotFound) {
+			// None of the renditions of a deleted product are needed
+			s.logger.Info(fmt.Sprintf("Removing renditions of deleted product %d", task.ProductID))
+			return s.pruneImages(ctx, task.ProductID, nil)
+		}
 		return err
 	}
 
-	// Invalidate cache
-	cacheKey := fmt.Sprintf("product:%d", productID)
-	if err := s.redisCache.Delete(ctx, cacheKey); err != nil {
-		s.logger.Error("Failed to invalidate product cache", logger.Error(err))
+	currentHash := queue.ImageListHash(product.ProductImages)
+	if task.ImageListHash != "" && task.ImageListHash != currentHash {
+		// The images changed after the task was enqueued; the task for
+		// the new images is on its way
+		s.logger.Info(fmt.Sprintf("Skipping stale image task for product %d", task.ProductID))
+		return nil
+	}
+
+	variants, compressedImages, rendered, err := s.renderImages(ctx, product)
+	if err != nil {
+		return err
+	}
+	changed := rendered || !equalStrings(product.CompressedProductImages, compressedImages)
+
+	var userID int64
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if task.MessageID != "" {
+			recorded, err := s.processedRepo.MarkProcessed(ctx, task.MessageID, product.ID, currentHash)
+			if err != nil {
+				return err
+			}
+			if !recorded {
+				return errDuplicateTask
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+		userID, err = s.productRepo.ReplaceImageVariants(ctx, product.ID, variants, compressedImages)
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.auditRepo, nil, models.AuditEntityImage, product.ID, models.AuditActionUpdate,
+			imageAuditOf(product.CompressedProductImages), imageAuditOf(compressedImages))
+	})
+	if errors.Is(err, errDuplicateTask) {
+		s.logger.Info(fmt.Sprintf("Image task %s was processed concurrently", task.MessageID))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return nil
+	}
+
+	s.invalidateProductCache(ctx, product.ID, userID)
+
+	// Renditions of images the product no longer has are only removed once
+	// the new set is recorded, so no stored URL points at a deleted object
+	return s.pruneImages(ctx, product.ID, product.ProductImages)
+}
+
+// pruneImages deletes the stored renditions of every image of a product
+// except those of the images in keep.
+func (s *ProductService) pruneImages(ctx context.Context, productID int64, keep []string) error {
+	if s.imageProcessor == nil {
+		return fmt.Errorf("image processor is not configured")
+	}
+	return s.imageProcessor.PruneImages(ctx, productID, keep)
+}
+
+// renderImages fetches every source image of a product and renders those
+// whose bytes changed since their renditions were made. It returns the
+// renditions of all images, the primary rendition URL of each and whether
+// any image had to be rendered.
+func (s *ProductService) renderImages(ctx context.Context, product *models.Product) ([]models.ImageVariant, []string, bool, error) {
+	if s.imageProcessor == nil {
+		return nil, nil, false, fmt.Errorf("image processor is not configured")
+	}
+
+	existing, err := s.productRepo.FindImageVariants(ctx, product.ID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	rendered := make(map[int][]models.ImageVariant)
+	for _, v := range existing {
+		rendered[v.ImageIndex] = append(rendered[v.ImageIndex], v)
+	}
+
+	var variants []models.ImageVariant
+	anyRendered := false
+	compressedImages := make([]string, len(product.ProductImages))
+	for i, location := range product.ProductImages {
+		source, err := s.imageProcessor.FetchImage(ctx, location)
+		if errors.Is(err, ErrImageTooLarge) {
+			// Retrying will not make the image any smaller
+			err = fmt.Errorf("%w: %w", queue.ErrInvalidMessage, err)
+		}
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to process images for product %d: %w", product.ID, err)
+		}
+
+		imageVariants := rendered[i]
+		if !renderedFrom(imageVariants, source) {
+			imageVariants, err = s.imageProcessor.RenderImage(ctx, product.ID, source)
+			if err != nil {
+				return nil, nil, false, fmt.Errorf("failed to process images for product %d: %w", product.ID, err)
+			}
+			for j := range imageVariants {
+				imageVariants[j].ImageIndex = i
+			}
+			anyRendered = true
+		}
+
+		variants = append(variants, imageVariants...)
+		compressedImages[i] = s.imageProcessor.primaryURL(imageVariants)
+	}
+
+	return variants, compressedImages, anyRendered, nil
+}
+
+// renderedFrom reports whether variants were all rendered from source.
+func renderedFrom(variants []models.ImageVariant, source *SourceImage) bool {
+	if len(variants) == 0 {
+		return false
+	}
+	for _, v := range variants {
+		if v.SourceImage != source.Location || v.SourceHash != source.Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateProduct replaces every editable field of a product.
+func (s *ProductService) UpdateProduct(ctx context.Context, actor *models.Identity, productID int64, req *models.ProductUpdateRequest) (*models.Product, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return nil, err
 	}
 
+	// Validate input
+	req.Tags = normalizeTags(req.Tags)
+	req.Currency = normalizeCurrency(req.Currency)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	return s.saveProduct(ctx, actor, product, req)
+}
+
+// PatchProduct applies a JSON merge patch (RFC 7386) to the editable fields
+// of a product. Members set to null are cleared; absent members are kept.
+func (s *ProductService) PatchProduct(ctx context.Context, actor *models.Identity, productID int64, patch []byte) (*models.Product, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := json.Marshal(&models.ProductUpdateRequest{
+		ProductName:        product.ProductName,
+		ProductDescription: product.ProductDescription,
+		ProductPrice:       product.ProductPrice,
+		Currency:           product.Currency,
+		ProductImages:      product.ProductImages,
+		Tags:               product.Tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal product: %w", err)
+	}
+
+	merged, err := mergePatch(current, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var req models.ProductUpdateRequest
+	if err := json.Unmarshal(merged, &req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	// Validate the merged document
+	req.Tags = normalizeTags(req.Tags)
+	req.Currency = normalizeCurrency(req.Currency)
+	if err := s.validator.Struct(&req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	return s.saveProduct(ctx, actor, product, &req)
+}
+
+// DeleteProduct removes a product and drops every cache entry that could
+// still reference it. Its stored renditions are removed by the image worker,
+// which cleans up after any image task for a product that no longer exists.
+func (s *ProductService) DeleteProduct(ctx context.Context, actor *models.Identity, productID int64) error {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionDelete, productID)
+	if err != nil {
+		return err
+	}
+
+	var userID int64
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if userID, err = s.productRepo.Delete(ctx, productID); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.auditRepo, actor, models.AuditEntityProduct, productID, models.AuditActionDelete, productAuditOf(product), nil); err != nil {
+			return err
+		}
+		return s.enqueueImageTask(ctx, &models.Product{ID: productID})
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidateProductCache(ctx, productID, userID)
+
 	return nil
-}
\ No newline at end of file
+}
+
+// ReprocessProductImages drops a product's renditions and queues its
+// images to be rendered again, e.g. after the rendition settings changed.
+func (s *ProductService) ReprocessProductImages(ctx context.Context, actor *models.Identity, productID int64) (*models.Product, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionReprocessImages, productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(product.ProductImages) == 0 {
+		return product, nil
+	}
+
+	// The worker skips products whose images all have renditions
+	before := imageAuditOf(product.CompressedProductImages)
+	product.CompressedProductImages = nil
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.productRepo.Update(ctx, product); err != nil {
+			return err
+		}
+		if err := s.productRepo.DeleteImageVariants(ctx, product.ID); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.auditRepo, actor, models.AuditEntityImage, product.ID, models.AuditActionUpdate, before, imageAuditOf(nil)); err != nil {
+			return err
+		}
+		return s.enqueueImageTask(ctx, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+	product.Images = nil
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	return product, nil
+}
+
+// SetProductCategories files a product under exactly the given categories
+// and returns them with their breadcrumbs. The change is audited as a
+// change of the product's category IDs.
+func (s *ProductService) SetProductCategories(ctx context.Context, actor *models.Identity, productID int64, req *models.ProductCategoriesRequest) ([]models.ProductCategory, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	var categories []models.ProductCategory
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.productRepo.FindCategories(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.productRepo.ReplaceCategories(ctx, product.ID, req.CategoryIDs); err != nil {
+			return err
+		}
+		categories, err = s.productRepo.FindCategories(ctx, product.ID)
+		if err != nil {
+			return err
+		}
+
+		return recordAudit(ctx, s.auditRepo, actor, models.AuditEntityProduct, product.ID, models.AuditActionUpdate,
+			categoryAuditOf(before), categoryAuditOf(categories))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	if categories == nil {
+		categories = []models.ProductCategory{}
+	}
+
+	return categories, nil
+}
+
+// saveProduct writes req onto product, persists it and enqueues a new image
+// processing task when the source images changed.
+func (s *ProductService) saveProduct(ctx context.Context, actor *models.Identity, product *models.Product, req *models.ProductUpdateRequest) (*models.Product, error) {
+	imagesChanged := !equalStrings(product.ProductImages, req.ProductImages)
+	oldPrice, oldCurrency := product.ProductPrice, product.Currency
+	before, imagesBefore := productAuditOf(product), imageAuditOf(product.CompressedProductImages)
+
+	product.ProductName = req.ProductName
+	product.ProductDescription = req.ProductDescription
+	product.ProductPrice = req.ProductPrice
+	if req.Currency != "" {
+		product.Currency = req.Currency
+	}
+	product.ProductImages = req.ProductImages
+	product.Tags = req.Tags
+	if imagesChanged {
+		// Stale renditions must not be served for the new images
+		product.CompressedProductImages = nil
+	}
+
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.productRepo.Update(ctx, product); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.auditRepo, actor, models.AuditEntityProduct, product.ID, models.AuditActionUpdate, before, productAuditOf(product)); err != nil {
+			return err
+		}
+		if product.ProductPrice != oldPrice || product.Currency != oldCurrency {
+			if err := s.recordRegularPriceChange(ctx, actor, product, oldPrice, oldCurrency); err != nil {
+				return err
+			}
+		}
+		if !imagesChanged {
+			return nil
+		}
+
+		if err := s.productRepo.DeleteImageVariants(ctx, product.ID); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, s.auditRepo, actor, models.AuditEntityImage, product.ID, models.AuditActionUpdate, imagesBefore, imageAuditOf(nil)); err != nil {
+			return err
+		}
+		return s.enqueueImageTask(ctx, product)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if imagesChanged {
+		product.Images = nil
+	}
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	if _, err := s.applyPriceSchedules(ctx, time.Now(), product); err != nil {
+		return nil, err
+	}
+
+	return product, nil
+}
+
+// enqueueImageTask adds an image processing task for product to the outbox.
+// A task without images makes the worker remove the product's renditions.
+func (s *ProductService) enqueueImageTask(ctx context.Context, product *models.Product) error {
+	payload, err := json.Marshal(queue.NewImageProcessingTask(product.ID, product.ProductImages))
+	if err != nil {
+		return fmt.Errorf("failed to marshal image task: %w", err)
+	}
+
+	return s.outboxRepo.Enqueue(ctx, &models.OutboxMessage{
+		AggregateType: "product",
+		AggregateID:   product.ID,
+		EventType:     queue.ImageTaskType,
+		Exchange:      queue.ImageExchange,
+		RoutingKey:    queue.ImageRoutingKey,
+		Payload:       payload,
+	})
+}
+
+// invalidateProductCache drops the cached product and every cached listing
+// page for its owner and for the whole catalog, since any of them may
+// contain the stale row.
+func (s *ProductService) invalidateProductCache(ctx context.Context, productID, userID int64) {
+	if s.redisCache == nil {
+		return
+	}
+
+	if err := s.redisCache.Delete(ctx, fmt.Sprintf("product:%d", productID)); err != nil {
+		s.logger.Error("Failed to invalidate product cache", logger.Error(err))
+	}
+
+	for _, owner := range []int64{userID, 0} {
+		if err := s.redisCache.DeleteByPattern(ctx, fmt.Sprintf("products:%d:*", owner)); err != nil {
+			s.logger.Error("Failed to invalidate product list cache", logger.Error(err))
+		}
+	}
+}
+
+// groupImageVariants arranges renditions per source image, in the order of
+// the product's images. Images without renditions yet are left out.
+func groupImageVariants(sources []string, variants []models.ImageVariant) []models.ProductImage {
+	byIndex := make(map[int]map[string]models.ImageVariant)
+	for _, v := range variants {
+		if byIndex[v.ImageIndex] == nil {
+			byIndex[v.ImageIndex] = make(map[string]models.ImageVariant)
+		}
+		byIndex[v.ImageIndex][v.Name] = v
+	}
+
+	var images []models.ProductImage
+	for i, source := range sources {
+		if renditions, ok := byIndex[i]; ok {
+			images = append(images, models.ProductImage{Source: source, Variants: renditions})
+		}
+	}
+
+	return images
+}
+
+// normalizePriceBuckets sorts price bucket bounds and drops repeated ones,
+// since width_bucket needs them ascending. Without bounds the default
+// buckets are used.
+func normalizePriceBuckets(bounds []models.Amount) []models.Amount {
+	if len(bounds) == 0 {
+		return slices.Clone(models.DefaultPriceBuckets)
+	}
+
+	bounds = slices.Clone(bounds)
+	slices.Sort(bounds)
+	return slices.Compact(bounds)
+}
+
+// normalizeCurrency upper-cases and trims a currency code.
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeTags lower-cases and trims tags and drops blank and repeated
+// ones, keeping the first occurrence order.
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
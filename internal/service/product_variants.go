@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"product-management/internal/models"
+)
+
+// CreateVariant adds a variant to a product. Its SKU must not be used by
+// any other variant of any product.
+func (s *ProductService) CreateVariant(ctx context.Context, actor *models.Identity, productID int64, req *models.ProductVariantRequest) (*models.ProductVariant, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizeVariantRequest(req)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	variant := newVariant(product.ID, req)
+	if err := s.variantRepo.Create(ctx, variant); err != nil {
+		return nil, err
+	}
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	return variant, nil
+}
+
+func (s *ProductService) ListVariants(ctx context.Context, actor *models.Identity, productID int64) ([]models.ProductVariant, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionRead, productID); err != nil {
+		return nil, err
+	}
+
+	return s.variantRepo.ListByProductID(ctx, productID)
+}
+
+func (s *ProductService) GetVariant(ctx context.Context, actor *models.Identity, productID, variantID int64) (*models.ProductVariant, error) {
+	if _, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionRead, productID); err != nil {
+		return nil, err
+	}
+
+	return s.variantRepo.FindByID(ctx, productID, variantID)
+}
+
+// UpdateVariant replaces every editable field of a variant.
+func (s *ProductService) UpdateVariant(ctx context.Context, actor *models.Identity, productID, variantID int64, req *models.ProductVariantRequest) (*models.ProductVariant, error) {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizeVariantRequest(req)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	variant := newVariant(product.ID, req)
+	variant.ID = variantID
+	if err := s.variantRepo.Update(ctx, variant); err != nil {
+		return nil, err
+	}
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	return variant, nil
+}
+
+func (s *ProductService) DeleteVariant(ctx context.Context, actor *models.Identity, productID, variantID int64) error {
+	product, err := findProductFor(ctx, s.productRepo, s.policy, actor, ActionUpdate, productID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.variantRepo.Delete(ctx, product.ID, variantID); err != nil {
+		return err
+	}
+
+	s.invalidateProductCache(ctx, product.ID, product.UserID)
+
+	return nil
+}
+
+func newVariant(productID int64, req *models.ProductVariantRequest) *models.ProductVariant {
+	return &models.ProductVariant{
+		ProductID:   productID,
+		SKU:         req.SKU,
+		Attributes:  req.Attributes,
+		Price:       req.Price,
+		Barcode:     req.Barcode,
+		WeightGrams: req.WeightGrams,
+	}
+}
+
+// normalizeVariantRequest upper-cases SKUs, so they are unique regardless
+// of case, and normalizes the attributes.
+func normalizeVariantRequest(req *models.ProductVariantRequest) {
+	req.SKU = strings.ToUpper(strings.TrimSpace(req.SKU))
+	req.Barcode = strings.TrimSpace(req.Barcode)
+	req.Attributes = normalizeAttributes(req.Attributes)
+	if req.Attributes == nil {
+		req.Attributes = map[string]string{}
+	}
+}
+
+// normalizeAttributes lower-cases and trims attribute names and trims
+// their values, so filters match regardless of how variants were entered.
+func normalizeAttributes(attributes map[string]string) map[string]string {
+	if attributes == nil {
+		return nil
+	}
+
+	normalized := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		normalized[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return normalized
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/pkg/logger"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type UserService struct {
+	userRepo         *repository.UserRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	auditRepo        *repository.AuditRepository
+	transactor       *repository.Transactor
+	validator        *validator.Validate
+	logger           *logger.Logger
+	policy           UserPolicy
+}
+
+func NewUserService(userRepo *repository.UserRepository, refreshTokenRepo *repository.RefreshTokenRepository, auditRepo *repository.AuditRepository, transactor *repository.Transactor, logger *logger.Logger) *UserService {
+	return &UserService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		auditRepo:        auditRepo,
+		transactor:       transactor,
+		validator:        validator.New(),
+		logger:           logger,
+	}
+}
+
+// CreateUser signs a new user up as a seller.
+func (s *UserService) CreateUser(ctx context.Context, req *models.UserCreateRequest) (*models.User, error) {
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = normalizeEmail(req.Email)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		Role:         models.RoleSeller,
+		PasswordHash: passwordHash,
+	}
+
+	// Signing up is audited without an actor
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return err
+		}
+		after := userAuditOf(user)
+		after.Password = auditRedacted
+		return recordAudit(ctx, s.auditRepo, nil, models.AuditEntityUser, user.ID, models.AuditActionCreate, nil, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserService) GetUserByID(ctx context.Context, actor *models.Identity, userID int64) (*models.User, error) {
+	if err := s.policy.Authorize(actor, ActionRead, userID); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.FindByID(ctx, userID)
+}
+
+// ListUsers returns one page of users. When params.Email is set the page
+// holds at most the user with that email.
+func (s *UserService) ListUsers(ctx context.Context, actor *models.Identity, params *models.UserFilterParams) ([]models.User, int, error) {
+	if err := s.policy.Authorize(actor, ActionList, 0); err != nil {
+		return nil, 0, err
+	}
+
+	if err := s.validator.Struct(params); err != nil {
+		return nil, 0, fmt.Errorf("validation error: %w", err)
+	}
+
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 10
+	}
+
+	if params.Email != "" {
+		user, err := s.userRepo.FindByEmail(ctx, normalizeEmail(params.Email))
+		if err == repository.ErrUserNotFound {
+			return []models.User{}, 0, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		return []models.User{*user}, 1, nil
+	}
+
+	return s.userRepo.List(ctx, params.Page, params.PageSize)
+}
+
+// UpdateUser replaces the editable fields of a user. The password and role
+// are only changed when the request carries them; only admins may change
+// roles. Changing either signs the user out of every session, so a stolen
+// refresh token stops working once the password is reset.
+func (s *UserService) UpdateUser(ctx context.Context, actor *models.Identity, userID int64, req *models.UserUpdateRequest) (*models.User, error) {
+	if err := s.policy.Authorize(actor, ActionUpdate, userID); err != nil {
+		return nil, err
+	}
+	if req.Role != "" && !actor.IsAdmin() {
+		return nil, ErrForbidden
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = normalizeEmail(req.Email)
+	if err := s.validator.Struct(req); err != nil {
+		return nil, fmt.Errorf("validation error: %w", err)
+	}
+
+	user := &models.User{
+		ID:       userID,
+		Username: req.Username,
+		Email:    req.Email,
+		Role:     req.Role,
+	}
+	if req.Password != "" {
+		passwordHash, err := hashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = passwordHash
+	}
+
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.userRepo.FindByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.Update(ctx, user); err != nil {
+			return err
+		}
+		if user.PasswordHash != "" || user.Role != current.Role {
+			if err := s.refreshTokenRepo.RevokeAllForUser(ctx, userID); err != nil {
+				return err
+			}
+		}
+
+		after := userAuditOf(user)
+		if user.PasswordHash != "" {
+			after.Password = auditRedacted
+		}
+		return recordAudit(ctx, s.auditRepo, actor, models.AuditEntityUser, userID, models.AuditActionUpdate, userAuditOf(current), after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteUser removes a user.
+func (s *UserService) DeleteUser(ctx context.Context, actor *models.Identity, userID int64) error {
+	if err := s.policy.Authorize(actor, ActionDelete, userID); err != nil {
+		return err
+	}
+
+	return s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.userRepo.FindByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.Delete(ctx, userID); err != nil {
+			return err
+		}
+		return recordAudit(ctx, s.auditRepo, actor, models.AuditEntityUser, userID, models.AuditActionDelete, userAuditOf(current), nil)
+	})
+}
+
+// normalizeEmail lower-cases emails so lookups are case-insensitive.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSignature is returned when a signed URL is forged or expired.
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// LocalStore keeps objects as plain files below a root directory. It is
+// meant for development and tests where no object store is available.
+type LocalStore struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewLocalStore creates root if needed. baseURL is the address the files are
+// served from, if any; signingKey authenticates URLs from SignedURL.
+func NewLocalStore(root, baseURL string, signingKey []byte) (*LocalStore, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage root: %w", err)
+	}
+	if err := os.MkdirAll(absRoot, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+
+	return &LocalStore{
+		root:       absRoot,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		signingKey: signingKey,
+	}, nil
+}
+
+// path maps a key to a file below root. Cleaning the key as a rooted path
+// strips any ".." elements, so keys can never escape the root.
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	dst := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary object: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write object %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("failed to store object %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to open object %s: %w", key, err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+
+	return f, s.info(key, fi), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	fi, err := os.Stat(s.path(key))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+	if fi.IsDir() {
+		return nil, ErrNotFound
+	}
+
+	return s.info(key, fi), nil
+}
+
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, *s.info(key, fi))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// SignedURL returns baseURL/key with an expiry and an HMAC signature that
+// VerifySignature checks. It requires both a base URL and a signing key.
+func (s *LocalStore) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if s.baseURL == "" || len(s.signingKey) == 0 {
+		return "", fmt.Errorf("local storage has no public URL or signing key configured")
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(key, expires))
+
+	return s.URL(key) + "?" + query.Encode(), nil
+}
+
+// VerifySignature checks the expires and signature query parameters of a URL
+// produced by SignedURL.
+func (s *LocalStore) VerifySignature(key, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, expires))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// URL returns the object's address under the configured base URL, or a
+// file URL when the store is not served over HTTP.
+func (s *LocalStore) URL(key string) string {
+	if s.baseURL == "" {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(s.path(key))}).String()
+	}
+	return s.baseURL + "/" + strings.TrimLeft(path.Clean("/"+key), "/")
+}
+
+func (s *LocalStore) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStore) info(key string, fi fs.FileInfo) *ObjectInfo {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return &ObjectInfo{
+		Key:          key,
+		Size:         fi.Size(),
+		ContentType:  contentType,
+		LastModified: fi.ModTime(),
+	}
+}
@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigAlgorithm     = "AWS4-HMAC-SHA256"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	amzDateFormat    = "20060102T150405Z"
+	amzShortDateFmt  = "20060102"
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// S3Config configures an S3Store.
+type S3Config struct {
+	// Endpoint is the service base URL, e.g. https://s3.amazonaws.com or
+	// http://localhost:9000 for MinIO.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses objects as endpoint/bucket/key instead of
+	// bucket.endpoint/key. MinIO and most S3 stand-ins need it.
+	PathStyle bool
+	Client    *http.Client
+	// Now overrides the signing clock; it defaults to time.Now.
+	Now func() time.Time
+}
+
+// S3Store talks to S3 or an S3-compatible server using the REST API signed
+// with AWS Signature Version 4.
+type S3Store struct {
+	endpoint *url.URL
+	config   S3Config
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &S3Store{endpoint: endpoint, config: cfg}, nil
+}
+
+// objectURL returns the URL of key, or of the bucket when key is empty.
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	escapedKey := uriEncode(key, false)
+
+	if s.config.PathStyle {
+		u.Path = s.endpoint.Path + "/" + s.config.Bucket + "/" + key
+		u.RawPath = s.endpoint.Path + "/" + s.config.Bucket + "/" + escapedKey
+	} else {
+		u.Host = s.config.Bucket + "." + s.endpoint.Host
+		u.Path = s.endpoint.Path + "/" + key
+		u.RawPath = s.endpoint.Path + "/" + escapedKey
+	}
+
+	return &u
+}
+
+// Put uploads an object in a single request. S3 rejects uploads without a
+// Content-Length, so a body of unknown size is read into memory first.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read object %s: %w", key, err)
+		}
+		r, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), r)
+	if err != nil {
+		return fmt.Errorf("failed to build S3 request: %w", err)
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build S3 request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+
+	return resp.Body, objectInfoFromHeader(key, resp.Header), nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to build S3 request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil && err != ErrNotFound {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	return nil
+}
+
+func (s *S3Store) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build S3 request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	return objectInfoFromHeader(key, resp.Header), nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		ETag         string    `xml:"ETag"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+
+	for {
+		u := s.objectURL("")
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build S3 request: %w", err)
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode object listing: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          c.Key,
+				Size:         c.Size,
+				ETag:         strings.Trim(c.ETag, `"`),
+				LastModified: c.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// SignedURL returns a presigned GET URL valid for expiry (at most 7 days).
+func (s *S3Store) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > maxPresignExpiry {
+		return "", fmt.Errorf("presigned URL expiry must be between 1s and %s", maxPresignExpiry)
+	}
+
+	now := s.config.Now().UTC()
+	u := s.objectURL(key)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", sigAlgorithm)
+	query.Set("X-Amz-Credential", s.config.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	header := http.Header{}
+	header.Set("Host", u.Host)
+	signature := s.signature(now, http.MethodGet, u, query, header, []string{"host"}, unsignedPayload)
+
+	u.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+func (s *S3Store) URL(key string) string {
+	return s.objectURL(key).String()
+}
+
+// do signs and sends req, turning 404s into ErrNotFound and other non-2xx
+// responses into errors carrying the S3 error body.
+func (s *S3Store) do(req *http.Request) (*http.Response, error) {
+	s.sign(req)
+
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 responded %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+// sign adds SigV4 headers to req. Payloads are sent unsigned so request
+// bodies can be streamed without being hashed up front.
+func (s *S3Store) sign(req *http.Request) {
+	now := s.config.Now().UTC()
+
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	// The client derives Host from the URL, so it is only set on the copy
+	// of the headers that gets signed
+	header := req.Header.Clone()
+	header.Set("Host", req.URL.Host)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append([]string{"content-type"}, signedHeaders...)
+	}
+
+	signature := s.signature(now, req.Method, req.URL, req.URL.Query(), header, signedHeaders, unsignedPayload)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigAlgorithm, s.config.AccessKey, s.scope(now), strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+func (s *S3Store) scope(now time.Time) string {
+	return now.Format(amzShortDateFmt) + "/" + s.config.Region + "/s3/aws4_request"
+}
+
+func (s *S3Store) signature(now time.Time, method string, u *url.URL, query url.Values, header http.Header, signedHeaders []string, payloadHash string) string {
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(header.Get(name)) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		uriEncode(u.Path, false),
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigAlgorithm,
+		now.Format(amzDateFormat),
+		s.scope(now),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format(amzShortDateFmt))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything except RFC 3986 unreserved characters.
+// Slashes are kept unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func objectInfoFromHeader(key string, header http.Header) *ObjectInfo {
+	info := &ObjectInfo{
+		Key:         key,
+		ContentType: header.Get("Content-Type"),
+		ETag:        strings.Trim(header.Get("ETag"), `"`),
+	}
+	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	info.LastModified, _ = http.ParseTime(header.Get("Last-Modified"))
+	return info
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"product-management/internal/config"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// BlobStore is a flat key/value object store. Keys use forward slashes as
+// separators regardless of the backend.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any existing
+	// object. A negative size means the length is unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens an object for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// Stat returns an object's metadata without reading its content.
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// List returns every object whose key starts with prefix.
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// SignedURL returns a time-limited URL granting read access to key.
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	// URL returns the permanent, unsigned location of key.
+	URL(key string) string
+}
+
+// NewBlobStore builds the backend selected by STORAGE_BACKEND. Both backends
+// keep objects in the S3_BUCKET bucket; the local backend maps it to a
+// directory below STORAGE_LOCAL_DIR.
+func NewBlobStore(cfg *config.Config) (BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return NewLocalStore(
+			filepath.Join(cfg.StorageLocalDir, cfg.S3Bucket),
+			cfg.StoragePublicURL,
+			[]byte(cfg.StorageSigningKey),
+		)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+			Client:    &http.Client{Timeout: 60 * time.Second},
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
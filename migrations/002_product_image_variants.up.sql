@@ -0,0 +1,17 @@
+-- Renditions generated for every entry of products.product_images
+CREATE TABLE product_image_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    image_index INTEGER NOT NULL,
+    source_image TEXT NOT NULL,
+    variant VARCHAR(50) NOT NULL,
+    url TEXT NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (product_id, image_index, variant)
+);
+
+CREATE INDEX idx_product_image_variants_product_id ON product_image_variants(product_id);
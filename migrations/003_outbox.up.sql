@@ -0,0 +1,23 @@
+-- Messages written in the same transaction as the change that caused them
+-- and relayed to RabbitMQ afterwards
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id BIGINT NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    exchange VARCHAR(255) NOT NULL,
+    routing_key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    -- A message that failed to publish waits until then, so it does not
+    -- hold up the messages behind it
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_outbox_pending ON outbox(next_attempt_at, id) WHERE published_at IS NULL;
+-- Finds earlier pending messages of the same aggregate, which must go first
+CREATE INDEX idx_outbox_aggregate_pending ON outbox(aggregate_type, aggregate_id, id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;
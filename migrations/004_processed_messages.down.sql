@@ -0,0 +1,2 @@
+ALTER TABLE product_image_variants DROP COLUMN source_hash;
+DROP TABLE processed_messages;
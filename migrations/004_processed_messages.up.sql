@@ -0,0 +1,14 @@
+-- Messages a consumer has already handled, so redeliveries can be acked
+-- without repeating the work. Rows older than the configured TTL are deleted.
+CREATE TABLE processed_messages (
+    message_id VARCHAR(64) PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    content_hash VARCHAR(64) NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_processed_messages_processed_at ON processed_messages(processed_at);
+
+-- SHA-256 of the source bytes each rendition was made from, so the worker
+-- re-renders images whose bytes changed behind an unchanged URL.
+ALTER TABLE product_image_variants ADD COLUMN source_hash VARCHAR(64);
@@ -0,0 +1,2 @@
+DROP INDEX idx_users_email_lower;
+ALTER TABLE users DROP COLUMN updated_at;
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+
+-- Emails are stored lower-cased; look them up the same way
+CREATE UNIQUE INDEX idx_users_email_lower ON users(LOWER(email));
@@ -0,0 +1,19 @@
+ALTER TABLE users ADD COLUMN password_hash VARCHAR(255);
+
+-- Refresh tokens are stored hashed. Every refresh replaces the token with a
+-- new one of the same family; presenting a replaced token revokes the family.
+CREATE TABLE refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    family_id VARCHAR(32) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
+
+-- Changing a user's password revokes all of their refresh tokens
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens(user_id);
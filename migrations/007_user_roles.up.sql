@@ -0,0 +1,4 @@
+-- admin: any product or user; seller: own products; viewer: read only.
+-- Promote the first admin by hand: UPDATE users SET role = 'admin' WHERE ...
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'seller'
+    CHECK (role IN ('admin', 'seller', 'viewer'));
@@ -0,0 +1,18 @@
+-- API keys let scripts and integrations act as their owner without logging
+-- in. Only a hash of the key is stored; the prefix identifies a key in
+-- listings without revealing it.
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    -- Requests per minute; NULL uses the default limit
+    rate_limit INTEGER CHECK (rate_limit > 0),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys(user_id);
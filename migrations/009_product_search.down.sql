@@ -0,0 +1,4 @@
+-- pg_trgm is left installed; other database objects may rely on it
+DROP INDEX idx_products_name_trgm;
+DROP INDEX idx_products_search_vector;
+ALTER TABLE products DROP COLUMN search_vector;
@@ -0,0 +1,11 @@
+-- Full-text search over names (weighted higher) and descriptions
+ALTER TABLE products ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(product_name, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(product_description, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+
+-- Trigram index for fuzzy name matching, so typos still find products
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX idx_products_name_trgm ON products USING GIN (product_name gin_trgm_ops);
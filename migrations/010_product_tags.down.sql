@@ -0,0 +1,5 @@
+DROP INDEX idx_products_name;
+DROP INDEX idx_products_updated_at;
+DROP INDEX idx_products_created_at;
+DROP INDEX idx_products_tags;
+ALTER TABLE products DROP COLUMN tags;
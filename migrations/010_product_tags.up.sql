@@ -0,0 +1,8 @@
+ALTER TABLE products ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_products_tags ON products USING GIN (tags);
+
+-- Catalog listings sort by these with the ID as tiebreaker
+CREATE INDEX idx_products_created_at ON products(created_at, id);
+CREATE INDEX idx_products_updated_at ON products(updated_at, id);
+CREATE INDEX idx_products_name ON products(product_name, id);
@@ -0,0 +1,24 @@
+-- Categories form a tree. path is the materialized path of IDs from the
+-- root down to the category, e.g. '/1/4/9/', so a subtree is every row
+-- whose path starts with the path of its root.
+CREATE TABLE categories (
+    id BIGSERIAL PRIMARY KEY,
+    parent_id BIGINT REFERENCES categories(id),
+    name VARCHAR(100) NOT NULL,
+    slug VARCHAR(100) NOT NULL,
+    path TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Slugs are unique among siblings; roots have no parent to compare
+CREATE UNIQUE INDEX idx_categories_parent_slug ON categories(COALESCE(parent_id, 0), slug);
+CREATE INDEX idx_categories_path ON categories(path text_pattern_ops);
+
+CREATE TABLE product_categories (
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    category_id BIGINT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX idx_product_categories_category_id ON product_categories(category_id);
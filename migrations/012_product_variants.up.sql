@@ -0,0 +1,16 @@
+-- Sellable versions of a product, e.g. per size and color. A NULL price
+-- sells the variant at the product's price.
+CREATE TABLE product_variants (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku VARCHAR(64) NOT NULL UNIQUE,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    price DECIMAL(10, 2) CHECK (price >= 0),
+    barcode VARCHAR(32),
+    weight_grams INTEGER CHECK (weight_grams >= 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_product_variants_product_id ON product_variants(product_id);
+CREATE INDEX idx_product_variants_attributes ON product_variants USING GIN (attributes jsonb_path_ops);
@@ -0,0 +1,5 @@
+DROP TABLE stock_movements;
+DROP FUNCTION reject_stock_movement_update();
+DROP TABLE stock_reservations;
+DROP TABLE inventory_levels;
+DROP TABLE warehouses;
@@ -0,0 +1,65 @@
+CREATE TABLE warehouses (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Single-warehouse setups need no further configuration
+INSERT INTO warehouses (code, name) VALUES ('main', 'Main warehouse');
+
+-- Stock of a product, or of one of its variants, in a warehouse. Reserved
+-- units are held for unfinished orders; on_hand - reserved is available.
+-- The checks are what keeps concurrent reservations from overselling.
+CREATE TABLE inventory_levels (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id BIGINT REFERENCES product_variants(id) ON DELETE CASCADE,
+    warehouse_id BIGINT NOT NULL REFERENCES warehouses(id),
+    on_hand INTEGER NOT NULL DEFAULT 0 CHECK (on_hand >= 0),
+    reserved INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0 AND reserved <= on_hand),
+    -- A low stock event is published when availability drops to or below it
+    low_stock_threshold INTEGER CHECK (low_stock_threshold >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_inventory_levels_item ON inventory_levels(product_id, COALESCE(variant_id, 0), warehouse_id);
+
+CREATE TABLE stock_reservations (
+    id BIGSERIAL PRIMARY KEY,
+    inventory_id BIGINT NOT NULL REFERENCES inventory_levels(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'committed', 'released')),
+    -- The caller's reference, e.g. an order number
+    reference VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_reservations_inventory_id ON stock_reservations(inventory_id);
+
+-- Every change to an inventory level, as deltas. Rows are never changed;
+-- they only go away with their product.
+CREATE TABLE stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    inventory_id BIGINT NOT NULL REFERENCES inventory_levels(id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('adjust', 'reserve', 'commit', 'release')),
+    on_hand_delta INTEGER NOT NULL,
+    reserved_delta INTEGER NOT NULL,
+    reservation_id BIGINT REFERENCES stock_reservations(id) ON DELETE CASCADE,
+    reason TEXT,
+    actor_id INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stock_movements_inventory_id ON stock_movements(inventory_id, id);
+
+CREATE FUNCTION reject_stock_movement_update() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_append_only
+    BEFORE UPDATE ON stock_movements
+    FOR EACH ROW EXECUTE FUNCTION reject_stock_movement_update();
@@ -0,0 +1,3 @@
+DROP TABLE product_prices;
+
+ALTER TABLE products DROP COLUMN currency;
@@ -0,0 +1,24 @@
+-- Sale prices that apply from starts_at until ends_at. The exclusion
+-- constraint keeps the schedules of a product from overlapping, so at most
+-- one applies at any time.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE price_schedules (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sale_price DECIMAL(10, 2) NOT NULL CHECK (sale_price >= 0),
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    label VARCHAR(100),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    -- Set once the price change at either end was announced
+    start_published_at TIMESTAMP WITH TIME ZONE,
+    end_published_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at),
+    EXCLUDE USING gist (product_id WITH =, tstzrange(starts_at, ends_at) WITH &&)
+);
+
+-- Schedules whose start or end is still to be announced
+CREATE INDEX idx_price_schedules_pending_start ON price_schedules(starts_at) WHERE start_published_at IS NULL;
+CREATE INDEX idx_price_schedules_pending_end ON price_schedules(ends_at) WHERE end_published_at IS NULL;
@@ -0,0 +1,20 @@
+-- Every change of the price a product sells for, its regular price or a
+-- sale price. old_price is NULL when the product was created or changed
+-- currency, as there is no earlier price to compare with.
+CREATE TABLE product_price_history (
+    id BIGSERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    old_price DECIMAL(10, 2),
+    new_price DECIMAL(10, 2) NOT NULL CHECK (new_price >= 0),
+    changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    reason VARCHAR(20) NOT NULL,
+    schedule_id BIGINT REFERENCES price_schedules(id) ON DELETE SET NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_product_price_history_product ON product_price_history(product_id, changed_at);
+
+-- Existing products start out at their current regular price
+INSERT INTO product_price_history (product_id, currency, new_price, changed_by, reason, changed_at)
+SELECT id, currency, product_price, user_id, 'created', COALESCE(created_at, CURRENT_TIMESTAMP) FROM products;
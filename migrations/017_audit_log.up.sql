@@ -0,0 +1,19 @@
+-- Who changed which product, user or product images, and how. Entries are
+-- written in the transaction of the change and never updated. Actors are
+-- kept as plain IDs so entries outlive deleted users.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    actor_id INTEGER,
+    api_key_id BIGINT,
+    request_id VARCHAR(128),
+    source_ip INET,
+    changes JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id, created_at);
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at);
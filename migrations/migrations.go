@@ -0,0 +1,11 @@
+// Package migrations holds the database schema as numbered migrations.
+// Every NNN_name.up.sql has a matching NNN_name.down.sql that undoes it.
+// They are applied by cmd/migrate.
+package migrations
+
+import "embed"
+
+// FS contains every migration file.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -12,7 +12,7 @@ type Logger struct {
 func NewLogger() *Logger {
 	config := zap.NewProductionConfig()
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	
+
 	logger, err := config.Build(zap.AddCallerSkip(1))
 	if err != nil {
 		panic(err)
@@ -37,6 +37,12 @@ func (l *Logger) Warn(msg string, fields ...zap.Field) {
 	l.Logger.Warn(msg, fields...)
 }
 
+// Error wraps err as a structured zap field so call sites can write
+// logger.Error(err) without importing zap directly.
+func Error(err error) zap.Field {
+	return zap.Error(err)
+}
+
 // Example usage for API request logging
 func (l *Logger) LogAPIRequest(method, path string, statusCode int, duration float64) {
 	l.Info("API Request",
@@ -45,4 +51,4 @@ func (l *Logger) LogAPIRequest(method, path string, statusCode int, duration flo
 		zap.Int("status_code", statusCode),
 		zap.Float64("duration_ms", duration),
 	)
-}
\ No newline at end of file
+}
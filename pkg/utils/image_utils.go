@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrUnsupportedFormat is returned for image formats the pipeline cannot
+// decode or encode.
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// DecodeImage decodes a JPEG, PNG, GIF or WebP image and reports its format
+// name as returned by image.Decode ("jpeg", "png", "gif" or "webp").
+func DecodeImage(r io.Reader) (image.Image, string, error) {
+	img, format, err := image.Decode(r)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, "", ErrUnsupportedFormat
+		}
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, format, nil
+}
+
+// ResizeToFit downscales img so that neither side exceeds maxDimension,
+// preserving the aspect ratio. Images that already fit are returned as is;
+// images are never upscaled.
+func ResizeToFit(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxDimension <= 0 || (width <= maxDimension && height <= maxDimension) {
+		return img
+	}
+
+	newWidth, newHeight := maxDimension, maxDimension
+	if width >= height {
+		newHeight = max(1, height*maxDimension/width)
+	} else {
+		newWidth = max(1, width*maxDimension/height)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// EncodeImage encodes img in the given format. Quality (1-100) applies to
+// JPEG output; PNG output always uses the best compression level and WebP
+// output is lossless.
+func EncodeImage(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	case "png":
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		err = encoder.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "webp":
+		var data []byte
+		data, err = EncodeWebP(img)
+		buf.Write(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s image: %w", format, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ContentType returns the MIME type for a format name.
+func ContentType(format string) string {
+	switch format {
+	case "jpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "webp":
+		return "image/webp"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension returns the file extension, without the dot, for a format name.
+func Extension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
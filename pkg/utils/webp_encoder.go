@@ -0,0 +1,306 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// EncodeWebP writes img as a lossless WebP (VP8L) image. The encoder is
+// deliberately simple: no transforms, no color cache and no backward
+// references, just one set of prefix codes over the literal ARGB values.
+// That keeps it small and dependency free at the cost of larger files than
+// libwebp would produce.
+func EncodeWebP(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return nil, errors.New("webp: image dimensions out of range")
+	}
+
+	// Collect non-premultiplied ARGB pixels and per-channel histograms
+	pixels := make([][4]uint8, 0, width*height)
+	var histograms [4][]int
+	for i := range histograms {
+		histograms[i] = make([]int, 256)
+	}
+	hasAlpha := false
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			// Channel order matches the VP8L symbol order: green, red, blue, alpha
+			px := [4]uint8{c.G, c.R, c.B, c.A}
+			for i, v := range px {
+				histograms[i][v]++
+			}
+			if c.A != 0xff {
+				hasAlpha = true
+			}
+			pixels = append(pixels, px)
+		}
+	}
+
+	w := &bitWriter{}
+	w.writeBits(0x2f, 8) // VP8L signature
+	w.writeBits(uint32(width-1), 14)
+	w.writeBits(uint32(height-1), 14)
+	if hasAlpha {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+	w.writeBits(0, 3) // version
+	w.writeBits(0, 1) // no transforms
+	w.writeBits(0, 1) // no color cache
+	w.writeBits(0, 1) // no meta prefix codes
+
+	// Green shares its alphabet with backward-reference lengths (256 + 24)
+	greenHistogram := append(histograms[0], make([]int, 24)...)
+	codes := [4][]prefixCode{
+		writePrefixCode(w, greenHistogram),
+		writePrefixCode(w, histograms[1]),
+		writePrefixCode(w, histograms[2]),
+		writePrefixCode(w, histograms[3]),
+	}
+	// Distance codes are never used but must still be present
+	writePrefixCode(w, make([]int, 40))
+
+	for _, px := range pixels {
+		for i, v := range px {
+			code := codes[i][v]
+			w.writeBits(code.bits, code.length)
+		}
+	}
+
+	data := w.bytes()
+
+	var buf bytes.Buffer
+	chunkSize := len(data)
+	padded := chunkSize + chunkSize&1
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+padded))
+	buf.WriteString("WEBP")
+	buf.WriteString("VP8L")
+	binary.Write(&buf, binary.LittleEndian, uint32(chunkSize))
+	buf.Write(data)
+	if chunkSize&1 == 1 {
+		buf.WriteByte(0)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// prefixCode is a symbol's code, already bit-reversed for LSB-first output.
+type prefixCode struct {
+	bits   uint32
+	length uint
+}
+
+// codeLengthOrder is the order code-length code lengths are transmitted in.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writePrefixCode writes the prefix code for a histogram and returns the
+// code of every symbol.
+func writePrefixCode(w *bitWriter, histogram []int) []prefixCode {
+	var used []int
+	for symbol, count := range histogram {
+		if count > 0 {
+			used = append(used, symbol)
+		}
+	}
+
+	codes := make([]prefixCode, len(histogram))
+
+	// Up to two 8-bit symbols fit a "simple" code; a single symbol costs
+	// no bits at all per pixel
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		w.writeBits(1, 1) // simple code
+		switch len(used) {
+		case 0:
+			w.writeBits(0, 1)
+			w.writeBits(0, 1)
+			w.writeBits(0, 1)
+		case 1:
+			w.writeBits(0, 1)
+			w.writeBits(1, 1)
+			w.writeBits(uint32(used[0]), 8)
+		case 2:
+			w.writeBits(1, 1)
+			w.writeBits(1, 1)
+			w.writeBits(uint32(used[0]), 8)
+			w.writeBits(uint32(used[1]), 8)
+			codes[used[1]] = prefixCode{bits: 1, length: 1}
+		}
+		return codes
+	}
+
+	lengths := huffmanLengths(histogram, 15)
+	for symbol, code := range canonicalCodes(lengths) {
+		codes[symbol] = code
+	}
+
+	// Code lengths are sent literally (symbols 0-15), never run-length coded
+	lengthHistogram := make([]int, 19)
+	for _, l := range lengths {
+		lengthHistogram[l]++
+	}
+	lengthLengths := huffmanLengths(lengthHistogram, 7)
+	lengthCodes := canonicalCodes(lengthLengths)
+
+	w.writeBits(0, 1)                              // normal code
+	w.writeBits(uint32(len(codeLengthOrder)-4), 4) // all 19 code length code lengths
+	for _, symbol := range codeLengthOrder {
+		w.writeBits(uint32(lengthLengths[symbol]), 3)
+	}
+	w.writeBits(0, 1) // max_symbol equals the alphabet size
+
+	for _, l := range lengths {
+		code := lengthCodes[l]
+		w.writeBits(code.bits, code.length)
+	}
+
+	return codes
+}
+
+// huffmanLengths returns Huffman code lengths no longer than maxLength.
+// At least two symbols always get a code so the result is a complete code
+// even for degenerate histograms.
+func huffmanLengths(histogram []int, maxLength int) []int {
+	counts := append([]int(nil), histogram...)
+
+	nonZero := 0
+	for _, c := range counts {
+		if c > 0 {
+			nonZero++
+		}
+	}
+	for i := 0; nonZero < 2 && i < len(counts); i++ {
+		if counts[i] == 0 {
+			counts[i] = 1
+			nonZero++
+		}
+	}
+
+	for floor := 0; ; floor = floor*2 + 1 {
+		// Raising small counts flattens the tree until it fits maxLength
+		adjusted := make([]int, len(counts))
+		for i, c := range counts {
+			if c > 0 {
+				adjusted[i] = max(c, floor)
+			}
+		}
+
+		lengths := buildHuffmanLengths(adjusted)
+		longest := 0
+		for _, l := range lengths {
+			longest = max(longest, l)
+		}
+		if longest <= maxLength {
+			return lengths
+		}
+	}
+}
+
+func buildHuffmanLengths(counts []int) []int {
+	type node struct {
+		weight      int
+		symbol      int
+		left, right *node
+	}
+
+	var nodes []*node
+	for symbol, c := range counts {
+		if c > 0 {
+			nodes = append(nodes, &node{weight: c, symbol: symbol})
+		}
+	}
+
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].weight < nodes[j].weight })
+		merged := &node{weight: nodes[0].weight + nodes[1].weight, symbol: -1, left: nodes[0], right: nodes[1]}
+		nodes = append([]*node{merged}, nodes[2:]...)
+	}
+
+	lengths := make([]int, len(counts))
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if n.left == nil {
+			lengths[n.symbol] = depth
+			return
+		}
+		walk(n.left, depth+1)
+		walk(n.right, depth+1)
+	}
+	walk(nodes[0], 0)
+
+	return lengths
+}
+
+// canonicalCodes assigns canonical codes (shorter codes first, ties broken
+// by symbol) and bit-reverses them for the LSB-first bit writer.
+func canonicalCodes(lengths []int) []prefixCode {
+	maxLength := 0
+	for _, l := range lengths {
+		maxLength = max(maxLength, l)
+	}
+
+	countPerLength := make([]int, maxLength+1)
+	for _, l := range lengths {
+		if l > 0 {
+			countPerLength[l]++
+		}
+	}
+
+	nextCode := make([]uint32, maxLength+2)
+	code := uint32(0)
+	for l := 1; l <= maxLength; l++ {
+		code = (code + uint32(countPerLength[l-1])) << 1
+		nextCode[l] = code
+	}
+
+	codes := make([]prefixCode, len(lengths))
+	for symbol, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c := nextCode[l]
+		nextCode[l]++
+
+		var reversed uint32
+		for i := 0; i < l; i++ {
+			reversed = reversed<<1 | (c>>i)&1
+		}
+		codes[symbol] = prefixCode{bits: reversed, length: uint(l)}
+	}
+
+	return codes
+}
+
+// bitWriter packs bits least-significant first, as VP8L requires.
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *bitWriter) writeBits(bits uint32, n uint) {
+	w.acc |= uint64(bits) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+	return w.buf
+}
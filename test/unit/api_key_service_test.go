@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var apiKeyColumns = []string{
+	"id", "user_id", "name", "prefix", "key_hash", "scopes",
+	"rate_limit", "last_used_at", "revoked_at", "created_at",
+}
+
+func newTestAPIKeyService(t *testing.T) (*service.APIKeyService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	apiKeyService := service.NewAPIKeyService(
+		repository.NewAPIKeyRepository(db, appLogger),
+		repository.NewUserRepository(db, appLogger),
+		appLogger,
+	)
+
+	return apiKeyService, mock
+}
+
+func TestCreateAPIKeyReturnsKeyOnce(t *testing.T) {
+	apiKeyService, mock := newTestAPIKeyService(t)
+
+	mock.ExpectQuery(`INSERT INTO api_keys`).
+		WithArgs(int64(7), "ingest", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, time.Now()))
+
+	key, err := apiKeyService.CreateAPIKey(context.Background(), seller, &models.APIKeyCreateRequest{
+		Name:   "ingest",
+		Scopes: []string{models.ScopeProductsWrite},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey returned error: %v", err)
+	}
+	if !strings.HasPrefix(key.Key, "pmk_") || !strings.HasPrefix(key.Key, key.Prefix) || key.KeyHash == key.Key {
+		t.Errorf("unexpected key %+v", key)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateAPIKeyRejectsUnknownScope(t *testing.T) {
+	apiKeyService, _ := newTestAPIKeyService(t)
+
+	_, err := apiKeyService.CreateAPIKey(context.Background(), seller, &models.APIKeyCreateRequest{
+		Name:   "ingest",
+		Scopes: []string{"users:write"},
+	})
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+}
+
+func TestAPIKeysCannotManageKeys(t *testing.T) {
+	apiKeyService, _ := newTestAPIKeyService(t)
+	key := &models.Identity{UserID: 7, Role: models.RoleSeller, APIKeyID: 2, Scopes: []string{models.ScopeProductsWrite}}
+
+	_, err := apiKeyService.CreateAPIKey(context.Background(), key, &models.APIKeyCreateRequest{
+		Name:   "escalate",
+		Scopes: []string{models.ScopeProductsWrite},
+	})
+	if !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestAuthenticateAPIKeyActsAsOwner(t *testing.T) {
+	apiKeyService, mock := newTestAPIKeyService(t)
+
+	mock.ExpectQuery(`SELECT (.+) FROM api_keys WHERE key_hash = \$1 AND revoked_at IS NULL`).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+			AddRow(2, 7, "ingest", "pmk_abcdefgh", "hash", "{products:read}", 120, nil, nil, time.Now()))
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(7, "bob", "bob@example.com", "seller", "", time.Now(), time.Now()))
+	mock.ExpectExec(`UPDATE api_keys\s+SET last_used_at`).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	identity, err := apiKeyService.Authenticate(context.Background(), "pmk_abcdefgh-rest")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if identity.UserID != 7 || identity.Role != models.RoleSeller || identity.APIKeyID != 2 || identity.RateLimit != 120 {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+	if !identity.HasScope(models.ScopeProductsRead) || identity.HasScope(models.ScopeProductsWrite) {
+		t.Errorf("unexpected scopes %v", identity.Scopes)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAPIKeyScopesLimitProductActions(t *testing.T) {
+	readOnly := &models.Identity{UserID: 7, Role: models.RoleSeller, APIKeyID: 2, Scopes: []string{models.ScopeProductsRead}}
+	policy := service.ProductPolicy{}
+
+	if err := policy.Authorize(readOnly, service.ActionRead, 7); err != nil {
+		t.Errorf("expected read to be allowed, got %v", err)
+	}
+	if err := policy.Authorize(readOnly, service.ActionUpdate, 7); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected update to be forbidden, got %v", err)
+	}
+	if err := policy.Authorize(readOnly, service.ActionReprocessImages, 7); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected reprocessing to be forbidden, got %v", err)
+	}
+	if err := (service.UserPolicy{}).Authorize(readOnly, service.ActionRead, 7); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected API keys to have no access to accounts, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"product-management/internal/api"
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+var auditColumns = []string{
+	"id", "entity_type", "entity_id", "action", "actor_id", "api_key_id",
+	"request_id", "source_ip", "changes", "created_at",
+}
+
+// expectAudit expects an audit entry to be written for a change of an
+// entity, by anyone.
+func expectAudit(mock sqlmock.Sqlmock, entityType string, entityID int64, action string) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(entityType, entityID, action, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+}
+
+// auditChanges matches the changes of an audit entry by the JSON of each
+// changed field before and after.
+type auditChanges map[string][2]string
+
+func (m auditChanges) Match(v driver.Value) bool {
+	data, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	var changes map[string]models.AuditChange
+	if err := json.Unmarshal(data, &changes); err != nil || len(changes) != len(m) {
+		return false
+	}
+	for name, want := range m {
+		got, ok := changes[name]
+		if !ok || string(got.Before) != want[0] || string(got.After) != want[1] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpdateUserAuditsChangedFieldsWithRequest(t *testing.T) {
+	userService, mock := newTestUserService(t)
+	actor := &models.Identity{UserID: 3, Role: models.RoleSeller}
+	ctx := service.WithRequestInfo(context.Background(), models.RequestInfo{
+		RequestID: "req-1",
+		SourceIP:  "203.0.113.9",
+	})
+
+	// The password hash stays out of the log
+	mock.ExpectBegin()
+	expectFindUser(mock, 3)
+	mock.ExpectQuery(`UPDATE users`).
+		WithArgs("bob", "bobby@example.com", "", sqlmock.AnyArg(), int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "created_at", "updated_at"}).
+			AddRow(models.RoleSeller, time.Now(), time.Now()))
+	expectRevokeUserTokens(mock, 3)
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(models.AuditEntityUser, int64(3), models.AuditActionUpdate, int64(3), nil, "req-1", "203.0.113.9", auditChanges{
+			"email":    {`"bob@example.com"`, `"bobby@example.com"`},
+			"password": {``, `"[redacted]"`},
+		}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	_, err := userService.UpdateUser(ctx, actor, 3, &models.UserUpdateRequest{
+		Username: "bob",
+		Email:    "Bobby@example.com",
+		Password: "correct horse",
+	})
+	if err != nil {
+		t.Fatalf("UpdateUser returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateUserWithoutChangesIsNotAudited(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectBegin()
+	expectFindUser(mock, 3)
+	mock.ExpectQuery(`UPDATE users`).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "created_at", "updated_at"}).
+			AddRow(models.RoleSeller, time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	_, err := userService.UpdateUser(context.Background(), &models.Identity{UserID: 3}, 3, &models.UserUpdateRequest{
+		Username: "bob",
+		Email:    "bob@example.com",
+	})
+	if err != nil {
+		t.Fatalf("UpdateUser returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func newTestAuditService(t *testing.T) (*service.AuditService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	return service.NewAuditService(repository.NewAuditRepository(db, appLogger), appLogger), mock
+}
+
+func TestListAuditEntriesFilters(t *testing.T) {
+	auditService, mock := newTestAuditService(t)
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM audit_log WHERE entity_type = \$1 AND entity_id = \$2 AND actor_id = \$3 AND created_at >= \$4 AND created_at < \$5`).
+		WithArgs(models.AuditEntityProduct, int64(1), int64(7), from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+	mock.ExpectQuery(`SELECT (.+) FROM audit_log WHERE (.+) ORDER BY created_at DESC, id DESC\s+LIMIT \$6 OFFSET \$7`).
+		WithArgs(models.AuditEntityProduct, int64(1), int64(7), from, to, 20, 20).
+		WillReturnRows(sqlmock.NewRows(auditColumns).
+			AddRow(4, "product", 1, "update", 7, nil, "req-1", "203.0.113.9",
+				[]byte(`{"product_name":{"before":"Old","after":"New"}}`), from.Add(time.Hour)))
+
+	entries, total, err := auditService.ListEntries(context.Background(), admin, &models.AuditFilterParams{
+		EntityType: models.AuditEntityProduct,
+		EntityID:   1,
+		ActorID:    7,
+		From:       &from,
+		To:         &to,
+		Page:       2,
+	})
+	if err != nil {
+		t.Fatalf("ListEntries returned error: %v", err)
+	}
+	if total != 21 || len(entries) != 1 {
+		t.Fatalf("unexpected page %+v (total %d)", entries, total)
+	}
+	if e := entries[0]; *e.ActorID != 7 || e.APIKeyID != nil || e.SourceIP != "203.0.113.9" || !strings.Contains(string(e.Changes), `"after":"New"`) {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditLogIsForAdminsOnly(t *testing.T) {
+	auditService, _ := newTestAuditService(t)
+
+	for _, actor := range []*models.Identity{
+		nil,
+		seller,
+		{UserID: 1, Role: models.RoleAdmin, APIKeyID: 2},
+	} {
+		_, _, err := auditService.ListEntries(context.Background(), actor, &models.AuditFilterParams{})
+		if !errors.Is(err, service.ErrForbidden) {
+			t.Errorf("%+v: expected ErrForbidden, got %v", actor, err)
+		}
+	}
+}
+
+func TestRequestInfoMiddlewareSetsRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(routes.RequestInfo())
+	router.GET("/products", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	get := func(requestID string) string {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		if requestID != "" {
+			req.Header.Set(routes.RequestIDHeader, requestID)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Header().Get(routes.RequestIDHeader)
+	}
+
+	if id := get("upstream-42"); id != "upstream-42" {
+		t.Errorf("expected the client's request ID to be kept, got %q", id)
+	}
+	for _, requestID := range []string{"", "with spaces", strings.Repeat("x", 129)} {
+		if id := get(requestID); len(id) != 32 || id == requestID {
+			t.Errorf("expected a generated request ID for %q, got %q", requestID, id)
+		}
+	}
+}
+
+func TestRequestInfoMiddlewareAuditsConnectingAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userService, mock := newTestUserService(t)
+
+	// gin trusts every proxy unless told otherwise, yet the forwarded
+	// address must not end up in the audit log
+	router := gin.New()
+	router.Use(routes.RequestInfo())
+	router.DELETE("/users/3", func(c *gin.Context) {
+		if err := userService.DeleteUser(c.Request.Context(), admin, 3); err != nil {
+			t.Errorf("DeleteUser returned error: %v", err)
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	mock.ExpectBegin()
+	expectFindUser(mock, 3)
+	mock.ExpectExec(`DELETE FROM users`).
+		WithArgs(int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(models.AuditEntityUser, int64(3), models.AuditActionDelete, admin.UserID, nil, sqlmock.AnyArg(), "192.0.2.10", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/3", nil)
+	req.RemoteAddr = "192.0.2.10:4321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.66")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,223 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"product-management/internal/api"
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testAuthConfig = service.AuthConfig{
+	Secret:          []byte("test-secret"),
+	Issuer:          "product-management",
+	AccessTokenTTL:  15 * time.Minute,
+	RefreshTokenTTL: time.Hour,
+}
+
+var refreshTokenColumns = []string{"id", "user_id", "token_hash", "family_id", "expires_at", "revoked_at", "created_at"}
+
+func newTestAuthService(t *testing.T) (*service.AuthService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	authService := service.NewAuthService(
+		repository.NewUserRepository(db, appLogger),
+		repository.NewRefreshTokenRepository(db, appLogger),
+		repository.NewTransactor(db),
+		appLogger,
+		testAuthConfig,
+	)
+
+	return authService, mock
+}
+
+func expectCreateRefreshToken(mock sqlmock.Sqlmock, userID int64) {
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs(userID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+}
+
+func TestLoginIssuesTokens(t *testing.T) {
+	authService, mock := newTestAuthService(t)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE LOWER\(email\)`).
+		WithArgs("alice@example.com").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(5, "alice", "alice@example.com", "seller", string(hash), time.Now(), time.Now()))
+	expectCreateRefreshToken(mock, 5)
+
+	tokens, err := authService.Login(context.Background(), &models.LoginRequest{
+		Email:    "Alice@example.com",
+		Password: "correct horse",
+	})
+	if err != nil {
+		t.Fatalf("Login returned error: %v", err)
+	}
+	if tokens.RefreshToken == "" || tokens.TokenType != "Bearer" || tokens.ExpiresIn != 900 {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+
+	identity, err := authService.Authenticate(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if identity.UserID != 5 || identity.Role != models.RoleSeller {
+		t.Errorf("expected seller 5, got %+v", identity)
+	}
+}
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	authService, mock := newTestAuthService(t)
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE LOWER\(email\)`).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(5, "alice", "alice@example.com", "seller", string(hash), time.Now(), time.Now()))
+
+	_, err := authService.Login(context.Background(), &models.LoginRequest{
+		Email:    "alice@example.com",
+		Password: "battery staple",
+	})
+	if !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	authService, mock := newTestAuthService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens (.+) FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+			AddRow(7, 5, "hash", "family", time.Now().Add(time.Hour), nil, time.Now()))
+	mock.ExpectExec(`UPDATE refresh_tokens\s+SET revoked_at (.+) WHERE id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = \$1`).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(5, "alice", "alice@example.com", "admin", "", time.Now(), time.Now()))
+	mock.ExpectQuery(`INSERT INTO refresh_tokens`).
+		WithArgs(int64(5), sqlmock.AnyArg(), "family", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(8, time.Now()))
+	mock.ExpectCommit()
+
+	tokens, err := authService.Refresh(context.Background(), "old-token")
+	if err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if tokens.RefreshToken == "old-token" || tokens.AccessToken == "" {
+		t.Errorf("expected a new token pair, got %+v", tokens)
+	}
+	if identity, err := authService.Authenticate(tokens.AccessToken); err != nil || identity.Role != models.RoleAdmin {
+		t.Errorf("expected the access token to carry the current role, got %+v, %v", identity, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefreshWithReusedTokenRevokesFamily(t *testing.T) {
+	authService, mock := newTestAuthService(t)
+
+	revokedAt := time.Now().Add(-time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM refresh_tokens (.+) FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+			AddRow(7, 5, "hash", "family", time.Now().Add(time.Hour), revokedAt, time.Now()))
+	mock.ExpectExec(`UPDATE refresh_tokens\s+SET revoked_at (.+) WHERE family_id = \$1`).
+		WithArgs("family").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	_, err := authService.Refresh(context.Background(), "stolen-token")
+	if !errors.Is(err, service.ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuthenticateRejectsForgedTokens(t *testing.T) {
+	authService, _ := newTestAuthService(t)
+
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.RegisteredClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := jwt.RegisteredClaims{
+		Issuer:    testAuthConfig.Issuer,
+		Subject:   "5",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	for name, token := range map[string]string{
+		"wrong secret": sign(jwt.SigningMethodHS256, []byte("other-secret"), valid),
+		"alg none":     sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid),
+		"expired":      sign(jwt.SigningMethodHS256, testAuthConfig.Secret, expired),
+		"garbage":      "not.a.token",
+	} {
+		if _, err := authService.Authenticate(token); !errors.Is(err, service.ErrInvalidToken) {
+			t.Errorf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestRequireAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authService, _ := newTestAuthService(t)
+	apiKeyService, apiKeyMock := newTestAPIKeyService(t)
+
+	router := gin.New()
+	router.GET("/me", routes.RequireAuth(authService, apiKeyService, logger.NewLogger()), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    testAuthConfig.Issuer,
+		Subject:   "5",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString(testAuthConfig.Secret)
+	apiKeyMock.ExpectQuery(`SELECT (.+) FROM api_keys WHERE key_hash`).
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns))
+
+	for header, want := range map[string]int{
+		"":                http.StatusUnauthorized,
+		"Bearer":          http.StatusUnauthorized,
+		"Bearer nonsense": http.StatusUnauthorized,
+		"Basic " + token:  http.StatusUnauthorized,
+		"Bearer " + token: http.StatusNoContent,
+		"ApiKey pmk_gone": http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("Authorization %q: expected %d, got %d", header, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func newTestCategoryService(t *testing.T) (*service.CategoryService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	return service.NewCategoryService(repository.NewCategoryRepository(db, appLogger), appLogger, nil), mock
+}
+
+func TestCreateCategoryBelowParent(t *testing.T) {
+	categoryService, mock := newTestCategoryService(t)
+	parentID := int64(1)
+
+	// The parent's path is read under the tree lock, after any move of the
+	// parent has committed
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO categories`).
+		WithArgs(&parentID, "Running Shoes", "running-shoes").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "path", "created_at", "updated_at"}).
+			AddRow(4, "/1/4/", time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	category, err := categoryService.CreateCategory(context.Background(), admin, &models.CategoryRequest{
+		ParentID: &parentID,
+		Name:     " Running Shoes ",
+		Slug:     "Running Shoes",
+	})
+	if err != nil {
+		t.Fatalf("CreateCategory returned error: %v", err)
+	}
+	if category.ID != 4 || category.Depth != 1 {
+		t.Errorf("unexpected category %+v", category)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOnlyAdminsChangeCategories(t *testing.T) {
+	categoryService, _ := newTestCategoryService(t)
+
+	_, err := categoryService.CreateCategory(context.Background(), seller, &models.CategoryRequest{Name: "Shoes", Slug: "shoes"})
+	if !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if err := categoryService.DeleteCategory(context.Background(), seller, 1); !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestMoveCategoryRewritesSubtreePaths(t *testing.T) {
+	categoryService, mock := newTestCategoryService(t)
+	parentID := int64(2)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT path FROM categories WHERE id = \$1`).
+		WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"path"}).AddRow("/1/4/"))
+	mock.ExpectQuery(`SELECT path FROM categories WHERE id = \$1`).
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"path"}).AddRow("/2/"))
+	mock.ExpectQuery(`UPDATE categories\s+SET parent_id`).
+		WithArgs(&parentID, "Shoes", "shoes", int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(time.Now(), time.Now()))
+	mock.ExpectExec(`UPDATE categories\s+SET path = \$1 \|\| substr\(path, \$2\)\s+WHERE path LIKE \$3`).
+		WithArgs("/2/4/", 6, "/1/4/").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	category, err := categoryService.UpdateCategory(context.Background(), admin, 4, &models.CategoryRequest{
+		ParentID: &parentID,
+		Name:     "Shoes",
+		Slug:     "shoes",
+	})
+	if err != nil {
+		t.Fatalf("UpdateCategory returned error: %v", err)
+	}
+	if category.Path != "/2/4/" {
+		t.Errorf("expected path /2/4/, got %q", category.Path)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMoveCategoryBelowItselfFails(t *testing.T) {
+	categoryService, mock := newTestCategoryService(t)
+	parentID := int64(9)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT path FROM categories WHERE id = \$1`).
+		WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"path"}).AddRow("/1/4/"))
+	mock.ExpectQuery(`SELECT path FROM categories WHERE id = \$1`).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"path"}).AddRow("/1/4/9/"))
+	mock.ExpectRollback()
+
+	_, err := categoryService.UpdateCategory(context.Background(), admin, 4, &models.CategoryRequest{
+		ParentID: &parentID,
+		Name:     "Shoes",
+		Slug:     "shoes",
+	})
+	if !errors.Is(err, repository.ErrCategoryCycle) {
+		t.Fatalf("expected ErrCategoryCycle, got %v", err)
+	}
+}
+
+func TestListProductsInCategorySubtree(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products WHERE id IN \(\s+SELECT pc.product_id FROM product_categories pc\s+JOIN categories c ON c.id = pc.category_id\s+WHERE c.path LIKE \(SELECT path FROM categories WHERE id = \$1\) \|\| '%'\)$`).
+		WithArgs(int64(4)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT (.+) FROM products WHERE id IN`).
+		WithArgs(int64(4), 11, 0).
+		WillReturnRows(sqlmock.NewRows(listColumns))
+
+	page, err := productService.ListProducts(context.Background(), seller, &models.ProductFilterParams{
+		CategoryID:         4,
+		IncludeDescendants: true,
+	})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if len(page.Products) != 0 {
+		t.Errorf("expected no products, got %+v", page.Products)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetProductCategoriesAuditsCategoryIDs(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM product_categories`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"category_id", "id", "name", "slug"}).
+			AddRow(9, 9, "Sale", "sale"))
+	mock.ExpectExec(`DELETE FROM product_categories WHERE product_id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO product_categories`).
+		WithArgs(int64(1), pq.Array([]int64{4, 9})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expectFindCategories(mock, 1)
+	mock.ExpectQuery(`INSERT INTO audit_log`).
+		WithArgs(models.AuditEntityProduct, int64(1), models.AuditActionUpdate, int64(7), nil, "", "", auditChanges{
+			"category_ids": {`[9]`, `[4,9]`},
+		}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	categories, err := productService.SetProductCategories(context.Background(), seller, 1, &models.ProductCategoriesRequest{
+		CategoryIDs: []int64{4, 9},
+	})
+	if err != nil {
+		t.Fatalf("SetProductCategories returned error: %v", err)
+	}
+	if len(categories) != 2 || len(categories[0].Breadcrumbs) != 2 {
+		t.Errorf("unexpected categories %+v", categories)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetProductCategoriesRejectsUnknownCategory(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectBegin()
+	expectFindCategories(mock, 1)
+	mock.ExpectExec(`DELETE FROM product_categories WHERE product_id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO product_categories`).
+		WithArgs(int64(1), pq.Array([]int64{4, 99})).
+		WillReturnError(&pq.Error{Code: "23503"})
+	mock.ExpectRollback()
+
+	_, err := productService.SetProductCategories(context.Background(), seller, 1, &models.ProductCategoriesRequest{
+		CategoryIDs: []int64{4, 99},
+	})
+	if !errors.Is(err, repository.ErrUnknownCategory) {
+		t.Fatalf("expected ErrUnknownCategory, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,55 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"product-management/internal/config"
+)
+
+func TestLoadConfigRejectsUnparsableValues(t *testing.T) {
+	for key, value := range map[string]string{
+		"OUTBOX_BATCH_SIZE":    "ten",
+		"OUTBOX_POLL_INTERVAL": "5",
+		"RABBITMQ_PREFETCH":    "4.5",
+	} {
+		t.Setenv(key, value)
+		_, err := config.LoadConfig()
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("%s=%s: expected an error naming the setting, got %v", key, value, err)
+		}
+		t.Setenv(key, "")
+	}
+}
+
+func TestLoadConfigUsesDefaultsForUnsetValues(t *testing.T) {
+	t.Setenv("OUTBOX_BATCH_SIZE", "")
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+	if cfg.OutboxBatchSize != 100 {
+		t.Errorf("expected the default batch size, got %d", cfg.OutboxBatchSize)
+	}
+}
+
+func TestLoadConfigTrustsNoProxiesByDefault(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+	if cfg.TrustedProxies != nil {
+		t.Errorf("expected no trusted proxies, got %v", cfg.TrustedProxies)
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.1")
+	cfg, err = config.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig returned error: %v", err)
+	}
+	if strings.Join(cfg.TrustedProxies, ",") != "10.0.0.0/8,192.168.1.1" {
+		t.Errorf("unexpected trusted proxies %v", cfg.TrustedProxies)
+	}
+}
@@ -0,0 +1,310 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"product-management/internal/service"
+	"product-management/internal/storage"
+	"product-management/pkg/utils"
+)
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newTestImageProcessor(t *testing.T, sourceDir string) (*service.ImageProcessor, string) {
+	t.Helper()
+
+	outputDir := t.TempDir()
+	store, err := storage.NewLocalStore(outputDir, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create local store: %v", err)
+	}
+
+	processor := service.NewImageProcessor(
+		service.NewImageSource(http.DefaultClient, sourceDir),
+		store,
+		service.ImageProcessorConfig{
+			Renditions: []service.RenditionSpec{
+				{Name: "thumbnail", MaxDimension: 40, Format: "original"},
+				{Name: "large", MaxDimension: 100, Format: "original"},
+				{Name: "large_webp", MaxDimension: 100, Format: "webp"},
+			},
+			PrimaryRendition: "large",
+			Quality:          75,
+		},
+	)
+	return processor, outputDir
+}
+
+func newTestLocalStore(t *testing.T) *storage.LocalStore {
+	t.Helper()
+
+	store, err := storage.NewLocalStore(t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("failed to create local store: %v", err)
+	}
+	return store
+}
+
+func TestProcessImageDownscalesHTTPImage(t *testing.T) {
+	data := encodeTestPNG(t, 400, 200)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	processor, outputDir := newTestImageProcessor(t, "")
+
+	variants, err := processor.ProcessImage(context.Background(), 5, server.URL+"/shoe.png")
+	if err != nil {
+		t.Fatalf("ProcessImage returned error: %v", err)
+	}
+	if len(variants) != 3 {
+		t.Fatalf("expected 3 renditions, got %d", len(variants))
+	}
+
+	expected := map[string]struct {
+		format        string
+		width, height int
+	}{
+		"thumbnail":  {"png", 40, 20},
+		"large":      {"png", 100, 50},
+		"large_webp": {"webp", 100, 50},
+	}
+
+	for _, v := range variants {
+		want, ok := expected[v.Name]
+		if !ok {
+			t.Fatalf("unexpected rendition %q", v.Name)
+		}
+
+		u, err := url.Parse(v.URL)
+		if err != nil || u.Scheme != "file" {
+			t.Fatalf("unexpected output location %q", v.URL)
+		}
+		if !strings.HasPrefix(u.Path, filepath.ToSlash(filepath.Join(outputDir, "products", "5"))) || path.Ext(u.Path) != "."+want.format {
+			t.Fatalf("unexpected output location %q", v.URL)
+		}
+
+		f, err := os.Open(filepath.FromSlash(u.Path))
+		if err != nil {
+			t.Fatalf("failed to open output: %v", err)
+		}
+		img, format, err := utils.DecodeImage(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("failed to decode %s: %v", v.Name, err)
+		}
+
+		if format != want.format || img.Bounds().Dx() != want.width || img.Bounds().Dy() != want.height {
+			t.Errorf("%s: expected %dx%d %s, got %dx%d %s", v.Name,
+				want.width, want.height, want.format, img.Bounds().Dx(), img.Bounds().Dy(), format)
+		}
+		if v.Width != want.width || v.Height != want.height || v.Format != want.format || v.SizeBytes == 0 {
+			t.Errorf("%s: unexpected variant metadata %+v", v.Name, v)
+		}
+	}
+}
+
+func TestProcessImageReportsHTTPFailure(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	processor, _ := newTestImageProcessor(t, "")
+
+	if _, err := processor.ProcessImage(context.Background(), 5, server.URL+"/missing.png"); err == nil {
+		t.Fatal("expected an error for a missing image")
+	}
+}
+
+func TestProcessImageReadsLocalFilesInsideRoot(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "hat.png"), encodeTestPNG(t, 50, 80), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	processor, _ := newTestImageProcessor(t, sourceDir)
+
+	if _, err := processor.ProcessImage(context.Background(), 1, "hat.png"); err != nil {
+		t.Fatalf("ProcessImage returned error: %v", err)
+	}
+
+	// Traversal is clamped to the source root, where this file does not exist
+	if _, err := processor.ProcessImage(context.Background(), 1, "../../etc/passwd"); err == nil {
+		t.Fatal("expected path traversal to be rejected")
+	}
+}
+
+func TestProcessImageRejectsUnsupportedFormat(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "notes.txt"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	processor, _ := newTestImageProcessor(t, sourceDir)
+
+	_, err := processor.ProcessImage(context.Background(), 1, "notes.txt")
+	if !errors.Is(err, utils.ErrUnsupportedFormat) {
+		t.Fatalf("expected unsupported format error, got %v", err)
+	}
+}
+
+func TestProcessImageRejectsOversizedSource(t *testing.T) {
+	sourceDir := t.TempDir()
+	data := encodeTestPNG(t, 50, 80)
+	if err := os.WriteFile(filepath.Join(sourceDir, "hat.png"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for limit, tooLarge := range map[int64]bool{
+		int64(len(data)):     false,
+		int64(len(data)) - 1: true,
+	} {
+		processor := service.NewImageProcessor(
+			service.NewImageSource(http.DefaultClient, sourceDir),
+			newTestLocalStore(t),
+			service.ImageProcessorConfig{
+				Renditions:     []service.RenditionSpec{{Name: "large", MaxDimension: 100, Format: "original"}},
+				Quality:        75,
+				MaxSourceBytes: limit,
+			},
+		)
+
+		_, err := processor.ProcessImage(context.Background(), 1, "hat.png")
+		if tooLarge && !errors.Is(err, service.ErrImageTooLarge) {
+			t.Errorf("limit %d: expected ErrImageTooLarge, got %v", limit, err)
+		}
+		if !tooLarge && err != nil {
+			t.Errorf("limit %d: ProcessImage returned error: %v", limit, err)
+		}
+	}
+}
+
+func TestPublicHTTPClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the internal server must not be reached")
+	}))
+	defer server.Close()
+
+	processor := service.NewImageProcessor(
+		service.NewImageSource(service.NewPublicHTTPClient(time.Second), ""),
+		newTestLocalStore(t),
+		service.ImageProcessorConfig{
+			Renditions: []service.RenditionSpec{{Name: "large", MaxDimension: 100, Format: "original"}},
+			Quality:    75,
+		},
+	)
+
+	for _, location := range []string{
+		server.URL + "/shoe.png",
+		"http://localhost:" + server.URL[strings.LastIndex(server.URL, ":")+1:] + "/shoe.png",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/shoe.png",
+		"http://[::1]/shoe.png",
+	} {
+		_, err := processor.ProcessImage(context.Background(), 1, location)
+		if !errors.Is(err, service.ErrForbiddenAddress) {
+			t.Errorf("%s: expected ErrForbiddenAddress, got %v", location, err)
+		}
+	}
+}
+
+func TestParseRenditions(t *testing.T) {
+	renditions, err := service.ParseRenditions("thumbnail:200, large:1600:jpeg,large_webp:1600:webp")
+	if err != nil {
+		t.Fatalf("ParseRenditions returned error: %v", err)
+	}
+
+	want := []service.RenditionSpec{
+		{Name: "thumbnail", MaxDimension: 200, Format: "original"},
+		{Name: "large", MaxDimension: 1600, Format: "jpeg"},
+		{Name: "large_webp", MaxDimension: 1600, Format: "webp"},
+	}
+	if len(renditions) != len(want) {
+		t.Fatalf("expected %d renditions, got %+v", len(want), renditions)
+	}
+	for i := range want {
+		if renditions[i] != want[i] {
+			t.Errorf("rendition %d = %+v, want %+v", i, renditions[i], want[i])
+		}
+	}
+
+	for _, invalid := range []string{"", "thumb", "thumb:0", "thumb:200:tiff", "a:1,a:2"} {
+		if _, err := service.ParseRenditions(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestPruneImagesKeepsOnlyCurrentImages(t *testing.T) {
+	sourceDir := t.TempDir()
+	for _, name := range []string{"hat.png", "scarf.png"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), encodeTestPNG(t, 50, 80), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	processor, outputDir := newTestImageProcessor(t, sourceDir)
+	ctx := context.Background()
+
+	var kept []string
+	for _, name := range []string{"hat.png", "scarf.png"} {
+		variants, err := processor.ProcessImage(ctx, 1, name)
+		if err != nil {
+			t.Fatalf("ProcessImage returned error: %v", err)
+		}
+		if name == "hat.png" {
+			for _, v := range variants {
+				u, _ := url.Parse(v.URL)
+				kept = append(kept, filepath.FromSlash(u.Path))
+			}
+		}
+	}
+	// Renditions of other products are not touched
+	if _, err := processor.ProcessImage(ctx, 2, "scarf.png"); err != nil {
+		t.Fatalf("ProcessImage returned error: %v", err)
+	}
+
+	if err := processor.PruneImages(ctx, 1, []string{"hat.png"}); err != nil {
+		t.Fatalf("PruneImages returned error: %v", err)
+	}
+
+	sort.Strings(kept)
+	remaining, _ := filepath.Glob(filepath.Join(outputDir, "products", "1", "*", "*"))
+	if strings.Join(remaining, ",") != strings.Join(kept, ",") {
+		t.Errorf("expected only %v to be kept, got %v", kept, remaining)
+	}
+	if others, _ := filepath.Glob(filepath.Join(outputDir, "products", "2", "*", "*")); len(others) != 3 {
+		t.Errorf("expected the renditions of product 2 to be kept, got %v", others)
+	}
+}
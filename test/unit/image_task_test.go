@@ -0,0 +1,153 @@
+package unit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"product-management/internal/models"
+	"product-management/internal/queue"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestImageWorker returns a product service that renders images from a
+// directory holding a.jpg, and the SHA-256 of that image.
+func newTestImageWorker(t *testing.T) (*service.ProductService, sqlmock.Sqlmock, *miniredis.Miniredis, string) {
+	t.Helper()
+
+	sourceDir := t.TempDir()
+	data := encodeTestPNG(t, 50, 80)
+	if err := os.WriteFile(filepath.Join(sourceDir, "a.jpg"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	processor, _ := newTestImageProcessor(t, sourceDir)
+
+	productService, mock, mr := newTestProductServiceWithProcessor(t, processor)
+	sum := sha256.Sum256(data)
+	return productService, mock, mr, hex.EncodeToString(sum[:])
+}
+
+// expectFindRenditions returns a rendition of a.jpg, the primary one
+// recorded as compressed_a.jpg, made from the source with sourceHash.
+func expectFindRenditions(mock sqlmock.Sqlmock, sourceHash string) {
+	mock.ExpectQuery(`SELECT (.+) FROM product_image_variants`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(imageVariantColumns).
+			AddRow(1, 0, "a.jpg", sourceHash, "large", "compressed_a.jpg", "png", 50, 80, 1000))
+}
+
+func expectIsProcessed(mock sqlmock.Sqlmock, messageID string, processed bool) {
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM processed_messages`).
+		WithArgs(messageID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(processed))
+}
+
+func TestProcessImageTaskAcksDuplicateWithoutWork(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	task := queue.NewImageProcessingTask(1, []string{"a.jpg"})
+
+	expectIsProcessed(mock, task.MessageID, true)
+
+	if err := productService.ProcessImageTask(context.Background(), task); err != nil {
+		t.Fatalf("ProcessImageTask returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessImageTaskSkipsStaleImages(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	task := queue.NewImageProcessingTask(1, []string{"old.jpg"})
+
+	expectIsProcessed(mock, task.MessageID, false)
+	expectFindProduct(mock, 1, []string{"new.jpg"})
+
+	if err := productService.ProcessImageTask(context.Background(), task); err != nil {
+		t.Fatalf("ProcessImageTask returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessImageTaskSkipsUnchangedImages(t *testing.T) {
+	productService, mock, _, sourceHash := newTestImageWorker(t)
+	task := queue.NewImageProcessingTask(1, []string{"a.jpg"})
+
+	expectIsProcessed(mock, task.MessageID, false)
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	expectFindRenditions(mock, sourceHash)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO processed_messages`).
+		WithArgs(task.MessageID, int64(1), task.ImageListHash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := productService.ProcessImageTask(context.Background(), task); err != nil {
+		t.Fatalf("ProcessImageTask returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessImageTaskRerendersChangedBytes(t *testing.T) {
+	productService, mock, mr, _ := newTestImageWorker(t)
+	task := queue.NewImageProcessingTask(1, []string{"a.jpg"})
+	mr.Set("product:1", "{}")
+
+	// The same location now serves different bytes
+	expectIsProcessed(mock, task.MessageID, false)
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	expectFindRenditions(mock, "previous-hash")
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO processed_messages`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE products\s+SET compressed_product_images`).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	mock.ExpectExec(`DELETE FROM product_image_variants`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for i := 0; i < 3; i++ {
+		mock.ExpectExec(`INSERT INTO product_image_variants`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectAudit(mock, models.AuditEntityImage, 1, models.AuditActionUpdate)
+	mock.ExpectCommit()
+
+	if err := productService.ProcessImageTask(context.Background(), task); err != nil {
+		t.Fatalf("ProcessImageTask returned error: %v", err)
+	}
+	if mr.Exists("product:1") {
+		t.Error("expected the cached product to be invalidated")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessImageTaskRollsBackWhenProcessedConcurrently(t *testing.T) {
+	productService, mock, _, sourceHash := newTestImageWorker(t)
+	task := queue.NewImageProcessingTask(1, []string{"a.jpg"})
+
+	expectIsProcessed(mock, task.MessageID, false)
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	expectFindRenditions(mock, sourceHash)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO processed_messages`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := productService.ProcessImageTask(context.Background(), task); err != nil {
+		t.Fatalf("ProcessImageTask returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,184 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/queue"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var inventoryLevelColumns = []string{
+	"id", "product_id", "variant_id", "warehouse_id", "on_hand", "reserved", "low_stock_threshold", "updated_at",
+}
+
+func newTestInventoryService(t *testing.T) (*service.InventoryService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	inventoryService := service.NewInventoryService(
+		repository.NewInventoryRepository(db, appLogger),
+		repository.NewProductRepository(db, appLogger),
+		repository.NewProductVariantRepository(db, appLogger),
+		repository.NewOutboxRepository(db, appLogger),
+		repository.NewTransactor(db),
+		appLogger,
+	)
+
+	return inventoryService, mock
+}
+
+func expectStockMovement(mock sqlmock.Sqlmock, kind string, onHandDelta, reservedDelta int) {
+	mock.ExpectQuery(`INSERT INTO stock_movements`).
+		WithArgs(int64(5), kind, onHandDelta, reservedDelta, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+}
+
+func TestAdjustStockPublishesLowStockOnceCrossed(t *testing.T) {
+	inventoryService, mock := newTestInventoryService(t)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO inventory_levels (.+) ON CONFLICT (.+) WHERE inventory_levels.on_hand \+ EXCLUDED.on_hand >= inventory_levels.reserved`).
+		WithArgs(int64(1), nil, int64(2), -4, nil).
+		WillReturnRows(sqlmock.NewRows(inventoryLevelColumns).AddRow(5, 1, nil, 2, 6, 2, 5, time.Now()))
+	expectStockMovement(mock, models.MovementAdjust, -4, 0)
+	mock.ExpectQuery(`INSERT INTO outbox`).
+		WithArgs("inventory", int64(5), queue.LowStockEventType, queue.InventoryExchange, queue.LowStockRoutingKey, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+	mock.ExpectCommit()
+
+	// 8 available before, 4 after, with a threshold of 5
+	level, err := inventoryService.AdjustStock(context.Background(), seller, 1, &models.StockAdjustRequest{
+		WarehouseID: 2,
+		Delta:       -4,
+		Reason:      "damaged in transit",
+	})
+	if err != nil {
+		t.Fatalf("AdjustStock returned error: %v", err)
+	}
+	if level.Available() != 4 {
+		t.Errorf("expected 4 available, got %+v", level)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdjustStockStayingLowPublishesNothing(t *testing.T) {
+	inventoryService, mock := newTestInventoryService(t)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO inventory_levels`).
+		WillReturnRows(sqlmock.NewRows(inventoryLevelColumns).AddRow(5, 1, nil, 2, 3, 0, 5, time.Now()))
+	expectStockMovement(mock, models.MovementAdjust, -1, 0)
+	mock.ExpectCommit()
+
+	_, err := inventoryService.AdjustStock(context.Background(), seller, 1, &models.StockAdjustRequest{
+		WarehouseID: 2,
+		Delta:       -1,
+		Reason:      "stocktake",
+	})
+	if err != nil {
+		t.Fatalf("AdjustStock returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReserveStockCannotOversell(t *testing.T) {
+	inventoryService, mock := newTestInventoryService(t)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE inventory_levels\s+SET reserved = reserved \+ \$1(.+)AND on_hand - reserved >= \$1`).
+		WithArgs(3, int64(1), nil, int64(2)).
+		WillReturnRows(sqlmock.NewRows(inventoryLevelColumns))
+	mock.ExpectRollback()
+
+	_, err := inventoryService.ReserveStock(context.Background(), seller, 1, &models.StockReserveRequest{
+		WarehouseID: 2,
+		Quantity:    3,
+		Reference:   "order-77",
+	})
+	if !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("expected ErrInsufficientStock, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCommitReservationTakesUnitsOffTheShelf(t *testing.T) {
+	inventoryService, mock := newTestInventoryService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM stock_reservations r (.+) FOR UPDATE OF r`).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "inventory_id", "product_id", "quantity", "status", "reference", "created_at", "updated_at",
+		}).AddRow(9, 5, 1, 3, models.ReservationPending, "order-77", time.Now(), time.Now()))
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectQuery(`UPDATE inventory_levels\s+SET on_hand = on_hand \+ \$1, reserved = reserved \+ \$2`).
+		WithArgs(-3, -3, int64(5)).
+		WillReturnRows(sqlmock.NewRows(inventoryLevelColumns).AddRow(5, 1, nil, 2, 7, 0, nil, time.Now()))
+	mock.ExpectQuery(`UPDATE stock_reservations`).
+		WithArgs(models.ReservationCommitted, int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(time.Now()))
+	expectStockMovement(mock, models.MovementCommit, -3, -3)
+	mock.ExpectCommit()
+
+	reservation, err := inventoryService.CommitReservation(context.Background(), seller, 9)
+	if err != nil {
+		t.Fatalf("CommitReservation returned error: %v", err)
+	}
+	if reservation.Status != models.ReservationCommitted || reservation.Level.OnHand != 7 {
+		t.Errorf("unexpected reservation %+v", reservation)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReleasedReservationCannotBeCommitted(t *testing.T) {
+	inventoryService, mock := newTestInventoryService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM stock_reservations`).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "inventory_id", "product_id", "quantity", "status", "reference", "created_at", "updated_at",
+		}).AddRow(9, 5, 1, 3, models.ReservationReleased, "", time.Now(), time.Now()))
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectRollback()
+
+	_, err := inventoryService.CommitReservation(context.Background(), seller, 9)
+	if !errors.Is(err, service.ErrReservationClosed) {
+		t.Fatalf("expected ErrReservationClosed, got %v", err)
+	}
+}
+
+func TestLowStockEventPayload(t *testing.T) {
+	variantID := int64(3)
+	event := queue.NewLowStockEvent(1, &variantID, 2, 4, 5)
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["message_id"] == "" || decoded["variant_id"] != 3.0 || decoded["available"] != 4.0 {
+		t.Errorf("unexpected payload %s", body)
+	}
+}
@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"product-management/internal/migrate"
+	"product-management/migrations"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	all, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	for i, m := range all {
+		if m.Version != int64(i+1) {
+			t.Errorf("expected migration %d to have version %d, got %d_%s", i, i+1, m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadRejectsIncompleteMigrations(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {
+			"001_init.up.sql": {Data: []byte("CREATE TABLE a ();")},
+		},
+		"bad name": {
+			"init.sql": {Data: []byte("CREATE TABLE a ();")},
+		},
+		"two names": {
+			"001_init.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+			"001_other.down.sql": {Data: []byte("DROP TABLE a;")},
+		},
+	} {
+		if _, err := migrate.Load(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMigratorUpAppliesPendingMigrationsUnderLock(t *testing.T) {
+	db, mock, appLogger := newMockDB(t)
+
+	all, err := migrate.Load(fstest.MapFS{
+		"001_init.up.sql":     {Data: []byte("CREATE TABLE a ();")},
+		"001_init.down.sql":   {Data: []byte("DROP TABLE a;")},
+		"002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+	})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).
+		WithArgs(int64(2), "second").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrate.NewMigrator(db, all, appLogger).Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up returned error: %v", err)
+	}
+	if applied != 1 {
+		t.Errorf("expected 1 migration to be applied, got %d", applied)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,215 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"product-management/internal/queue"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+type fakePublisher struct {
+	published []queue.Message
+	failOn    string
+	// onPublish is called after every successful publish
+	onPublish func()
+}
+
+func (p *fakePublisher) Publish(exchange, routingKey string, msg queue.Message) error {
+	if msg.ID == p.failOn {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, msg)
+	if p.onPublish != nil {
+		p.onPublish()
+	}
+	return nil
+}
+
+var outboxColumns = []string{
+	"id", "aggregate_type", "aggregate_id", "event_type", "exchange",
+	"routing_key", "payload", "attempts", "last_error", "created_at",
+}
+
+// retryAfter matches a retry time the given delay from now.
+type retryAfter time.Duration
+
+func (d retryAfter) Match(v driver.Value) bool {
+	retryAt, ok := v.(time.Time)
+	wait := time.Until(retryAt)
+	return ok && wait <= time.Duration(d) && wait > time.Duration(d)-time.Second
+}
+
+func newTestOutboxRelay(t *testing.T, publisher service.MessagePublisher) (*service.OutboxRelay, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	relay, err := service.NewOutboxRelay(
+		repository.NewTransactor(db),
+		repository.NewOutboxRepository(db, appLogger),
+		publisher,
+		appLogger,
+		service.OutboxRelayConfig{BatchSize: 10, PollInterval: time.Second, RetryBaseDelay: time.Second, RetryMaxDelay: time.Minute},
+	)
+	if err != nil {
+		t.Fatalf("NewOutboxRelay returned error: %v", err)
+	}
+
+	return relay, mock
+}
+
+func TestNewOutboxRelayRejectsInvalidConfig(t *testing.T) {
+	for name, config := range map[string]service.OutboxRelayConfig{
+		"zero batch size":     {BatchSize: 0, PollInterval: time.Second},
+		"negative batch size": {BatchSize: -1, PollInterval: time.Second},
+		"zero interval":       {BatchSize: 10, PollInterval: 0},
+		"negative interval":   {BatchSize: 10, PollInterval: -time.Second},
+	} {
+		if _, err := service.NewOutboxRelay(nil, nil, &fakePublisher{}, nil, config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// expectClaim returns the given due messages, none of which failed before,
+// from a claim transaction.
+func expectClaim(mock sqlmock.Sqlmock, ids ...int64) {
+	rows := sqlmock.NewRows(outboxColumns)
+	for _, id := range ids {
+		rows.AddRow(id, "product", id, queue.ImageTaskType, queue.ImageExchange,
+			queue.ImageRoutingKey, []byte(`{"product_id":1}`), 0, "", time.Now())
+	}
+	expectClaimRows(mock, rows)
+}
+
+func expectClaimRows(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`UPDATE outbox\s+SET next_attempt_at = \$2\s+WHERE id IN \(\s+SELECT (.+) ORDER BY o.id\s+LIMIT \$1`).
+		WithArgs(10, retryAfter(10*time.Minute)).
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+}
+
+func expectMarkPublished(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectExec(`UPDATE outbox\s+SET published_at`).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestOutboxRelayPublishesAfterClaimCommits(t *testing.T) {
+	publisher := &fakePublisher{}
+	relay, mock := newTestOutboxRelay(t, publisher)
+
+	expectClaim(mock, 2, 1)
+	expectMarkPublished(mock, 1)
+	expectMarkPublished(mock, 2)
+
+	published, err := relay.RelayBatch(context.Background())
+	if err != nil {
+		t.Fatalf("RelayBatch returned error: %v", err)
+	}
+	if published != 2 || len(publisher.published) != 2 {
+		t.Fatalf("expected 2 published messages, got %d", published)
+	}
+	if publisher.published[0].ID != "outbox-1" || publisher.published[0].Type != queue.ImageTaskType {
+		t.Errorf("expected messages in insertion order, got %+v", publisher.published[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutboxRelayRecordsFailureAndReleasesTheRest(t *testing.T) {
+	publisher := &fakePublisher{failOn: "outbox-2"}
+	relay, mock := newTestOutboxRelay(t, publisher)
+
+	expectClaim(mock, 1, 2, 3)
+	expectMarkPublished(mock, 1)
+	mock.ExpectExec(`UPDATE outbox\s+SET attempts`).
+		WithArgs("broker unavailable", retryAfter(time.Second), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE outbox\s+SET next_attempt_at = CURRENT_TIMESTAMP`).
+		WithArgs(pq.Int64Array{3}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	published, err := relay.RelayBatch(context.Background())
+	if err == nil {
+		t.Fatal("expected a publish error")
+	}
+	if published != 1 {
+		t.Errorf("expected 1 published message, got %d", published)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutboxRelayBacksOffRepeatedFailures(t *testing.T) {
+	publisher := &fakePublisher{failOn: "outbox-1"}
+	relay, mock := newTestOutboxRelay(t, publisher)
+
+	expectClaimRows(mock, sqlmock.NewRows(outboxColumns).
+		AddRow(1, "product", 1, queue.ImageTaskType, queue.ImageExchange,
+			queue.ImageRoutingKey, []byte(`{"product_id":1}`), 3, "broker unavailable", time.Now()))
+	// The fourth attempt failed, so the fifth waits 8s
+	mock.ExpectExec(`UPDATE outbox\s+SET attempts = attempts \+ 1, last_error = \$1, next_attempt_at = \$2`).
+		WithArgs("broker unavailable", retryAfter(8*time.Second), int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err := relay.RelayBatch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "attempt 4") {
+		t.Fatalf("expected a publish error naming the attempt, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutboxClaimHoldsBackMessagesBehindWaitingOnesOfTheSameAggregate(t *testing.T) {
+	relay, mock := newTestOutboxRelay(t, &fakePublisher{})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`NOT EXISTS \(\s+SELECT 1 FROM outbox earlier\s+`+
+		`WHERE earlier.aggregate_type = o.aggregate_type\s+AND earlier.aggregate_id = o.aggregate_id\s+`+
+		`AND earlier.id < o.id\s+AND earlier.published_at IS NULL\s+AND earlier.next_attempt_at > CURRENT_TIMESTAMP`).
+		WithArgs(10, retryAfter(10*time.Minute)).
+		WillReturnRows(sqlmock.NewRows(outboxColumns))
+	mock.ExpectCommit()
+
+	if _, err := relay.RelayBatch(context.Background()); err != nil {
+		t.Fatalf("RelayBatch returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOutboxRelayReleasesClaimsWhenStopped(t *testing.T) {
+	publisher := &fakePublisher{}
+	relay, mock := newTestOutboxRelay(t, publisher)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	publisher.onPublish = cancel
+
+	expectClaim(mock, 1, 2)
+	expectMarkPublished(mock, 1)
+	mock.ExpectExec(`UPDATE outbox\s+SET next_attempt_at = CURRENT_TIMESTAMP`).
+		WithArgs(pq.Int64Array{2}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	published, err := relay.RelayBatch(ctx)
+	if !errors.Is(err, context.Canceled) || published != 1 {
+		t.Fatalf("expected to stop after one message, got %d, %v", published, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,101 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"product-management/internal/currency"
+	"product-management/internal/models"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// expectRecordPriceChange expects a change of a USD product's price to be
+// recorded. oldPrice is nil for changes without an earlier price.
+func expectRecordPriceChange(mock sqlmock.Sqlmock, productID int64, oldPrice interface{}, newPrice, reason string) {
+	mock.ExpectQuery(`INSERT INTO product_price_history`).
+		WithArgs(productID, "USD", oldPrice, newPrice, sqlmock.AnyArg(), reason, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+}
+
+func TestUpdatePriceDuringSaleIsNotRecorded(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	now := time.Now()
+	sale := models.PriceSchedule{
+		ID: 3, ProductID: 1, SalePrice: 7_99,
+		StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour),
+	}
+
+	// The product keeps selling at 7.99 until the sale ends, when the new
+	// price is recorded
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE products`).
+		WithArgs("Old name", "Old description", "12.00", "USD", pq.StringArray{"a.jpg"}, pq.StringArray{"compressed_a.jpg"}, pq.StringArray{"sale"}, int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "created_at", "updated_at"}).
+			AddRow(7, now, now))
+	expectAudit(mock, models.AuditEntityProduct, 1, models.AuditActionUpdate)
+	expectFindSchedules(mock, []int64{1}, sale)
+	mock.ExpectCommit()
+	expectFindSchedules(mock, []int64{1}, sale)
+
+	product, err := productService.PatchProduct(context.Background(), seller, 1, []byte(`{"product_price": 12}`))
+	if err != nil {
+		t.Fatalf("PatchProduct returned error: %v", err)
+	}
+	if product.ProductPrice != 12_00 || product.EffectivePrice != 7_99 {
+		t.Errorf("unexpected prices %+v", product)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPriceHistoryDefaultsToLastThirtyDays(t *testing.T) {
+	priceService, mock := newTestPriceService(t, &currency.Table{})
+	to := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	from := to.Add(-30 * 24 * time.Hour)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectQuery(`SELECT (.+) FROM product_price_history\s+WHERE product_id = \$1 AND changed_at >= \$2 AND changed_at < \$3`).
+		WithArgs(int64(1), from, to).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "product_id", "currency", "old_price", "new_price", "changed_by", "reason", "schedule_id", "changed_at",
+		}).AddRow(4, 1, "USD", "10.50", "7.99", 7, models.PriceChangeSaleStarted, 3, to.Add(-24*time.Hour)))
+	mock.ExpectQuery(`WITH spans AS (.+) FROM product_price_history`).
+		WithArgs(int64(1), "USD", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"min", "max", "average"}).AddRow("7.99", "10.50", "10.42"))
+
+	history, err := priceService.PriceHistory(context.Background(), seller, 1, &models.PriceHistoryParams{To: &to})
+	if err != nil {
+		t.Fatalf("PriceHistory returned error: %v", err)
+	}
+
+	if len(history.Changes) != 1 || *history.Changes[0].OldPrice != 10_50 || *history.Changes[0].ScheduleID != 3 {
+		t.Errorf("unexpected changes %+v", history.Changes)
+	}
+	stats := history.Stats
+	if !stats.From.Equal(from) || *stats.Min != 7_99 || *stats.Max != 10_50 || *stats.Average != 10_42 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPriceHistoryRejectsInvertedPeriod(t *testing.T) {
+	priceService, mock := newTestPriceService(t, &currency.Table{})
+	from := time.Now()
+	to := from.Add(-time.Hour)
+
+	expectFindProduct(mock, 1, nil)
+
+	_, err := priceService.PriceHistory(context.Background(), seller, 1, &models.PriceHistoryParams{From: &from, To: &to})
+	if !errors.Is(err, service.ErrInvalidPeriod) {
+		t.Fatalf("expected ErrInvalidPeriod, got %v", err)
+	}
+}
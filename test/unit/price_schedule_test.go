@@ -0,0 +1,188 @@
+package unit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/queue"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+var priceScheduleColumns = []string{
+	"id", "product_id", "sale_price", "starts_at", "ends_at", "label", "created_by",
+	"start_published_at", "end_published_at", "created_at",
+}
+
+// expectFindSchedules returns the given schedules as the current ones of
+// the products.
+func expectFindSchedules(mock sqlmock.Sqlmock, productIDs []int64, schedules ...models.PriceSchedule) {
+	rows := sqlmock.NewRows(priceScheduleColumns)
+	for _, s := range schedules {
+		rows.AddRow(s.ID, s.ProductID, s.SalePrice.String(), s.StartsAt, s.EndsAt, s.Label, s.CreatedBy,
+			s.StartPublishedAt, s.EndPublishedAt, s.CreatedAt)
+	}
+	mock.ExpectQuery(`SELECT (.+) FROM price_schedules\s+WHERE product_id = ANY\(\$1\) AND ends_at > \$2`).
+		WithArgs(pq.Array(productIDs), sqlmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+func TestGetProductByIDAppliesRunningSale(t *testing.T) {
+	productService, mock, mr := newTestProductService(t)
+	now := time.Now()
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectQuery(`SELECT (.+) FROM product_image_variants`).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"product_id", "image_index", "source_image", "variant", "url",
+			"format", "width", "height", "size_bytes",
+		}))
+	expectFindCategories(mock, 1)
+	expectListVariants(mock, 1)
+	expectFindSchedules(mock, []int64{1}, models.PriceSchedule{
+		ID: 3, ProductID: 1, SalePrice: 7_99, Label: "Summer sale",
+		StartsAt: now.Add(-time.Hour), EndsAt: now.Add(10 * time.Minute),
+	})
+
+	product, err := productService.GetProductByID(context.Background(), seller, 1)
+	if err != nil {
+		t.Fatalf("GetProductByID returned error: %v", err)
+	}
+	if product.ProductPrice != 10_50 || product.EffectivePrice != 7_99 || product.Sale == nil || product.Sale.ID != 3 {
+		t.Errorf("expected the sale price to apply, got %+v", product)
+	}
+
+	// The cached product expires when the sale ends
+	if ttl := mr.TTL("product:1"); ttl <= 0 || ttl > 10*time.Minute {
+		t.Errorf("expected the cache to expire with the sale, got TTL %v", ttl)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreatePriceScheduleRejectsOverlap(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	now := time.Now()
+	price := models.Amount(8_00)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectQuery(`INSERT INTO price_schedules`).
+		WithArgs(int64(1), "8.00", sqlmock.AnyArg(), sqlmock.AnyArg(), "", seller.UserID).
+		WillReturnError(&pq.Error{Code: "23P01"})
+
+	_, err := productService.CreatePriceSchedule(context.Background(), seller, 1, &models.PriceScheduleRequest{
+		SalePrice: &price,
+		StartsAt:  now,
+		EndsAt:    now.Add(24 * time.Hour),
+		Label:     "  ",
+	})
+	if !errors.Is(err, repository.ErrScheduleOverlap) {
+		t.Fatalf("expected ErrScheduleOverlap, got %v", err)
+	}
+}
+
+// priceChange matches the payload of a price changed event.
+type priceChange struct {
+	reason        string
+	old, newPrice models.Amount
+}
+
+func (m priceChange) Match(v driver.Value) bool {
+	payload, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+	var event queue.PriceChangedEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return false
+	}
+	return event.Reason == m.reason && event.OldPrice == m.old && event.NewPrice == m.newPrice
+}
+
+func TestNewPriceSchedulerRejectsInvalidConfig(t *testing.T) {
+	for name, config := range map[string]service.PriceSchedulerConfig{
+		"zero batch size": {BatchSize: 0, PollInterval: time.Second},
+		"zero interval":   {BatchSize: 10, PollInterval: 0},
+	} {
+		if _, err := service.NewPriceScheduler(nil, nil, nil, nil, nil, config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPriceSchedulerAnnouncesStartsAndEnds(t *testing.T) {
+	db, mock, appLogger := newMockDB(t)
+	scheduler, err := service.NewPriceScheduler(
+		repository.NewTransactor(db),
+		repository.NewPriceRepository(db, appLogger),
+		repository.NewProductRepository(db, appLogger),
+		repository.NewOutboxRepository(db, appLogger),
+		appLogger,
+		service.PriceSchedulerConfig{BatchSize: 10, PollInterval: time.Second},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	startedAt := now.Add(-2 * time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM price_schedules(.+)FOR UPDATE SKIP LOCKED`).
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows(priceScheduleColumns).
+			// Started a minute ago
+			AddRow(1, 1, "8.00", now.Add(-time.Minute), now.Add(time.Hour), "", nil, nil, nil, now).
+			// Ended a minute ago
+			AddRow(2, 1, "9.00", now.Add(-3*time.Hour), now.Add(-time.Minute), "", nil, startedAt, nil, now).
+			// Came and went unannounced
+			AddRow(3, 1, "7.00", now.Add(-3*time.Hour), now.Add(-2*time.Hour), "", nil, nil, nil, now))
+
+	expectFindProduct(mock, 1, nil)
+	expectRecordPriceChange(mock, 1, "10.50", "8.00", models.PriceChangeSaleStarted)
+	mock.ExpectQuery(`INSERT INTO outbox`).
+		WithArgs("product", int64(1), queue.PriceChangedEventType, queue.PricingExchange, queue.PriceChangedRoutingKey,
+			priceChange{models.PriceChangeSaleStarted, 10_50, 8_00}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, now))
+	mock.ExpectExec(`UPDATE price_schedules`).
+		WithArgs(now, nil, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	expectFindProduct(mock, 1, nil)
+	expectRecordPriceChange(mock, 1, "9.00", "10.50", models.PriceChangeSaleEnded)
+	mock.ExpectQuery(`INSERT INTO outbox`).
+		WithArgs("product", int64(1), queue.PriceChangedEventType, queue.PricingExchange, queue.PriceChangedRoutingKey,
+			priceChange{models.PriceChangeSaleEnded, 9_00, 10_50}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, now))
+	mock.ExpectExec(`UPDATE price_schedules`).
+		WithArgs(startedAt, now, int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Recorded, but not announced
+	expectFindProduct(mock, 1, nil)
+	expectRecordPriceChange(mock, 1, "10.50", "7.00", models.PriceChangeSaleStarted)
+	expectRecordPriceChange(mock, 1, "7.00", "10.50", models.PriceChangeSaleEnded)
+	mock.ExpectExec(`UPDATE price_schedules`).
+		WithArgs(now, now, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	announced, err := scheduler.AnnounceDue(context.Background(), now)
+	if err != nil {
+		t.Fatalf("AnnounceDue returned error: %v", err)
+	}
+	if announced != 3 {
+		t.Errorf("expected 3 schedules handled, got %d", announced)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,211 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"product-management/internal/currency"
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestAmountIsExactInJSON(t *testing.T) {
+	var req models.ProductCreateRequest
+	if err := json.Unmarshal([]byte(`{"product_price": 0.29}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.ProductPrice != 29 {
+		t.Fatalf("expected 29 hundredths, got %d", req.ProductPrice)
+	}
+
+	// Strings are accepted too, and output always has two digits
+	var price models.CurrencyPriceRequest
+	if err := json.Unmarshal([]byte(`{"amount": "1234567.8"}`), &price); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(models.Product{ProductPrice: *price.Amount})
+	var out map[string]json.RawMessage
+	json.Unmarshal(data, &out)
+	if string(out["product_price"]) != "1234567.80" {
+		t.Errorf("expected 1234567.80, got %s", out["product_price"])
+	}
+
+	for _, invalid := range []string{`1.999`, `1e3`, `"abc"`, `"-"`, `".5"`} {
+		if err := json.Unmarshal([]byte(invalid), new(models.Amount)); err == nil {
+			t.Errorf("expected %s to be rejected", invalid)
+		}
+	}
+}
+
+func TestAmountScansDecimalText(t *testing.T) {
+	var amount models.Amount
+	if err := amount.Scan([]byte("-0.05")); err != nil || amount != -5 {
+		t.Fatalf("expected -5, got %d (%v)", amount, err)
+	}
+	if value, _ := amount.Value(); value != "-0.05" {
+		t.Errorf("expected -0.05, got %v", value)
+	}
+}
+
+func TestDisplayPriceUsesCurrencyExponent(t *testing.T) {
+	for _, tc := range []struct {
+		price models.DisplayPrice
+		want  string
+	}{
+		{models.DisplayPrice{Amount: 1500, Currency: "JPY", Source: models.PriceSourceBase}, `{"amount":1500,"currency":"JPY","source":"base"}`},
+		{models.DisplayPrice{Amount: 3417, Currency: "KWD", Source: models.PriceSourceBase}, `{"amount":3.417,"currency":"KWD","source":"base"}`},
+		{models.DisplayPrice{Amount: -5, Currency: "EUR", Source: models.PriceSourceBase}, `{"amount":-0.05,"currency":"EUR","source":"base"}`},
+	} {
+		data, err := json.Marshal(tc.price)
+		if err != nil || string(data) != tc.want {
+			t.Errorf("expected %s, got %s (%v)", tc.want, data, err)
+		}
+	}
+
+	// Amounts kept in hundredths round to the minor unit
+	for exponent, want := range map[int]int64{0: 20, 2: 19_50, 3: 19_500} {
+		if units := models.Amount(19_50).InMinorUnits(exponent); units != want {
+			t.Errorf("exponent %d: expected %d, got %d", exponent, want, units)
+		}
+	}
+}
+
+func writeRates(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileProviderConvertsThroughBase(t *testing.T) {
+	rates, err := currency.NewFileProvider(writeRates(t, `{"base": "USD", "rates": {"EUR": "0.9", "JPY": 150, "KWD": "0.3075"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 10.00 EUR = 11.11 USD = 1666.67 JPY = 3.41667 KWD, rounded half away
+	// from zero to the minor unit of each currency
+	for code, want := range map[string]int64{"JPY": 1667, "KWD": 3417, "USD": 1111} {
+		rate, err := rates.Rate(context.Background(), "EUR", code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if converted := currency.Convert(10_00, rate, models.CurrencyExponent(code)); converted != want {
+			t.Errorf("%s: expected %d minor units, got %d", code, want, converted)
+		}
+	}
+
+	if _, err := rates.Rate(context.Background(), "USD", "GBP"); !errors.Is(err, currency.ErrRateUnavailable) {
+		t.Errorf("expected ErrRateUnavailable, got %v", err)
+	}
+}
+
+func newTestPriceService(t *testing.T, rates currency.RateProvider) (*service.PriceService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	priceService := service.NewPriceService(
+		repository.NewPriceRepository(db, appLogger),
+		repository.NewProductRepository(db, appLogger),
+		rates,
+		appLogger,
+	)
+
+	return priceService, mock
+}
+
+func TestLocalizePrefersPriceListOverConversion(t *testing.T) {
+	rates, err := currency.NewFileProvider(writeRates(t, `{"base": "USD", "rates": {"EUR": "0.92"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priceService, mock := newTestPriceService(t, rates)
+
+	mock.ExpectQuery(`SELECT product_id, amount FROM product_prices WHERE product_id = ANY\(\$1\) AND currency = \$2`).
+		WithArgs(pq.Array([]int64{1, 2}), "EUR").
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "amount"}).AddRow(1, "9.49"))
+
+	listed := &models.Product{ID: 1, ProductPrice: 10_00, EffectivePrice: 10_00, Currency: "USD"}
+	converted := &models.Product{ID: 2, ProductPrice: 19_99, EffectivePrice: 19_99, Currency: "USD"}
+	own := &models.Product{ID: 3, ProductPrice: 5_00, EffectivePrice: 5_00, Currency: "EUR"}
+	if err := priceService.Localize(context.Background(), " eur", listed, converted, own); err != nil {
+		t.Fatalf("Localize returned error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		product *models.Product
+		want    models.DisplayPrice
+	}{
+		{listed, models.DisplayPrice{Amount: 9_49, Currency: "EUR", Source: models.PriceSourceList}},
+		{converted, models.DisplayPrice{Amount: 18_39, Currency: "EUR", Source: models.PriceSourceExchangeRate}},
+		{own, models.DisplayPrice{Amount: 5_00, Currency: "EUR", Source: models.PriceSourceBase}},
+	} {
+		if tc.product.DisplayPrice == nil || *tc.product.DisplayPrice != tc.want {
+			t.Errorf("product %d: expected %+v, got %+v", tc.product.ID, tc.want, tc.product.DisplayPrice)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLocalizeShowsRunningSale(t *testing.T) {
+	rates, err := currency.NewFileProvider(writeRates(t, `{"base": "USD", "rates": {"EUR": "0.92"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priceService, mock := newTestPriceService(t, rates)
+
+	// The price list is not consulted for products on sale
+	sale := &models.PriceSchedule{ID: 3, ProductID: 1, SalePrice: 8_00}
+	abroad := &models.Product{ID: 1, ProductPrice: 10_00, EffectivePrice: 8_00, Currency: "USD", Sale: sale}
+	own := &models.Product{ID: 2, ProductPrice: 10_00, EffectivePrice: 8_00, Currency: "EUR", Sale: sale}
+	if err := priceService.Localize(context.Background(), "EUR", abroad, own); err != nil {
+		t.Fatalf("Localize returned error: %v", err)
+	}
+
+	if want := (models.DisplayPrice{Amount: 7_36, Currency: "EUR", Source: models.PriceSourceExchangeRate}); *abroad.DisplayPrice != want {
+		t.Errorf("expected %+v, got %+v", want, abroad.DisplayPrice)
+	}
+	if want := (models.DisplayPrice{Amount: 8_00, Currency: "EUR", Source: models.PriceSourceBase}); *own.DisplayPrice != want {
+		t.Errorf("expected %+v, got %+v", want, own.DisplayPrice)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLocalizeWithoutRatesFails(t *testing.T) {
+	priceService, mock := newTestPriceService(t, &currency.Table{})
+
+	mock.ExpectQuery(`SELECT product_id, amount FROM product_prices`).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "amount"}))
+
+	err := priceService.Localize(context.Background(), "GBP", &models.Product{ID: 1, ProductPrice: 10_00, EffectivePrice: 10_00, Currency: "USD"})
+	if !errors.Is(err, currency.ErrRateUnavailable) {
+		t.Fatalf("expected ErrRateUnavailable, got %v", err)
+	}
+}
+
+func TestSetPriceRejectsOwnCurrency(t *testing.T) {
+	priceService, mock := newTestPriceService(t, &currency.Table{})
+
+	expectFindProduct(mock, 1, nil)
+
+	amount := models.Amount(9_00)
+	_, err := priceService.SetPrice(context.Background(), seller, 1, "usd", &models.CurrencyPriceRequest{Amount: &amount})
+	if !errors.Is(err, service.ErrOwnCurrency) {
+		t.Fatalf("expected ErrOwnCurrency, got %v", err)
+	}
+}
@@ -0,0 +1,750 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"product-management/internal/cache"
+	"product-management/internal/models"
+	"product-management/internal/queue"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+	"product-management/pkg/logger"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/lib/pq"
+)
+
+var productColumns = []string{
+	"id", "user_id", "product_name", "product_description",
+	"product_price", "currency", "product_images", "compressed_product_images",
+	"tags", "created_at", "updated_at",
+}
+
+var imageVariantColumns = []string{
+	"product_id", "image_index", "source_image", "source_hash", "variant", "url",
+	"format", "width", "height", "size_bytes",
+}
+
+var (
+	seller = &models.Identity{UserID: 7, Role: models.RoleSeller}
+	admin  = &models.Identity{UserID: 1, Role: models.RoleAdmin}
+)
+
+// newMockDB returns a mocked database, closed when the test ends, and a
+// logger for the repositories built on it.
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *logger.Logger) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db, mock, logger.NewLogger()
+}
+
+func newTestProductService(t *testing.T) (*service.ProductService, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	return newTestProductServiceWithProcessor(t, nil)
+}
+
+func newTestProductServiceWithProcessor(t *testing.T, imageProcessor *service.ImageProcessor) (*service.ProductService, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	mr := miniredis.RunT(t)
+
+	productService := service.NewProductService(
+		repository.NewProductRepository(db, appLogger),
+		repository.NewProductVariantRepository(db, appLogger),
+		repository.NewPriceRepository(db, appLogger),
+		repository.NewOutboxRepository(db, appLogger),
+		repository.NewProcessedMessageRepository(db, appLogger),
+		repository.NewAuditRepository(db, appLogger),
+		repository.NewTransactor(db),
+		appLogger,
+		cache.NewRedisCache("redis://"+mr.Addr()),
+		imageProcessor,
+		[]byte("cursor-secret"),
+	)
+
+	return productService, mock, mr
+}
+
+func expectFindProduct(mock sqlmock.Sqlmock, id int64, images []string) {
+	now := time.Now()
+	mock.ExpectQuery(`SELECT (.+) FROM products\s+WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(productColumns).AddRow(
+			id, 7, "Old name", "Old description", "10.50", "USD",
+			"{"+strings.Join(images, ",")+"}", "{compressed_a.jpg}", "{sale}", now, now,
+		))
+}
+
+func expectEnqueueImageTask(mock sqlmock.Sqlmock, productID int64) *sqlmock.ExpectedQuery {
+	return mock.ExpectQuery(`INSERT INTO outbox`).
+		WithArgs("product", productID, queue.ImageTaskType, queue.ImageExchange, queue.ImageRoutingKey, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, time.Now()))
+}
+
+func TestCreateProductEnqueuesImageTaskInSameTransaction(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO products`).
+		WithArgs(int64(7), "Name", "", "15.00", "USD", pq.StringArray{"a.jpg"}, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+			AddRow(3, time.Now(), time.Now()))
+	expectRecordPriceChange(mock, 3, nil, "15.00", models.PriceChangeCreated)
+	expectAudit(mock, models.AuditEntityProduct, 3, models.AuditActionCreate)
+	expectEnqueueImageTask(mock, 3)
+	mock.ExpectCommit()
+
+	product, err := productService.CreateProduct(context.Background(), seller, &models.ProductCreateRequest{
+		UserID:        7,
+		ProductName:   "Name",
+		ProductPrice:  15_00,
+		ProductImages: []string{"a.jpg"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProduct returned error: %v", err)
+	}
+	if product.ID != 3 {
+		t.Errorf("expected product ID 3, got %d", product.ID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateProductRollsBackWhenOutboxWriteFails(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO products`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+			AddRow(3, time.Now(), time.Now()))
+	expectRecordPriceChange(mock, 3, nil, "15.00", models.PriceChangeCreated)
+	expectAudit(mock, models.AuditEntityProduct, 3, models.AuditActionCreate)
+	expectEnqueueImageTask(mock, 3).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	_, err := productService.CreateProduct(context.Background(), seller, &models.ProductCreateRequest{
+		UserID:        7,
+		ProductName:   "Name",
+		ProductPrice:  15_00,
+		ProductImages: []string{"a.jpg"},
+	})
+	if err == nil {
+		t.Fatal("expected CreateProduct to fail")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateProductRejectsUnknownUser(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO products`).
+		WillReturnError(&pq.Error{Code: "23503"})
+	mock.ExpectRollback()
+
+	_, err := productService.CreateProduct(context.Background(), admin, &models.ProductCreateRequest{
+		UserID:        99,
+		ProductName:   "Name",
+		ProductPrice:  15_00,
+		ProductImages: []string{"a.jpg"},
+	})
+	if !errors.Is(err, service.ErrUnknownUser) {
+		t.Fatalf("expected ErrUnknownUser, got %v", err)
+	}
+}
+
+func TestPatchProductMergesFieldsAndInvalidatesCache(t *testing.T) {
+	productService, mock, mr := newTestProductService(t)
+	ctx := context.Background()
+
+	mr.Set("product:1", "{}")
+	mr.Set("products:7:0:0:foo:1:10", "{}")
+	mr.Set("products:8:0:0:foo:1:10", "{}")
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE products`).
+		WithArgs("New name", "", "10.50", "USD", pq.StringArray{"a.jpg"}, pq.StringArray{"compressed_a.jpg"}, pq.StringArray{"sale"}, int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "created_at", "updated_at"}).
+			AddRow(7, time.Now(), time.Now()))
+	expectAudit(mock, models.AuditEntityProduct, 1, models.AuditActionUpdate)
+	mock.ExpectCommit()
+	expectFindSchedules(mock, []int64{1})
+
+	product, err := productService.PatchProduct(ctx, seller, 1, []byte(`{"product_name":"New name","product_description":null}`))
+	if err != nil {
+		t.Fatalf("PatchProduct returned error: %v", err)
+	}
+
+	if product.ProductName != "New name" || product.ProductDescription != "" || product.ProductPrice != 10_50 {
+		t.Errorf("unexpected patched product: %+v", product)
+	}
+	if mr.Exists("product:1") || mr.Exists("products:7:0:0:foo:1:10") {
+		t.Error("expected product and owner list cache keys to be invalidated")
+	}
+	if !mr.Exists("products:8:0:0:foo:1:10") {
+		t.Error("expected other users' list cache keys to be kept")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPatchProductRejectsNonObjectPatch(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+
+	_, err := productService.PatchProduct(context.Background(), seller, 1, []byte(`["product_name"]`))
+	if !errors.Is(err, service.ErrInvalidPatch) {
+		t.Fatalf("expected ErrInvalidPatch, got %v", err)
+	}
+}
+
+func TestUpdateProductClearsCompressedImagesWhenImagesChange(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE products`).
+		WithArgs("Name", "Desc", "20.00", "USD", pq.StringArray{"b.jpg"}, nil, nil, int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "created_at", "updated_at"}).
+			AddRow(7, time.Now(), time.Now()))
+	expectAudit(mock, models.AuditEntityProduct, 1, models.AuditActionUpdate)
+	expectFindSchedules(mock, []int64{1})
+	expectRecordPriceChange(mock, 1, "10.50", "20.00", models.PriceChangeUpdated)
+	mock.ExpectQuery(`INSERT INTO outbox`).
+		WithArgs("product", int64(1), queue.PriceChangedEventType, queue.PricingExchange, queue.PriceChangedRoutingKey, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, time.Now()))
+	mock.ExpectExec(`DELETE FROM product_image_variants`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	expectAudit(mock, models.AuditEntityImage, 1, models.AuditActionUpdate)
+	expectEnqueueImageTask(mock, 1)
+	mock.ExpectCommit()
+	expectFindSchedules(mock, []int64{1})
+
+	product, err := productService.UpdateProduct(context.Background(), seller, 1, &models.ProductUpdateRequest{
+		ProductName:        "Name",
+		ProductDescription: "Desc",
+		ProductPrice:       20_00,
+		ProductImages:      []string{"b.jpg"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateProduct returned error: %v", err)
+	}
+	if len(product.CompressedProductImages) != 0 {
+		t.Errorf("expected compressed images to be cleared, got %v", product.CompressedProductImages)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteProductNotFound(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectQuery(`SELECT (.+) FROM products\s+WHERE id = \$1`).
+		WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows(productColumns))
+
+	err := productService.DeleteProduct(context.Background(), seller, 42)
+	if !errors.Is(err, repository.ErrProductNotFound) {
+		t.Fatalf("expected ErrProductNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeleteProductEnqueuesImageCleanupInSameTransaction(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM products`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	expectAudit(mock, models.AuditEntityProduct, 1, models.AuditActionDelete)
+	expectEnqueueImageTask(mock, 1)
+	mock.ExpectCommit()
+
+	if err := productService.DeleteProduct(context.Background(), seller, 1); err != nil {
+		t.Fatalf("DeleteProduct returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetProductByIDGroupsImageVariants(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, []string{"a.jpg", "b.jpg"})
+	mock.ExpectQuery(`SELECT (.+) FROM product_image_variants`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(imageVariantColumns).
+			AddRow(1, 0, "a.jpg", "", "thumbnail", "http://cdn/a_t.jpg", "jpeg", 200, 100, 1000).
+			AddRow(1, 0, "a.jpg", "", "large", "http://cdn/a_l.jpg", "jpeg", 1600, 800, 9000))
+	expectFindCategories(mock, 1)
+	expectListVariants(mock, 1)
+	expectFindSchedules(mock, []int64{1})
+
+	product, err := productService.GetProductByID(context.Background(), seller, 1)
+	if err != nil {
+		t.Fatalf("GetProductByID returned error: %v", err)
+	}
+
+	if len(product.Images) != 1 {
+		t.Fatalf("expected renditions for one image, got %+v", product.Images)
+	}
+	image := product.Images[0]
+	if image.Source != "a.jpg" || image.Variants["large"].URL != "http://cdn/a_l.jpg" || image.Variants["thumbnail"].Width != 200 {
+		t.Errorf("unexpected image %+v", image)
+	}
+}
+
+func TestProcessImageTaskRemovesRenditionsOfDeletedProduct(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "hat.png"), encodeTestPNG(t, 50, 80), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	processor, outputDir := newTestImageProcessor(t, sourceDir)
+	if _, err := processor.ProcessImage(context.Background(), 1, "hat.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	productService, mock, _ := newTestProductServiceWithProcessor(t, processor)
+	task := queue.NewImageProcessingTask(1, nil)
+
+	expectIsProcessed(mock, task.MessageID, false)
+	mock.ExpectQuery(`SELECT (.+) FROM products\s+WHERE id = \$1`).
+		WithArgs(int64(1)).
+		WillReturnError(sql.ErrNoRows)
+
+	if err := productService.ProcessImageTask(context.Background(), task); err != nil {
+		t.Fatalf("ProcessImageTask returned error: %v", err)
+	}
+
+	if remaining, _ := filepath.Glob(filepath.Join(outputDir, "products", "1", "*", "*")); len(remaining) != 0 {
+		t.Errorf("expected every rendition to be removed, got %v", remaining)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// expectFindCategories returns the breadcrumbs of a product filed under
+// Clothing > Shoes and under Sale.
+func expectFindCategories(mock sqlmock.Sqlmock, productID int64) {
+	mock.ExpectQuery(`SELECT (.+) FROM product_categories`).
+		WithArgs(productID).
+		WillReturnRows(sqlmock.NewRows([]string{"category_id", "id", "name", "slug"}).
+			AddRow(4, 1, "Clothing", "clothing").
+			AddRow(4, 4, "Shoes", "shoes").
+			AddRow(9, 9, "Sale", "sale"))
+}
+
+func TestGetProductByIDIncludesBreadcrumbs(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectQuery(`SELECT (.+) FROM product_image_variants`).
+		WillReturnRows(sqlmock.NewRows(imageVariantColumns))
+	expectFindCategories(mock, 1)
+	expectListVariants(mock, 1)
+	expectFindSchedules(mock, []int64{1})
+
+	product, err := productService.GetProductByID(context.Background(), seller, 1)
+	if err != nil {
+		t.Fatalf("GetProductByID returned error: %v", err)
+	}
+
+	if len(product.Categories) != 2 {
+		t.Fatalf("expected two categories, got %+v", product.Categories)
+	}
+	shoes, sale := product.Categories[0], product.Categories[1]
+	if shoes.Slug != "shoes" || len(shoes.Breadcrumbs) != 2 || shoes.Breadcrumbs[0].Name != "Clothing" {
+		t.Errorf("unexpected category %+v", shoes)
+	}
+	if sale.ID != 9 || len(sale.Breadcrumbs) != 1 {
+		t.Errorf("unexpected category %+v", sale)
+	}
+}
+
+func TestSellerCannotChangeAnotherSellersProduct(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	other := &models.Identity{UserID: 8, Role: models.RoleSeller}
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	_, err := productService.PatchProduct(context.Background(), other, 1, []byte(`{"product_name":"Mine now"}`))
+	if !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden from patch, got %v", err)
+	}
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	if err := productService.DeleteProduct(context.Background(), other, 1); !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden from delete, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdminCanDeleteAnyProduct(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM products`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	expectAudit(mock, models.AuditEntityProduct, 1, models.AuditActionDelete)
+	expectEnqueueImageTask(mock, 1)
+	mock.ExpectCommit()
+
+	if err := productService.DeleteProduct(context.Background(), admin, 1); err != nil {
+		t.Fatalf("DeleteProduct returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessImageTaskRejectsOversizedImagesPermanently(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "hat.png"), encodeTestPNG(t, 50, 80), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	processor := service.NewImageProcessor(
+		service.NewImageSource(http.DefaultClient, sourceDir),
+		newTestLocalStore(t),
+		service.ImageProcessorConfig{
+			Renditions:     []service.RenditionSpec{{Name: "large", MaxDimension: 100, Format: "original"}},
+			Quality:        75,
+			MaxSourceBytes: 10,
+		},
+	)
+
+	productService, mock, _ := newTestProductServiceWithProcessor(t, processor)
+	task := queue.NewImageProcessingTask(1, []string{"hat.png"})
+
+	expectIsProcessed(mock, task.MessageID, false)
+	expectFindProduct(mock, 1, []string{"hat.png"})
+	mock.ExpectQuery(`SELECT (.+) FROM product_image_variants`).
+		WithArgs(int64(1)).
+		WillReturnRows(sqlmock.NewRows(imageVariantColumns))
+
+	err := productService.ProcessImageTask(context.Background(), task)
+	if !errors.Is(err, service.ErrImageTooLarge) || !errors.Is(err, queue.ErrInvalidMessage) {
+		t.Fatalf("expected a permanent ErrImageTooLarge, got %v", err)
+	}
+}
+
+func TestProductPolicy(t *testing.T) {
+	viewer := &models.Identity{UserID: 9, Role: models.RoleViewer}
+	policy := service.ProductPolicy{}
+
+	cases := []struct {
+		name    string
+		actor   *models.Identity
+		action  service.Action
+		ownerID int64
+		allowed bool
+	}{
+		{"anonymous read", nil, service.ActionRead, 7, false},
+		{"viewer read", viewer, service.ActionRead, 7, true},
+		{"viewer create", viewer, service.ActionCreate, 9, false},
+		{"viewer list own", viewer, service.ActionList, 9, true},
+		{"seller create own", seller, service.ActionCreate, 7, true},
+		{"seller create for other", seller, service.ActionCreate, 8, false},
+		{"seller list other", seller, service.ActionList, 8, true},
+		{"admin update any", admin, service.ActionUpdate, 7, true},
+		{"admin list any", admin, service.ActionList, 7, true},
+	}
+
+	for _, tc := range cases {
+		err := policy.Authorize(tc.actor, tc.action, tc.ownerID)
+		if tc.allowed && err != nil {
+			t.Errorf("%s: expected allowed, got %v", tc.name, err)
+		}
+		if !tc.allowed && !errors.Is(err, service.ErrForbidden) {
+			t.Errorf("%s: expected ErrForbidden, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestReprocessProductImagesRequeuesTask(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, []string{"a.jpg"})
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE products`).
+		WithArgs("Old name", "Old description", "10.50", "USD", pq.StringArray{"a.jpg"}, nil, pq.StringArray{"sale"}, int64(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "created_at", "updated_at"}).
+			AddRow(7, time.Now(), time.Now()))
+	mock.ExpectExec(`DELETE FROM product_image_variants`).
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	expectAudit(mock, models.AuditEntityImage, 1, models.AuditActionUpdate)
+	expectEnqueueImageTask(mock, 1)
+	mock.ExpectCommit()
+
+	if _, err := productService.ReprocessProductImages(context.Background(), seller, 1); err != nil {
+		t.Fatalf("ReprocessProductImages returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSearchProductsRanksAndEscapesSnippets(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM products p, websearch_to_tsquery`).
+		WithArgs("red shoes").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT (.+) ts_headline(.+) ORDER BY p.rank DESC, p.id`).
+		WithArgs("red shoes", 10, 0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(append(productColumns, "rank", "snippet")).AddRow(
+			4, 8, "Red shoes", "<b>Bold</b> red shoes", "30.00", "USD", "{}", "{}", "{}", now, now, 0.6,
+			"Red shoes: <b>Bold</b> \x01red\x02 \x01shoes\x02",
+		))
+	expectFindSchedules(mock, []int64{4})
+
+	results, total, err := productService.SearchProducts(context.Background(), seller, &models.ProductSearchParams{Query: " red shoes "})
+	if err != nil {
+		t.Fatalf("SearchProducts returned error: %v", err)
+	}
+	if total != 1 || len(results) != 1 || results[0].ID != 4 || results[0].Rank != 0.6 {
+		t.Fatalf("unexpected results %+v (total %d)", results, total)
+	}
+	want := "Red shoes: &lt;b&gt;Bold&lt;/b&gt; <mark>red</mark> <mark>shoes</mark>"
+	if results[0].Snippet != want {
+		t.Errorf("expected snippet %q, got %q", want, results[0].Snippet)
+	}
+}
+
+func TestSearchProductsFuzzyMatchesSimilarNames(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\)(.+)OR p.product_name % \$1`).
+		WithArgs("sheos").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`similarity\(p.product_name, \$1\)`).
+		WillReturnRows(sqlmock.NewRows(append(productColumns, "rank", "snippet")))
+
+	_, _, err := productService.SearchProducts(context.Background(), seller, &models.ProductSearchParams{Query: "sheos", Fuzzy: true})
+	if err != nil {
+		t.Fatalf("SearchProducts returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListProductsFiltersAndSortsCatalog(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	compressed := true
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products WHERE product_price >= \$1 AND created_at >= \$2 AND cardinality\(compressed_product_images\) > 0 AND tags @> \$3$`).
+		WithArgs("5.00", since, pq.Array([]string{"sale", "shoes"})).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT (.+) FROM products WHERE (.+) ORDER BY product_price DESC, id DESC LIMIT \$4 OFFSET \$5`).
+		WithArgs("5.00", since, pq.Array([]string{"sale", "shoes"}), 21, 20).
+		WillReturnRows(sqlmock.NewRows(listColumns).AddRow(
+			2, 9, "Shoes", "", "50.00", "USD", "{a.jpg}", "{c.jpg}", "{sale,shoes}", since, since, "50.00",
+		))
+	expectFindSchedules(mock, []int64{2})
+
+	page, err := productService.ListProducts(context.Background(), seller, &models.ProductFilterParams{
+		MinPrice:            5_00,
+		CreatedAfter:        &since,
+		HasCompressedImages: &compressed,
+		Tags:                []string{" Sale", "shoes", "sale"},
+		Sort:                models.SortPrice,
+		Order:               models.OrderDesc,
+		Page:                2,
+		PageSize:            20,
+	})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if *page.TotalCount != 1 || len(page.Products) != 1 || page.Products[0].UserID != 9 {
+		t.Errorf("unexpected page %+v", page)
+	}
+	if page.NextCursor != "" || page.PrevCursor == "" {
+		t.Errorf("expected only a previous cursor on the last page, got %q and %q", page.NextCursor, page.PrevCursor)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListProductsRelevanceNeedsQuery(t *testing.T) {
+	productService, _, _ := newTestProductService(t)
+
+	_, err := productService.ListProducts(context.Background(), seller, &models.ProductFilterParams{Sort: models.SortRelevance})
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+}
+
+// listColumns are the columns of a listing: the product and its sort key.
+var listColumns = append(append([]string{}, productColumns...), "sort_key")
+
+func TestListProductsFollowsCursor(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products WHERE tags @> \$1$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT (.+) FROM products WHERE tags @> \$1 ORDER BY product_price ASC, id ASC LIMIT \$2 OFFSET \$3`).
+		WithArgs(pq.Array([]string{"sale"}), 3, 0).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow(1, 7, "A", "", "10.00", "USD", "{}", "{}", "{sale}", now, now, "10.00").
+			AddRow(2, 7, "B", "", "12.50", "USD", "{}", "{}", "{sale}", now, now, "12.50").
+			AddRow(3, 7, "C", "", "20.00", "USD", "{}", "{}", "{sale}", now, now, "20.00"))
+	expectFindSchedules(mock, []int64{1, 2})
+
+	params := models.ProductFilterParams{Tags: []string{"sale"}, Sort: models.SortPrice, PageSize: 2}
+	first := params
+	page, err := productService.ListProducts(context.Background(), seller, &first)
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if len(page.Products) != 2 || page.NextCursor == "" || page.PrevCursor != "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+
+	// The next page continues after the last row, without counting again
+	mock.ExpectQuery(`SELECT (.+) FROM products WHERE tags @> \$1 AND \(product_price, id\) > \(\$2::NUMERIC, \$3\) ORDER BY product_price ASC, id ASC LIMIT \$4 OFFSET \$5`).
+		WithArgs(pq.Array([]string{"sale"}), "12.50", int64(2), 3, 0).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow(3, 7, "C", "", "20.00", "USD", "{}", "{}", "{sale}", now, now, "20.00"))
+	expectFindSchedules(mock, []int64{3})
+
+	second := params
+	second.Cursor = page.NextCursor
+	second.Count = models.CountNone
+	page, err = productService.ListProducts(context.Background(), seller, &second)
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if len(page.Products) != 1 || page.Products[0].ID != 3 || page.TotalCount != nil {
+		t.Errorf("unexpected second page %+v", page)
+	}
+	if page.NextCursor != "" || page.PrevCursor == "" {
+		t.Errorf("expected only a previous cursor, got %q and %q", page.NextCursor, page.PrevCursor)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListProductsRejectsForeignCursors(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	now := time.Now()
+
+	mock.ExpectQuery(`SELECT COUNT`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT (.+) FROM products`).
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow(1, 7, "A", "", "10.00", "USD", "{}", "{}", "{}", now, now, "A").
+			AddRow(2, 7, "B", "", "12.50", "USD", "{}", "{}", "{}", now, now, "B"))
+	expectFindSchedules(mock, []int64{1})
+
+	page, err := productService.ListProducts(context.Background(), seller, &models.ProductFilterParams{
+		Sort:     models.SortName,
+		PageSize: 1,
+	})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+
+	payload, mac, _ := strings.Cut(page.NextCursor, ".")
+	for name, params := range map[string]models.ProductFilterParams{
+		"other filters": {Sort: models.SortName, MinPrice: 5_00, Cursor: page.NextCursor},
+		"other order":   {Sort: models.SortName, Order: models.OrderDesc, Cursor: page.NextCursor},
+		"tampered":      {Sort: models.SortName, Cursor: payload + "x." + mac},
+		"garbage":       {Sort: models.SortName, Cursor: "garbage"},
+	} {
+		if _, err := productService.ListProducts(context.Background(), seller, &params); !errors.Is(err, service.ErrInvalidCursor) {
+			t.Errorf("%s: expected ErrInvalidCursor, got %v", name, err)
+		}
+	}
+}
+
+func TestListProductsCountsFacets(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products WHERE tags @> \$1$`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`WITH matched AS \(\s+SELECT id, tags, product_price FROM products WHERE tags @> \$1\s+\)`).
+		WithArgs(pq.Array([]string{"sale"}), pq.Array([]models.Amount{20_00, 50_00})).
+		WillReturnRows(sqlmock.NewRows([]string{"tags", "categories", "prices"}).AddRow(
+			`[{"value":"sale","count":3},{"value":"shoes","count":2}]`,
+			`[{"id":1,"parent_id":null,"name":"Clothing","slug":"clothing","path":"/1/","count":3},`+
+				`{"id":4,"parent_id":1,"name":"Shoes","slug":"shoes","path":"/1/4/","count":2}]`,
+			`[{"bucket":0,"count":1},{"bucket":2,"count":2}]`,
+		))
+	mock.ExpectQuery(`SELECT (.+) FROM products WHERE tags @> \$1 ORDER BY`).
+		WillReturnRows(sqlmock.NewRows(listColumns))
+
+	page, err := productService.ListProducts(context.Background(), seller, &models.ProductFilterParams{
+		Tags:         []string{"sale"},
+		Facets:       true,
+		PriceBuckets: []models.Amount{50_00, 20_00, 50_00},
+	})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+
+	facets := page.Facets
+	if facets == nil || len(facets.Tags) != 2 || facets.Tags[1].Value != "shoes" {
+		t.Fatalf("unexpected facets %+v", facets)
+	}
+	if len(facets.Categories) != 2 || *facets.Categories[1].ParentID != 1 || facets.Categories[0].Count != 3 {
+		t.Errorf("unexpected category facets %+v", facets.Categories)
+	}
+
+	// Every range is reported, empty ones included
+	if len(facets.Prices) != 3 {
+		t.Fatalf("expected three price ranges, got %+v", facets.Prices)
+	}
+	below, between, above := facets.Prices[0], facets.Prices[1], facets.Prices[2]
+	if below.Min != nil || *below.Max != 20_00 || below.Count != 1 {
+		t.Errorf("unexpected lowest range %+v", below)
+	}
+	if *between.Min != 20_00 || *between.Max != 50_00 || between.Count != 0 {
+		t.Errorf("unexpected middle range %+v", between)
+	}
+	if *above.Min != 50_00 || above.Max != nil || above.Count != 2 {
+		t.Errorf("unexpected highest range %+v", above)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
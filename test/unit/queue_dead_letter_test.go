@@ -0,0 +1,123 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"product-management/internal/queue"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// newTestDeadLetters returns a broker holding two dead letters and an
+// admin for it.
+func newTestDeadLetters(t *testing.T, publisher *fakeAMQPPublisher) (*fakeBroker, *queue.DeadLetterAdmin) {
+	t.Helper()
+
+	broker := &fakeBroker{}
+	for _, id := range []string{"msg-1", "msg-2"} {
+		d := imageTaskDelivery(t, amqp091.Table{
+			queue.HeaderAttempts:      int32(5),
+			queue.HeaderFailureReason: "storage unavailable",
+			queue.HeaderFailedAt:      "2024-03-01T12:00:00Z",
+		})
+		d.MessageId = id
+		broker.push(d)
+	}
+	broker.push(amqp091.Delivery{MessageId: "msg-3", Body: []byte("not json")})
+
+	return broker, queue.NewDeadLetterAdminWithChannel(broker, publisher)
+}
+
+func TestDeadLetterInspectLeavesMessagesInOrder(t *testing.T) {
+	broker, admin := newTestDeadLetters(t, &fakeAMQPPublisher{})
+
+	letters, err := admin.Inspect(10)
+	if err != nil {
+		t.Fatalf("Inspect returned error: %v", err)
+	}
+
+	if len(letters) != 3 {
+		t.Fatalf("expected three dead letters, got %+v", letters)
+	}
+	first := letters[0]
+	if first.MessageID != "msg-1" || first.Attempts != 5 || first.Reason != "storage unavailable" || first.FailedAt != "2024-03-01T12:00:00Z" {
+		t.Errorf("unexpected dead letter %+v", first)
+	}
+	if string(letters[2].Body) != `"not json"` {
+		t.Errorf("expected the undecodable body to be quoted, got %s", letters[2].Body)
+	}
+
+	if len(broker.ready) != 3 || broker.ready[0].MessageId != "msg-1" || broker.ready[2].MessageId != "msg-3" {
+		t.Errorf("expected every dead letter back on the queue in order, got %v", broker.ready)
+	}
+}
+
+func TestDeadLetterReplayResetsAttempts(t *testing.T) {
+	publisher := &fakeAMQPPublisher{}
+	broker, admin := newTestDeadLetters(t, publisher)
+
+	replayed, err := admin.Replay(2)
+	if err != nil {
+		t.Fatalf("Replay returned error: %v", err)
+	}
+
+	if replayed != 2 || len(publisher.published) != 2 || len(broker.acked) != 2 {
+		t.Fatalf("expected two dead letters moved, got %d, published %v", replayed, publisher.published)
+	}
+	for _, p := range publisher.published {
+		if p.exchange != queue.ImageExchange || p.routingKey != queue.ImageRoutingKey {
+			t.Errorf("expected a replay onto the image queue, got %s/%s", p.exchange, p.routingKey)
+		}
+		for _, header := range []string{queue.HeaderAttempts, queue.HeaderFailureReason, queue.HeaderFailedAt} {
+			if _, ok := p.msg.Headers[header]; ok {
+				t.Errorf("expected header %s to be dropped, got %v", header, p.msg.Headers)
+			}
+		}
+	}
+	if len(broker.ready) != 1 || broker.ready[0].MessageId != "msg-3" {
+		t.Errorf("expected the third dead letter to stay, got %v", broker.ready)
+	}
+}
+
+func TestDeadLetterReplayKeepsMessageWhenPublishFails(t *testing.T) {
+	broker, admin := newTestDeadLetters(t, &fakeAMQPPublisher{err: errors.New("channel closed")})
+
+	replayed, err := admin.Replay(10)
+	if err == nil || replayed != 0 {
+		t.Fatalf("expected Replay to fail without moving anything, got %d, %v", replayed, err)
+	}
+	if len(broker.ready) != 3 || broker.ready[0].MessageId != "msg-1" {
+		t.Errorf("expected the dead letter to be requeued, got %v", broker.ready)
+	}
+}
+
+func TestRunDeadLetterCommand(t *testing.T) {
+	_, admin := newTestDeadLetters(t, &fakeAMQPPublisher{})
+	var out bytes.Buffer
+
+	if err := queue.RunDeadLetterCommand(admin, "inspect", 1, &out); err != nil {
+		t.Fatalf("inspect returned error: %v", err)
+	}
+	var letter queue.DeadLetter
+	if err := json.Unmarshal(out.Bytes(), &letter); err != nil || letter.MessageID != "msg-1" {
+		t.Errorf("expected one dead letter as JSON, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := queue.RunDeadLetterCommand(admin, "replay", 1, &out); err != nil || out.String() != "replayed 1 dead letters\n" {
+		t.Errorf("unexpected replay output %q, %v", out.String(), err)
+	}
+
+	out.Reset()
+	if err := queue.RunDeadLetterCommand(admin, "purge", 0, &out); err != nil || out.String() != "purged 2 dead letters\n" {
+		t.Errorf("unexpected purge output %q, %v", out.String(), err)
+	}
+
+	if err := queue.RunDeadLetterCommand(admin, "drop", 0, &out); !errors.Is(err, queue.ErrUnknownCommand) || !strings.Contains(err.Error(), "drop") {
+		t.Errorf("expected ErrUnknownCommand, got %v", err)
+	}
+}
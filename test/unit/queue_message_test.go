@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"product-management/internal/queue"
+)
+
+func TestImageProcessingTaskRoundTrip(t *testing.T) {
+	body, err := json.Marshal(queue.NewImageProcessingTask(12, []string{"a.jpg", "b.png"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, err := queue.DecodeImageProcessingTask(body)
+	if err != nil {
+		t.Fatalf("DecodeImageProcessingTask returned error: %v", err)
+	}
+	if task.Version != queue.ImageTaskSchemaVersion || task.ProductID != 12 || len(task.ImageURLs) != 2 {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if task.MessageID == "" || task.ImageListHash != queue.ImageListHash([]string{"a.jpg", "b.png"}) {
+		t.Errorf("expected message ID and image list hash, got %+v", task)
+	}
+}
+
+func TestDecodeImageProcessingTaskAcceptsVersion1(t *testing.T) {
+	task, err := queue.DecodeImageProcessingTask([]byte(`{"version":1,"product_id":3,"image_urls":["a.jpg"]}`))
+	if err != nil {
+		t.Fatalf("DecodeImageProcessingTask returned error: %v", err)
+	}
+	if task.MessageID != "" || task.ProductID != 3 {
+		t.Errorf("unexpected task %+v", task)
+	}
+}
+
+func TestImageListHashDependsOnOrderAndLocations(t *testing.T) {
+	ab := queue.ImageListHash([]string{"a.jpg", "b.jpg"})
+	if ab != queue.ImageListHash([]string{"a.jpg", "b.jpg"}) {
+		t.Error("expected equal lists to hash equally")
+	}
+	if ab == queue.ImageListHash([]string{"b.jpg", "a.jpg"}) || ab == queue.ImageListHash([]string{"a.jpgb.jpg"}) {
+		t.Error("expected different lists to hash differently")
+	}
+}
+
+func TestDecodeImageProcessingTaskRejectsInvalidMessages(t *testing.T) {
+	for name, body := range map[string]string{
+		"malformed":       `{"version":`,
+		"unknown version": `{"version":99,"product_id":1,"image_urls":[]}`,
+		"missing product": `{"version":1,"image_urls":["a.jpg"]}`,
+		"missing ids":     `{"version":2,"product_id":1,"image_urls":["a.jpg"]}`,
+	} {
+		if _, err := queue.DecodeImageProcessingTask([]byte(body)); !errors.Is(err, queue.ErrInvalidMessage) {
+			t.Errorf("%s: expected ErrInvalidMessage, got %v", name, err)
+		}
+	}
+}
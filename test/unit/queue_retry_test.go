@@ -0,0 +1,325 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"product-management/internal/queue"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// fakeBroker stands in for an AMQP channel on a single queue. Messages
+// taken with Get or delivered by ConsumeWithContext stay unacknowledged
+// until they are settled, like on a real channel.
+type fakeBroker struct {
+	mu      sync.Mutex
+	ready   []amqp091.Delivery
+	unacked []amqp091.Delivery
+	lastTag uint64
+	acked   []uint64
+	// requeued lists nacked tags that went back on the queue
+	requeued []uint64
+	// settled is called whenever a message is acked or nacked
+	settled func()
+}
+
+func (b *fakeBroker) push(d amqp091.Delivery) {
+	b.ready = append(b.ready, d)
+}
+
+func (b *fakeBroker) take() amqp091.Delivery {
+	d := b.ready[0]
+	b.ready = b.ready[1:]
+	b.lastTag++
+	d.DeliveryTag = b.lastTag
+	d.Acknowledger = b
+	b.unacked = append(b.unacked, d)
+	return d
+}
+
+// ConsumeWithContext delivers every ready message. The channel is left
+// open, as a broker's is while it waits for more.
+func (b *fakeBroker) ConsumeWithContext(ctx context.Context, queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp091.Table) (<-chan amqp091.Delivery, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	deliveries := make(chan amqp091.Delivery, len(b.ready))
+	for len(b.ready) > 0 {
+		deliveries <- b.take()
+	}
+	return deliveries, nil
+}
+
+func (b *fakeBroker) Get(queue string, autoAck bool) (amqp091.Delivery, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.ready) == 0 {
+		return amqp091.Delivery{}, false, nil
+	}
+	return b.take(), true, nil
+}
+
+func (b *fakeBroker) QueuePurge(name string, noWait bool) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := len(b.ready)
+	b.ready = nil
+	return count, nil
+}
+
+func (b *fakeBroker) Ack(tag uint64, multiple bool) error {
+	b.settle(tag, multiple, func(d amqp091.Delivery) { b.acked = append(b.acked, d.DeliveryTag) })
+	return nil
+}
+
+// Nack puts requeued messages back at the head of the queue in order.
+func (b *fakeBroker) Nack(tag uint64, multiple, requeue bool) error {
+	var back []amqp091.Delivery
+	b.settle(tag, multiple, func(d amqp091.Delivery) {
+		if requeue {
+			b.requeued = append(b.requeued, d.DeliveryTag)
+			back = append(back, d)
+		}
+	})
+
+	b.mu.Lock()
+	b.ready = append(back, b.ready...)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *fakeBroker) Reject(tag uint64, requeue bool) error {
+	return b.Nack(tag, false, requeue)
+}
+
+func (b *fakeBroker) settle(tag uint64, multiple bool, fn func(amqp091.Delivery)) {
+	b.mu.Lock()
+	var kept []amqp091.Delivery
+	for _, d := range b.unacked {
+		if d.DeliveryTag == tag || (multiple && d.DeliveryTag < tag) {
+			fn(d)
+		} else {
+			kept = append(kept, d)
+		}
+	}
+	b.unacked = kept
+	settled := b.settled
+	b.mu.Unlock()
+
+	if settled != nil {
+		settled()
+	}
+}
+
+type publishedMessage struct {
+	exchange, routingKey string
+	msg                  amqp091.Publishing
+}
+
+// fakeAMQPPublisher records what is published, or fails with err.
+type fakeAMQPPublisher struct {
+	mu        sync.Mutex
+	published []publishedMessage
+	err       error
+}
+
+func (p *fakeAMQPPublisher) Publish(exchange, routingKey string, msg amqp091.Publishing) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.mu.Lock()
+	p.published = append(p.published, publishedMessage{exchange, routingKey, msg})
+	p.mu.Unlock()
+	return nil
+}
+
+func imageTaskDelivery(t *testing.T, headers amqp091.Table) amqp091.Delivery {
+	t.Helper()
+
+	body, err := json.Marshal(queue.NewImageProcessingTask(1, []string{"a.jpg"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return amqp091.Delivery{Headers: headers, MessageId: "msg-1", Body: body}
+}
+
+var testRetryPolicy = queue.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute}
+
+// consume runs a consumer with the given number of workers over the
+// broker's messages until all of them are settled.
+func consume(broker *fakeBroker, publisher *fakeAMQPPublisher, workers int, handler queue.ImageTaskHandler) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	pending := len(broker.ready)
+	broker.settled = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if pending--; pending == 0 {
+			cancel()
+		}
+	}
+
+	return queue.NewConsumer(broker, publisher, testRetryPolicy, workers).ConsumeImageProcessingTasks(ctx, handler)
+}
+
+func TestRetryPolicyDelayBacksOffExponentially(t *testing.T) {
+	policy := queue.RetryPolicy{MaxAttempts: 6, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, expected := range want {
+		if got := policy.Delay(i + 1); got != expected {
+			t.Errorf("Delay(%d) = %s, want %s", i+1, got, expected)
+		}
+	}
+}
+
+func TestDelayQueueNameIsKeyedByTTL(t *testing.T) {
+	if got := queue.DelayQueueName(1500 * time.Millisecond); got != "product.images.retry.1500ms" {
+		t.Errorf("unexpected delay queue name %q", got)
+	}
+}
+
+func TestConsumerAcksHandledTasks(t *testing.T) {
+	broker, publisher := &fakeBroker{}, &fakeAMQPPublisher{}
+	broker.push(imageTaskDelivery(t, nil))
+
+	var handled int64
+	err := consume(broker, publisher, 1, func(ctx context.Context, task *queue.ImageProcessingTask) error {
+		handled = task.ProductID
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConsumeImageProcessingTasks returned error: %v", err)
+	}
+	if handled != 1 || len(broker.acked) != 1 || len(publisher.published) != 0 {
+		t.Errorf("expected the task to be handled and acked, got handled %d, acked %v, published %v", handled, broker.acked, publisher.published)
+	}
+}
+
+func TestConsumerHandlesTasksConcurrently(t *testing.T) {
+	broker, publisher := &fakeBroker{}, &fakeAMQPPublisher{}
+	for i := 0; i < 3; i++ {
+		broker.push(imageTaskDelivery(t, nil))
+	}
+
+	// Every handler waits for all three to be running at once
+	var running sync.WaitGroup
+	running.Add(3)
+	err := consume(broker, publisher, 3, func(ctx context.Context, task *queue.ImageProcessingTask) error {
+		running.Done()
+		done := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("tasks were not handled concurrently")
+		}
+	})
+	if err != nil {
+		t.Fatalf("ConsumeImageProcessingTasks returned error: %v", err)
+	}
+	if len(broker.acked) != 3 || len(publisher.published) != 0 {
+		t.Errorf("expected all tasks to be acked, acked %v, published %v", broker.acked, publisher.published)
+	}
+}
+
+func TestConsumerSchedulesRetryWithBackoff(t *testing.T) {
+	broker, publisher := &fakeBroker{}, &fakeAMQPPublisher{}
+	broker.push(imageTaskDelivery(t, amqp091.Table{queue.HeaderAttempts: int32(1)}))
+
+	err := consume(broker, publisher, 1, func(ctx context.Context, task *queue.ImageProcessingTask) error {
+		return errors.New("storage unavailable")
+	})
+	if err != nil {
+		t.Fatalf("ConsumeImageProcessingTasks returned error: %v", err)
+	}
+
+	if len(publisher.published) != 1 {
+		t.Fatalf("expected one retry, got %v", publisher.published)
+	}
+	retry := publisher.published[0]
+	if retry.exchange != queue.ImageRetryExchange || retry.routingKey != queue.DelayQueueName(2*time.Second) {
+		t.Errorf("expected the second delay queue, got %s/%s", retry.exchange, retry.routingKey)
+	}
+	if retry.msg.Headers[queue.HeaderAttempts] != int32(2) || retry.msg.Headers[queue.HeaderLastError] != "storage unavailable" {
+		t.Errorf("unexpected retry headers %v", retry.msg.Headers)
+	}
+	if retry.msg.MessageId != "msg-1" || len(broker.acked) != 1 {
+		t.Errorf("expected the original to be acked once its copy was published, acked %v", broker.acked)
+	}
+}
+
+func TestConsumerDeadLettersExhaustedTasks(t *testing.T) {
+	broker, publisher := &fakeBroker{}, &fakeAMQPPublisher{}
+	broker.push(imageTaskDelivery(t, amqp091.Table{queue.HeaderAttempts: int32(2)}))
+
+	err := consume(broker, publisher, 1, func(ctx context.Context, task *queue.ImageProcessingTask) error {
+		return errors.New("storage unavailable")
+	})
+	if err != nil {
+		t.Fatalf("ConsumeImageProcessingTasks returned error: %v", err)
+	}
+
+	if len(publisher.published) != 1 {
+		t.Fatalf("expected one dead letter, got %v", publisher.published)
+	}
+	dead := publisher.published[0]
+	if dead.exchange != queue.ImageDeadLetterExchange || dead.routingKey != queue.ImageDeadLetterKey {
+		t.Errorf("expected the dead-letter exchange, got %s/%s", dead.exchange, dead.routingKey)
+	}
+	headers := dead.msg.Headers
+	if headers[queue.HeaderAttempts] != int32(3) || headers[queue.HeaderFailureReason] != "storage unavailable" || headers[queue.HeaderFailedAt] == nil {
+		t.Errorf("unexpected dead letter headers %v", headers)
+	}
+}
+
+func TestConsumerDeadLettersPoisonMessagesImmediately(t *testing.T) {
+	broker, publisher := &fakeBroker{}, &fakeAMQPPublisher{}
+	broker.push(amqp091.Delivery{Body: []byte(`{"version": 99, "product_id": 1}`)})
+
+	err := consume(broker, publisher, 1, func(ctx context.Context, task *queue.ImageProcessingTask) error {
+		t.Error("the handler must not see undecodable tasks")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ConsumeImageProcessingTasks returned error: %v", err)
+	}
+
+	if len(publisher.published) != 1 || publisher.published[0].exchange != queue.ImageDeadLetterExchange {
+		t.Fatalf("expected the message to be dead-lettered, got %v", publisher.published)
+	}
+	headers := publisher.published[0].msg.Headers
+	reason, _ := headers[queue.HeaderFailureReason].(string)
+	if headers[queue.HeaderAttempts] != int32(1) || !strings.Contains(reason, "unsupported schema version 99") {
+		t.Errorf("unexpected dead letter headers %v", headers)
+	}
+}
+
+func TestConsumerRequeuesWhenRepublishFails(t *testing.T) {
+	broker, publisher := &fakeBroker{}, &fakeAMQPPublisher{err: errors.New("channel closed")}
+	broker.push(imageTaskDelivery(t, nil))
+
+	err := consume(broker, publisher, 1, func(ctx context.Context, task *queue.ImageProcessingTask) error {
+		return errors.New("storage unavailable")
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed to reschedule") {
+		t.Fatalf("expected the consumer to stop with a reschedule error, got %v", err)
+	}
+	if len(broker.requeued) != 1 || len(broker.acked) != 0 || len(broker.ready) != 1 {
+		t.Errorf("expected the original to be requeued, requeued %v, acked %v", broker.requeued, broker.acked)
+	}
+}
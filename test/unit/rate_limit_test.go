@@ -0,0 +1,154 @@
+package unit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"product-management/internal/api"
+	"product-management/internal/cache"
+	"product-management/internal/models"
+	"product-management/pkg/logger"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRateLimiter(t *testing.T) *cache.RateLimiter {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	return cache.NewRateLimiter(cache.NewRedisCache("redis://" + mr.Addr()))
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := cache.ParseRateLimit("100/1m")
+	if err != nil || limit.Requests != 100 || limit.Period != time.Minute {
+		t.Fatalf("unexpected limit %+v, %v", limit, err)
+	}
+
+	for _, spec := range []string{"", "100", "0/1m", "x/1m", "10/soon", "10/-1s"} {
+		if _, err := cache.ParseRateLimit(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestRateLimiterAllowsBurstThenRejects(t *testing.T) {
+	limiter := newTestRateLimiter(t)
+	limit := cache.RateLimit{Requests: 3, Period: time.Minute}
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(ctx, "ratelimit:test:ip:1", limit)
+		if err != nil {
+			t.Fatalf("Allow returned error: %v", err)
+		}
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("expected request to be allowed with %d remaining, got %+v", i, result)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, "ratelimit:test:ip:1", limit)
+	if err != nil {
+		t.Fatalf("Allow returned error: %v", err)
+	}
+	if result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > 20*time.Second {
+		t.Errorf("expected rejection with a retry within one interval, got %+v", result)
+	}
+
+	// Other clients have their own budget
+	if result, _ := limiter.Allow(ctx, "ratelimit:test:ip:2", limit); !result.Allowed {
+		t.Error("expected another client to be allowed")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := newTestRateLimiter(t)
+
+	router := gin.New()
+	router.GET("/products",
+		func(c *gin.Context) {
+			if c.GetHeader("X-Key") != "" {
+				c.Set("identity", &models.Identity{UserID: 7, APIKeyID: 2, RateLimit: 1})
+			}
+		},
+		routes.RateLimit(limiter, "products", cache.RateLimit{Requests: 2, Period: time.Minute}, logger.NewLogger()),
+		func(c *gin.Context) { c.Status(http.StatusNoContent) },
+	)
+
+	get := func(withKey bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/products", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if withKey {
+			req.Header.Set("X-Key", "1")
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := get(false); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: expected 204, got %d", i, rec.Code)
+		}
+	}
+
+	rec := get(false)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "30" || rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+
+	// The API key is counted separately, against its own limit
+	if rec := get(true); rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Fatalf("expected the API key's first request to pass with its own limit, got %d %v", rec.Code, rec.Header())
+	}
+	if rec := get(true); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the API key's second request to be limited, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitIgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for trustedProxies, wantLimited := range map[string]bool{
+		"":         true,
+		"10.0.0.1": false,
+	} {
+		limiter := newTestRateLimiter(t)
+		router := gin.New()
+		var proxies []string
+		if trustedProxies != "" {
+			proxies = []string{trustedProxies}
+		}
+		if err := router.SetTrustedProxies(proxies); err != nil {
+			t.Fatalf("SetTrustedProxies returned error: %v", err)
+		}
+		router.GET("/products",
+			routes.RateLimit(limiter, "products", cache.RateLimit{Requests: 1, Period: time.Minute}, logger.NewLogger()),
+			func(c *gin.Context) { c.Status(http.StatusNoContent) },
+		)
+
+		// Every request claims to come from a different client
+		codes := make([]int, 0, 2)
+		for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+			req := httptest.NewRequest(http.MethodGet, "/products", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			codes = append(codes, rec.Code)
+		}
+
+		limited := codes[1] == http.StatusTooManyRequests
+		if codes[0] != http.StatusNoContent || limited != wantLimited {
+			t.Errorf("trusted proxies %q: expected second request limited=%v, got %v", trustedProxies, wantLimited, codes)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var userColumns = []string{"id", "username", "email", "role", "password_hash", "created_at", "updated_at"}
+
+func newTestUserService(t *testing.T) (*service.UserService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, appLogger := newMockDB(t)
+	return service.NewUserService(
+		repository.NewUserRepository(db, appLogger),
+		repository.NewRefreshTokenRepository(db, appLogger),
+		repository.NewAuditRepository(db, appLogger),
+		repository.NewTransactor(db),
+		appLogger,
+	), mock
+}
+
+func TestCreateUserNormalizesEmail(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO users`).
+		WithArgs("alice", "alice@example.com", models.RoleSeller, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+			AddRow(4, time.Now(), time.Now()))
+	expectAudit(mock, models.AuditEntityUser, 4, models.AuditActionCreate)
+	mock.ExpectCommit()
+
+	user, err := userService.CreateUser(context.Background(), &models.UserCreateRequest{
+		Username: "alice",
+		Email:    " Alice@Example.com",
+		Password: "correct horse",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+	if user.ID != 4 || user.Email != "alice@example.com" {
+		t.Errorf("unexpected user %+v", user)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("correct horse")) != nil {
+		t.Error("expected the password to be stored as a bcrypt hash")
+	}
+}
+
+func TestCreateUserRejectsDuplicates(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO users`).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectRollback()
+
+	_, err := userService.CreateUser(context.Background(), &models.UserCreateRequest{
+		Username: "alice",
+		Email:    "alice@example.com",
+		Password: "correct horse",
+	})
+	if !errors.Is(err, repository.ErrUserExists) {
+		t.Fatalf("expected ErrUserExists, got %v", err)
+	}
+}
+
+func TestCreateUserValidatesEmail(t *testing.T) {
+	userService, _ := newTestUserService(t)
+
+	_, err := userService.CreateUser(context.Background(), &models.UserCreateRequest{
+		Username: "alice",
+		Email:    "not-an-email",
+		Password: "correct horse",
+	})
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+}
+
+func TestListUsersByEmail(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE LOWER\(email\) = LOWER\(\$1\)`).
+		WithArgs("bob@example.com").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	users, total, err := userService.ListUsers(context.Background(), &models.Identity{UserID: 1, Role: models.RoleAdmin}, &models.UserFilterParams{Email: "Bob@example.com"})
+	if err != nil {
+		t.Fatalf("ListUsers returned error: %v", err)
+	}
+	if len(users) != 0 || total != 0 {
+		t.Errorf("expected no users, got %d (total %d)", len(users), total)
+	}
+}
+
+func TestListUsersPaginates(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(25))
+	mock.ExpectQuery(`SELECT (.+) FROM users ORDER BY id LIMIT \$1 OFFSET \$2`).
+		WithArgs(10, 10).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(11, "user11", "u11@example.com", "seller", "", time.Now(), time.Now()))
+
+	users, total, err := userService.ListUsers(context.Background(), &models.Identity{UserID: 1, Role: models.RoleAdmin}, &models.UserFilterParams{Page: 2})
+	if err != nil {
+		t.Fatalf("ListUsers returned error: %v", err)
+	}
+	if total != 25 || len(users) != 1 || users[0].Username != "user11" {
+		t.Errorf("unexpected page %+v (total %d)", users, total)
+	}
+}
+
+func TestDeleteUserWithProducts(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectBegin()
+	expectFindUser(mock, 3)
+	mock.ExpectExec(`DELETE FROM users`).
+		WithArgs(int64(3)).
+		WillReturnError(&pq.Error{Code: "23503"})
+	mock.ExpectRollback()
+
+	if err := userService.DeleteUser(context.Background(), &models.Identity{UserID: 3}, 3); !errors.Is(err, repository.ErrUserHasProducts) {
+		t.Fatalf("expected ErrUserHasProducts, got %v", err)
+	}
+}
+
+func TestDeleteUserNotFound(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = \$1`).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectRollback()
+
+	if err := userService.DeleteUser(context.Background(), &models.Identity{UserID: 3}, 3); !errors.Is(err, repository.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func expectRevokeUserTokens(mock sqlmock.Sqlmock, userID int64) {
+	mock.ExpectExec(`UPDATE refresh_tokens\s+SET revoked_at = CURRENT_TIMESTAMP\s+WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+}
+
+func expectFindUser(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(id, "bob", "bob@example.com", models.RoleSeller, "old-hash", time.Now(), time.Now()))
+}
+
+func TestUpdateUserRevokesSessionsOnPasswordOrRoleChange(t *testing.T) {
+	admin := &models.Identity{UserID: 1, Role: models.RoleAdmin}
+
+	for name, tc := range map[string]struct {
+		actor *models.Identity
+		req   models.UserUpdateRequest
+		role  string
+	}{
+		"password": {
+			&models.Identity{UserID: 3, Role: models.RoleSeller},
+			models.UserUpdateRequest{Username: "bob", Email: "bob@example.com", Password: "correct horse"},
+			models.RoleSeller,
+		},
+		"role": {
+			admin,
+			models.UserUpdateRequest{Username: "bob", Email: "bob@example.com", Role: models.RoleViewer},
+			models.RoleViewer,
+		},
+	} {
+		userService, mock := newTestUserService(t)
+
+		mock.ExpectBegin()
+		expectFindUser(mock, 3)
+		mock.ExpectQuery(`UPDATE users`).
+			WillReturnRows(sqlmock.NewRows([]string{"role", "created_at", "updated_at"}).
+				AddRow(tc.role, time.Now(), time.Now()))
+		expectRevokeUserTokens(mock, 3)
+		expectAudit(mock, models.AuditEntityUser, 3, models.AuditActionUpdate)
+		mock.ExpectCommit()
+
+		if _, err := userService.UpdateUser(context.Background(), tc.actor, 3, &tc.req); err != nil {
+			t.Fatalf("%s: UpdateUser returned error: %v", name, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestUpdateUserKeepsSessionsOnProfileChange(t *testing.T) {
+	userService, mock := newTestUserService(t)
+
+	mock.ExpectBegin()
+	expectFindUser(mock, 3)
+	mock.ExpectQuery(`UPDATE users`).
+		WillReturnRows(sqlmock.NewRows([]string{"role", "created_at", "updated_at"}).
+			AddRow(models.RoleSeller, time.Now(), time.Now()))
+	expectAudit(mock, models.AuditEntityUser, 3, models.AuditActionUpdate)
+	mock.ExpectCommit()
+
+	actor := &models.Identity{UserID: 3, Role: models.RoleSeller}
+	req := &models.UserUpdateRequest{Username: "bobby", Email: "bob@example.com"}
+	if _, err := userService.UpdateUser(context.Background(), actor, 3, req); err != nil {
+		t.Fatalf("UpdateUser returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateUserOnlyAllowsSelf(t *testing.T) {
+	userService, _ := newTestUserService(t)
+
+	_, err := userService.UpdateUser(context.Background(), &models.Identity{UserID: 2}, 3, &models.UserUpdateRequest{
+		Username: "mallory",
+		Email:    "mallory@example.com",
+	})
+	if !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestUpdateUserRoleRequiresAdmin(t *testing.T) {
+	userService, _ := newTestUserService(t)
+
+	_, err := userService.UpdateUser(context.Background(), &models.Identity{UserID: 3, Role: models.RoleSeller}, 3, &models.UserUpdateRequest{
+		Username: "bob",
+		Email:    "bob@example.com",
+		Role:     models.RoleAdmin,
+	})
+	if !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"product-management/internal/models"
+	"product-management/internal/repository"
+	"product-management/internal/service"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+var variantColumns = []string{
+	"id", "product_id", "sku", "attributes", "price", "barcode", "weight_grams", "created_at", "updated_at",
+}
+
+// expectListVariants returns one variant of the product in size M, at the
+// product price.
+func expectListVariants(mock sqlmock.Sqlmock, productID int64) {
+	mock.ExpectQuery(`SELECT (.+) FROM product_variants WHERE product_id = \$1`).
+		WithArgs(productID).
+		WillReturnRows(sqlmock.NewRows(variantColumns).
+			AddRow(3, productID, "TEE-M", `{"size":"M"}`, nil, "", nil, time.Now(), time.Now()))
+}
+
+func TestCreateVariantNormalizesSKUAndAttributes(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	price := models.Amount(12_50)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectQuery(`INSERT INTO product_variants`).
+		WithArgs(int64(1), "TEE-RED-M", `{"color":"red","size":"M"}`, &price, "4006381333931", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).AddRow(3, time.Now(), time.Now()))
+
+	variant, err := productService.CreateVariant(context.Background(), seller, 1, &models.ProductVariantRequest{
+		SKU:        " tee-red-m ",
+		Attributes: map[string]string{" Color": "red ", "size": "M"},
+		Price:      &price,
+		Barcode:    "4006381333931",
+	})
+	if err != nil {
+		t.Fatalf("CreateVariant returned error: %v", err)
+	}
+	if variant.ID != 3 || variant.SKU != "TEE-RED-M" || variant.Attributes["color"] != "red" {
+		t.Errorf("unexpected variant %+v", variant)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCreateVariantRejectsTakenSKU(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	expectFindProduct(mock, 1, nil)
+	mock.ExpectQuery(`INSERT INTO product_variants`).
+		WillReturnError(&pq.Error{Code: "23505"})
+
+	_, err := productService.CreateVariant(context.Background(), seller, 1, &models.ProductVariantRequest{SKU: "TEE-M"})
+	if !errors.Is(err, repository.ErrSKUExists) {
+		t.Fatalf("expected ErrSKUExists, got %v", err)
+	}
+}
+
+func TestSellerCannotAddVariantToAnotherSellersProduct(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+	other := &models.Identity{UserID: 8, Role: models.RoleSeller}
+
+	expectFindProduct(mock, 1, nil)
+	_, err := productService.CreateVariant(context.Background(), other, 1, &models.ProductVariantRequest{SKU: "TEE-M"})
+	if !errors.Is(err, service.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestListProductsMatchesVariantAttributes(t *testing.T) {
+	productService, mock, _ := newTestProductService(t)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products WHERE id IN \(SELECT product_id FROM product_variants WHERE attributes @> \$1::JSONB\)$`).
+		WithArgs(`{"color":"Red","size":"M"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT (.+) FROM products WHERE id IN`).
+		WillReturnRows(sqlmock.NewRows(listColumns))
+
+	_, err := productService.ListProducts(context.Background(), seller, &models.ProductFilterParams{
+		Attributes: map[string]string{"Size": "M", "color": " Red"},
+	})
+	if err != nil {
+		t.Fatalf("ListProducts returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}